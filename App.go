@@ -36,16 +36,21 @@ type App struct {
 	MuxRouter    *api.MuxRouter
 	Logger       *zap.SugaredLogger
 	watcher      *git.GitWatcherImpl
+	maintenance  *git.RepositoryMaintenanceServiceImpl
+	diskQuota    *git.DiskQuotaServiceImpl
 	server       *http.Server
 	db           *pg.DB
 	pubSubClient *internal.PubSubClient
 }
 
-func NewApp(MuxRouter *api.MuxRouter, Logger *zap.SugaredLogger, impl *git.GitWatcherImpl, db *pg.DB, pubSubClient *internal.PubSubClient) *App {
+func NewApp(MuxRouter *api.MuxRouter, Logger *zap.SugaredLogger, impl *git.GitWatcherImpl, db *pg.DB, pubSubClient *internal.PubSubClient,
+	maintenance *git.RepositoryMaintenanceServiceImpl, diskQuota *git.DiskQuotaServiceImpl) *App {
 	return &App{
 		MuxRouter:    MuxRouter,
 		Logger:       Logger,
 		watcher:      impl,
+		maintenance:  maintenance,
+		diskQuota:    diskQuota,
 		db:           db,
 		pubSubClient: pubSubClient,
 	}
@@ -81,9 +86,12 @@ func (app *App) Start() {
 
 func (app *App) Stop() {
 	app.Logger.Infow("orchestrator shutdown initiating")
-	timeoutContext, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	timeoutContext, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	app.Logger.Infow("stopping cron")
 	app.watcher.StopCron()
+	app.maintenance.StopCron()
+	app.diskQuota.StopCron()
 	app.Logger.Infow("stopping nats")
 
 	err := app.pubSubClient.Conn.Drain()
@@ -38,8 +38,17 @@ type RestHandler interface {
 	GetCommitMetadataForPipelineMaterial(w http.ResponseWriter, r *http.Request)
 	ReloadAllMaterial(w http.ResponseWriter, r *http.Request)
 	ReloadMaterial(w http.ResponseWriter, r *http.Request)
+	RunMaintenance(w http.ResponseWriter, r *http.Request)
 	GetChangesInRelease(w http.ResponseWriter, r *http.Request)
+	GetChangesInReleaseForMaterials(w http.ResponseWriter, r *http.Request)
 	GetCommitInfoForTag(w http.ResponseWriter, r *http.Request)
+	GetFileDiff(w http.ResponseWriter, r *http.Request)
+	GetTree(w http.ResponseWriter, r *http.Request)
+	ListBranches(w http.ResponseWriter, r *http.Request)
+	ListTags(w http.ResponseWriter, r *http.Request)
+	SearchCommits(w http.ResponseWriter, r *http.Request)
+	CompareRefs(w http.ResponseWriter, r *http.Request)
+	GetFileContent(w http.ResponseWriter, r *http.Request)
 	RefreshGitMaterial(w http.ResponseWriter, r *http.Request)
 	GetWebhookData(w http.ResponseWriter, r *http.Request)
 	GetAllWebhookEventConfigForHost(w http.ResponseWriter, r *http.Request)
@@ -48,13 +57,14 @@ type RestHandler interface {
 	GetWebhookPayloadFilterDataForPipelineMaterialId(w http.ResponseWriter, r *http.Request)
 }
 
-func NewRestHandlerImpl(repositoryManager pkg.RepoManager, logger *zap.SugaredLogger) *RestHandlerImpl {
-	return &RestHandlerImpl{repositoryManager: repositoryManager, logger: logger}
+func NewRestHandlerImpl(repositoryManager pkg.RepoManager, logger *zap.SugaredLogger, maintenanceService git.RepositoryMaintenanceService) *RestHandlerImpl {
+	return &RestHandlerImpl{repositoryManager: repositoryManager, logger: logger, maintenanceService: maintenanceService}
 }
 
 type RestHandlerImpl struct {
-	repositoryManager pkg.RepoManager
-	logger            *zap.SugaredLogger
+	repositoryManager  pkg.RepoManager
+	logger             *zap.SugaredLogger
+	maintenanceService git.RepositoryMaintenanceService
 }
 
 type Response struct {
@@ -123,7 +133,7 @@ func (handler RestHandlerImpl) AddRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	handler.logger.Infow("add repo request ", "req", Repo)
-	res, err := handler.repositoryManager.AddRepo(Repo)
+	res, err := handler.repositoryManager.AddRepo(r.Context(), Repo)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -141,7 +151,7 @@ func (handler RestHandlerImpl) UpdateRepo(w http.ResponseWriter, r *http.Request
 		return
 	}
 	handler.logger.Infow("update repo request ", "req", Repo)
-	res, err := handler.repositoryManager.UpdateRepo(Repo)
+	res, err := handler.repositoryManager.UpdateRepo(r.Context(), Repo)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -159,7 +169,7 @@ func (handler RestHandlerImpl) SavePipelineMaterial(w http.ResponseWriter, r *ht
 		return
 	}
 	handler.logger.Infow("update pipelineMaterial request ", "req", material)
-	res, err := handler.repositoryManager.SavePipelineMaterial(material)
+	res, err := handler.repositoryManager.SavePipelineMaterial(r.Context(), material)
 	if err != nil {
 		handler.logger.Errorw("error in saving pipeline material", "err", err)
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
@@ -183,7 +193,7 @@ func (handler RestHandlerImpl) ReloadMaterial(w http.ResponseWriter, r *http.Req
 		return
 	}
 	handler.logger.Infow("reload all pipelineMaterial request", "id", materialId)
-	err = handler.repositoryManager.ResetRepo(materialId)
+	err = handler.repositoryManager.ResetRepo(r.Context(), materialId)
 	if err != nil {
 		handler.logger.Errorw("error in reloading pipeline material", "err", err)
 		handler.writeJsonResp(w, err, nil, http.StatusInternalServerError)
@@ -192,6 +202,24 @@ func (handler RestHandlerImpl) ReloadMaterial(w http.ResponseWriter, r *http.Req
 	}
 }
 
+func (handler RestHandlerImpl) RunMaintenance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	materialId, err := strconv.Atoi(vars["materialId"])
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("repository maintenance request", "id", materialId)
+	res, err := handler.maintenanceService.RunMaintenance(r.Context(), materialId)
+	if err != nil {
+		handler.logger.Errorw("error in repository maintenance", "id", materialId, "err", err)
+		handler.writeJsonResp(w, err, res, http.StatusInternalServerError)
+	} else {
+		handler.writeJsonResp(w, nil, res, http.StatusOK)
+	}
+}
+
 //-------------
 func (handler RestHandlerImpl) FetchChanges(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
@@ -203,7 +231,7 @@ func (handler RestHandlerImpl) FetchChanges(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	handler.logger.Infow("update pipelineMaterial request ", "req", material)
-	commits, err := handler.repositoryManager.FetchChanges(material.PipelineMaterialId, material.From, material.To, material.Count)
+	commits, err := handler.repositoryManager.FetchChanges(r.Context(), material)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -241,11 +269,11 @@ func (handler RestHandlerImpl) GetCommitMetadata(w http.ResponseWriter, r *http.
 	handler.logger.Infow("commit detail request", "req", material)
 	var commits *git.GitCommit
 	if len(material.GitTag) > 0 {
-		commits, err = handler.repositoryManager.GetCommitInfoForTag(material)
+		commits, err = handler.repositoryManager.GetCommitInfoForTag(r.Context(), material)
 	} else if len(material.BranchName) > 0 {
-		commits, err = handler.repositoryManager.GetLatestCommitForBranch(material.PipelineMaterialId, material.BranchName)
+		commits, err = handler.repositoryManager.GetLatestCommitForBranch(r.Context(), material.PipelineMaterialId, material.BranchName)
 	} else {
-		commits, err = handler.repositoryManager.GetCommitMetadata(material.PipelineMaterialId, material.GitHash)
+		commits, err = handler.repositoryManager.GetCommitMetadata(r.Context(), material.PipelineMaterialId, material.GitHash)
 	}
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
@@ -264,7 +292,7 @@ func (handler RestHandlerImpl) GetCommitMetadataForPipelineMaterial(w http.Respo
 		return
 	}
 	handler.logger.Infow("commit detail request for pipeline material", "req", material)
-	commit, err := handler.repositoryManager.GetCommitMetadataForPipelineMaterial(material.PipelineMaterialId, material.GitHash)
+	commit, err := handler.repositoryManager.GetCommitMetadataForPipelineMaterial(r.Context(), material.PipelineMaterialId, material.GitHash)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -282,7 +310,7 @@ func (handler RestHandlerImpl) GetCommitInfoForTag(w http.ResponseWriter, r *htt
 		return
 	}
 	handler.logger.Infow("tag detail request", "req", material)
-	commits, err := handler.repositoryManager.GetCommitInfoForTag(material)
+	commits, err := handler.repositoryManager.GetCommitInfoForTag(r.Context(), material)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -301,7 +329,115 @@ func (handler RestHandlerImpl) GetChangesInRelease(w http.ResponseWriter, r *htt
 		return
 	}
 	handler.logger.Infow("commit detail request", "req", request)
-	commits, err := handler.repositoryManager.GetReleaseChanges(request)
+	commits, err := handler.repositoryManager.GetReleaseChanges(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, commits, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) GetChangesInReleaseForMaterials(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &pkg.BatchReleaseChangesRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("batch release changes request", "materials", len(request.Materials))
+	changes, err := handler.repositoryManager.GetBatchReleaseChanges(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, changes, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) GetFileDiff(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.FileDiffRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("file diff request", "req", request)
+	diff, err := handler.repositoryManager.GetFileDiff(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, diff, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) GetTree(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.TreeRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("tree request", "req", request)
+	tree, err := handler.repositoryManager.GetTree(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, tree, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) ListBranches(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.BranchListRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("branch list request", "req", request)
+	branches, err := handler.repositoryManager.ListBranches(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, branches, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) ListTags(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.TagListRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("tag list request", "req", request)
+	tags, err := handler.repositoryManager.ListTags(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, tags, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) SearchCommits(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.CommitSearchRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("commit search request", "req", request)
+	commits, err := handler.repositoryManager.SearchCommits(r.Context(), request)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
 	} else {
@@ -309,6 +445,42 @@ func (handler RestHandlerImpl) GetChangesInRelease(w http.ResponseWriter, r *htt
 	}
 }
 
+func (handler RestHandlerImpl) CompareRefs(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.CompareRefsRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("compare refs request", "req", request)
+	comparison, err := handler.repositoryManager.CompareRefs(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, comparison, http.StatusOK)
+	}
+}
+
+func (handler RestHandlerImpl) GetFileContent(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	request := &git.FileContentRequest{}
+	err := decoder.Decode(request)
+	if err != nil {
+		handler.logger.Error(err)
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	handler.logger.Infow("file content request", "req", request)
+	content, err := handler.repositoryManager.GetFileContent(r.Context(), request)
+	if err != nil {
+		handler.writeJsonResp(w, err, nil, http.StatusBadRequest)
+	} else {
+		handler.writeJsonResp(w, err, content, http.StatusOK)
+	}
+}
+
 func (handler RestHandlerImpl) RefreshGitMaterial(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	request := &git.RefreshGitMaterialRequest{}
@@ -319,7 +491,7 @@ func (handler RestHandlerImpl) RefreshGitMaterial(w http.ResponseWriter, r *http
 		return
 	}
 	handler.logger.Infow("commit detail request", "req", request)
-	resp, err := handler.repositoryManager.RefreshGitMaterial(request)
+	resp, err := handler.repositoryManager.RefreshGitMaterial(r.Context(), request)
 	if err != nil {
 		handler.writeJsonResp(w, err, nil, http.StatusInternalServerError)
 	} else {
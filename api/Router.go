@@ -61,16 +61,25 @@ func (r MuxRouter) Init() {
 	r.Router.Path("/git-repo").HandlerFunc(r.restHandler.UpdateRepo).Methods("PUT")
 	r.Router.Path("/git-pipeline-material").HandlerFunc(r.restHandler.SavePipelineMaterial).Methods("POST")
 	r.Router.Path("/git-changes").HandlerFunc(r.restHandler.FetchChanges).Methods("POST")
+	r.Router.Path("/git-changes/search").HandlerFunc(r.restHandler.SearchCommits).Methods("POST")
 	r.Router.Path("/git-head").HandlerFunc(r.restHandler.GetHeadForPipelineMaterials).Methods("POST")
 	r.Router.Path("/commit-metadata").HandlerFunc(r.restHandler.GetCommitMetadata).Methods("POST")
 	r.Router.Path("/pipeline-material-commit-metadata").HandlerFunc(r.restHandler.GetCommitMetadataForPipelineMaterial).Methods("GET")
 	r.Router.Path("/tag-commit-metadata").HandlerFunc(r.restHandler.GetCommitInfoForTag).Methods("POST")
+	r.Router.Path("/git-tree").HandlerFunc(r.restHandler.GetTree).Methods("POST")
+	r.Router.Path("/git-file").HandlerFunc(r.restHandler.GetFileContent).Methods("POST")
+	r.Router.Path("/git-repo/branches").HandlerFunc(r.restHandler.ListBranches).Methods("POST")
+	r.Router.Path("/git-repo/tags").HandlerFunc(r.restHandler.ListTags).Methods("POST")
 	r.Router.Path("/git-repo/refresh").HandlerFunc(r.restHandler.RefreshGitMaterial).Methods("POST")
 
 	r.Router.Path("/admin/reload-all").HandlerFunc(r.restHandler.ReloadAllMaterial).Methods("POST")
 	r.Router.Path("/admin/reload/{materialId}").HandlerFunc(r.restHandler.ReloadMaterial).Methods("POST")
+	r.Router.Path("/admin/maintenance/{materialId}").HandlerFunc(r.restHandler.RunMaintenance).Methods("POST")
 
 	r.Router.Path("/release/changes").HandlerFunc(r.restHandler.GetChangesInRelease).Methods("POST")
+	r.Router.Path("/release/compare").HandlerFunc(r.restHandler.CompareRefs).Methods("POST")
+	r.Router.Path("/release/changes/batch").HandlerFunc(r.restHandler.GetChangesInReleaseForMaterials).Methods("POST")
+	r.Router.Path("/release/diff").HandlerFunc(r.restHandler.GetFileDiff).Methods("POST")
 
 	r.Router.Path("/webhook/data").HandlerFunc(r.restHandler.GetWebhookData).Methods("GET")
 	r.Router.Path("/webhook/host/events").HandlerFunc(r.restHandler.GetAllWebhookEventConfigForHost).Methods("GET")
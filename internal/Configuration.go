@@ -3,7 +3,12 @@ package internal
 import "github.com/caarlos0/env"
 
 type Configuration struct {
-	CommitStatsTimeoutInSec int `env:"COMMIT_STATS_TIMEOUT_IN_SEC" envDefault:"2"`
+	CommitStatsTimeoutInSec    int `env:"COMMIT_STATS_TIMEOUT_IN_SEC" envDefault:"2"`
+	GitFetchTimeoutInSec       int `env:"GIT_FETCH_TIMEOUT_IN_SEC" envDefault:"600"`
+	GitCloneTimeoutInSec       int `env:"GIT_CLONE_TIMEOUT_IN_SEC" envDefault:"600"`
+	GitCommandTimeoutInSec     int `env:"GIT_COMMAND_TIMEOUT_IN_SEC" envDefault:"30"`
+	GitReadTimeoutInSec        int `env:"GIT_READ_TIMEOUT_IN_SEC" envDefault:"60"`
+	GitMaintenanceTimeoutInSec int `env:"GIT_MAINTENANCE_TIMEOUT_IN_SEC" envDefault:"600"`
 }
 
 func ParseConfiguration() (*Configuration, error) {
@@ -63,12 +63,14 @@ const (
 	WEBHOOK_EVENT_TOPIC               string = "WEBHOOK_EVENT"
 	WEBHOOK_EVENT_TOPIC_GRP           string = "WEBHOOK_EVENT_GRP"
 	WEBHOOK_EVENT_TOPIC_DURABLE       string = "WEBHOOK_EVENT_DURABLE"
+	CI_MATERIAL_STATE_CHANGE_TOPIC    string = "CI-MATERIAL-STATE-CHANGE"
+	GIT_DISK_QUOTA_STATE_CHANGE_TOPIC string = "GIT-DISK-QUOTA-STATE-CHANGE"
 )
 
 var ORCHESTRATOR_SUBJECTS = []string{BULK_APPSTORE_DEPLOY_TOPIC, BULK_DEPLOY_TOPIC, BULK_HIBERNATE_TOPIC, WEBHOOK_EVENT_TOPIC}
 var CI_RUNNER_SUBJECTS = []string{CI_COMPLETE_TOPIC, CD_STAGE_COMPLETE_TOPIC}
 var KUBEWATCH_SUBJECTS = []string{APPLICATION_STATUS_UPDATE_TOPIC, CRON_EVENTS, WORKFLOW_STATUS_UPDATE_TOPIC, CD_WORKFLOW_STATUS_UPDATE}
-var GIT_SENSOR_SUBJECTS = []string{NEW_CI_MATERIAL_TOPIC, POLL_CI_TOPIC}
+var GIT_SENSOR_SUBJECTS = []string{NEW_CI_MATERIAL_TOPIC, POLL_CI_TOPIC, CI_MATERIAL_STATE_CHANGE_TOPIC, GIT_DISK_QUOTA_STATE_CHANGE_TOPIC}
 
 func GetStreamSubjects(streamName string) []string {
 	var subjArr []string
@@ -102,7 +104,38 @@ func AddStream(js nats.JetStreamContext, streamNames ...string) error {
 			}
 		} else if err != nil {
 			log.Fatal("Error while getting stream info", "stream name", streamName, "error", err)
+		} else if missingSubjects(streamInfo.Config.Subjects, GetStreamSubjects(streamName)) {
+			// stream created by older version does not have subjects added later
+			log.Print("Updating subjects of stream", "Stream name", streamName)
+			streamConfig := streamInfo.Config
+			streamConfig.Subjects = mergeSubjects(streamConfig.Subjects, GetStreamSubjects(streamName))
+			_, err = js.UpdateStream(&streamConfig)
+			if err != nil {
+				log.Print("Error while updating stream subjects", "stream name", streamName, "error", err)
+				return err
+			}
 		}
 	}
 	return err
 }
+
+func missingSubjects(existing []string, required []string) bool {
+	return len(mergeSubjects(existing, required)) != len(existing)
+}
+
+func mergeSubjects(existing []string, required []string) []string {
+	subjects := append([]string{}, existing...)
+	for _, subject := range required {
+		found := false
+		for _, existingSubject := range existing {
+			if existingSubject == subject {
+				found = true
+				break
+			}
+		}
+		if !found {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
@@ -24,37 +24,38 @@ import (
 type RepositoryLocker struct {
 	logger *zap.SugaredLogger
 	Mutex  sync.Mutex
-	Bank   map[int]*RepositoryLock
+	Bank   map[string]*RepositoryLock
 }
 
 func NewRepositoryLocker(logger *zap.SugaredLogger) *RepositoryLocker {
 	return &RepositoryLocker{
 		logger: logger,
-		Bank:   map[int]*RepositoryLock{},
+		Bank:   map[string]*RepositoryLock{},
 	}
 }
 
-func (locker *RepositoryLocker) LeaseLocker(RepositoryId int) *RepositoryLock {
-	locker.logger.Infow("lease req get for ", "repo", RepositoryId)
+// LeaseLocker returns lock of repository at location, materials sharing an object store share the lock as well
+func (locker *RepositoryLocker) LeaseLocker(location string) *RepositoryLock {
+	locker.logger.Infow("lease req get for ", "repo", location)
 	locker.Mutex.Lock()
 	defer locker.Mutex.Unlock()
-	repositoryLock := locker.Bank[RepositoryId]
+	repositoryLock := locker.Bank[location]
 	if repositoryLock == nil {
 		repositoryLock = &RepositoryLock{} //check for initialization
-		locker.Bank[RepositoryId] = repositoryLock
+		locker.Bank[location] = repositoryLock
 	}
 	repositoryLock.counter = repositoryLock.counter + 1
 	return repositoryLock
 }
 
-func (locker *RepositoryLocker) ReturnLocker(appId int) {
-	locker.logger.Infow("lease req release for ", "repo", appId)
+func (locker *RepositoryLocker) ReturnLocker(location string) {
+	locker.logger.Infow("lease req release for ", "repo", location)
 	locker.Mutex.Lock()
 	defer locker.Mutex.Unlock()
-	repositoryLock := locker.Bank[appId]
+	repositoryLock := locker.Bank[location]
 	repositoryLock.counter = repositoryLock.counter - 1
 	if repositoryLock.counter == 0 {
-		delete(locker.Bank, appId)
+		delete(locker.Bank, location)
 	}
 }
 
@@ -105,6 +105,48 @@ var GitMaterialPollCounter = promauto.NewCounterVec(
 	},
 	[]string{})
 
+var GitCloneCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name:        "git_clone",
+		Help:        "no of clones of git repositories",
+		ConstLabels: constLabels,
+	},
+	[]string{})
+
+var GitStoreCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name:        "git_store_count",
+	Help:        "no of object stores fetched for active git repositories",
+	ConstLabels: constLabels,
+}, []string{})
+
+var GitBaseDiskUsageBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name:        "git_base_disk_usage_bytes",
+	Help:        "space used on volume of git checkouts",
+	ConstLabels: constLabels,
+}, []string{})
+
+var GitBaseDiskCapacityBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name:        "git_base_disk_capacity_bytes",
+	Help:        "space available to git checkouts, quota if configured else size of volume",
+	ConstLabels: constLabels,
+}, []string{})
+
+var GitBaseDiskQuotaBreachCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name:        "git_base_disk_quota_breach",
+		Help:        "no of times disk usage of git checkouts crossed a quota threshold",
+		ConstLabels: constLabels,
+	},
+	[]string{"level"})
+
+var GitStoreEvictionCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name:        "git_store_eviction",
+		Help:        "no of repositories removed from disk to free space",
+		ConstLabels: constLabels,
+	},
+	[]string{"reason"})
+
 var PanicCounter = promauto.NewCounterVec(
 	prometheus.CounterOpts{
 		Name:        "panic",
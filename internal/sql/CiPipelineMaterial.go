@@ -19,7 +19,6 @@ package sql
 import (
 	"github.com/go-pg/pg"
 	"go.uber.org/zap"
-	"time"
 )
 
 type CiPipelineMaterial struct {
@@ -29,15 +28,38 @@ type CiPipelineMaterial struct {
 	Type          SourceType `sql:"type"`
 	Value         string     `sql:"value"`
 	Active        bool       `sql:"active,notnull"`
-	LastSeenHash  string     `sql:"last_seen_hash,notnull"`
-	CommitAuthor  string     `sql:"commit_author"`
-	CommitDate    time.Time  `sql:"commit_date"`
-	
-	CommitHistory string     `sql:"commit_history"` //last five commit for caching purpose1
-	Errored       bool       `sql:"errored,notnull"`
-	ErrorMsg      string     `sql:"error_msg,notnull"`
+	LastSeenHash  string     `sql:"last_seen_hash,notnull"` // head commit, commits are kept in git_commit
+
+	Errored          bool              `sql:"errored,notnull"`
+	ErrorMsg         string            `sql:"error_msg,notnull"`
+	PathFilter       *PathFilter       `sql:"path_filter,notnull"` // notnull so that removing the filter is persisted by UpdateNotNull
+	SuppressionRules *SuppressionRules `sql:"suppression_rules,notnull"`
+	State            MaterialState     `sql:"state"`
 }
 
+type MaterialState string
+
+const (
+	MATERIAL_STATE_NORMAL            MaterialState = "NORMAL"
+	MATERIAL_STATE_HISTORY_REWRITTEN MaterialState = "HISTORY_REWRITTEN" // last seen commit is not an ancestor of new head
+	MATERIAL_STATE_BRANCH_DELETED    MaterialState = "BRANCH_DELETED"
+)
+
+// SuppressionRules stops new commit notifications of a material for commits matching any rule
+type SuppressionRules struct {
+	SkipMessageTokens  []string `json:"skipMessageTokens,omitempty"`  // e.g. [skip ci], [ci skip]
+	SkipAuthorPatterns []string `json:"skipAuthorPatterns,omitempty"` // regex matched against "name <email>" of author
+	OnlyMergeCommits   bool     `json:"onlyMergeCommits,omitempty"`
+	// commits without a valid signature from trusted keys of git provider are not notified, applies to polled branches only
+	RequireSignedCommits bool `json:"requireSignedCommits,omitempty"`
+}
+
+// PathFilter restricts notifications of a branch material to changes in matching paths,
+// globs are relative to repository root and support * , ? and **
+type PathFilter struct {
+	IncludePaths []string `json:"includePaths,omitempty"`
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+}
 
 type CiPipelineMaterialRepository interface {
 	FindByGitMaterialId(gitMaterialId int) ([]*CiPipelineMaterial, error)
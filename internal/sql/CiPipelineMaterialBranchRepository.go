@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package sql
+
+import (
+	"github.com/go-pg/pg"
+	"time"
+)
+
+// CiPipelineMaterialBranch keeps the last seen head of every branch matched by a
+// SOURCE_TYPE_BRANCH_REGEX pipeline material
+type CiPipelineMaterialBranch struct {
+	tableName            struct{}  `sql:"ci_pipeline_material_branch" pg:",discard_unknown_columns"`
+	Id                   int       `sql:"id,pk"`
+	CiPipelineMaterialId int       `sql:"ci_pipeline_material_id,notnull"`
+	BranchName           string    `sql:"branch_name,notnull"`
+	LastSeenHash         string    `sql:"last_seen_hash,notnull"`
+	Active               bool      `sql:"active,notnull"`
+	CreatedOn            time.Time `sql:"created_on,notnull"`
+	UpdatedOn            time.Time `sql:"updated_on"`
+}
+
+type CiPipelineMaterialBranchRepository interface {
+	FindActiveByCiPipelineMaterialId(ciPipelineMaterialId int) ([]*CiPipelineMaterialBranch, error)
+	Save(branches []*CiPipelineMaterialBranch) error
+	Update(branches []*CiPipelineMaterialBranch) error
+}
+
+type CiPipelineMaterialBranchRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewCiPipelineMaterialBranchRepositoryImpl(dbConnection *pg.DB) *CiPipelineMaterialBranchRepositoryImpl {
+	return &CiPipelineMaterialBranchRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl CiPipelineMaterialBranchRepositoryImpl) FindActiveByCiPipelineMaterialId(ciPipelineMaterialId int) ([]*CiPipelineMaterialBranch, error) {
+	var branches []*CiPipelineMaterialBranch
+	err := impl.dbConnection.Model(&branches).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("active = ?", true).
+		Select()
+	return branches, err
+}
+
+func (impl CiPipelineMaterialBranchRepositoryImpl) Save(branches []*CiPipelineMaterialBranch) error {
+	_, err := impl.dbConnection.Model(&branches).Insert()
+	return err
+}
+
+func (impl CiPipelineMaterialBranchRepositoryImpl) Update(branches []*CiPipelineMaterialBranch) error {
+	err := impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		for _, branch := range branches {
+			_, err := tx.Model(branch).WherePK().Update()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package sql
+
+import (
+	"github.com/go-pg/pg"
+	"time"
+)
+
+// CommitStats is file stats of a commit computed once per commit hash, FileStats is json of changed files. Partial stats
+// list only names of changed files as computed from a blobless clone, they are replaced once computed from a full one
+type CommitStats struct {
+	tableName  struct{}  `sql:"git_commit_stats" pg:",discard_unknown_columns"`
+	Id         int       `sql:"id,pk"`
+	CommitHash string    `sql:"commit_hash,notnull"`
+	FileStats  string    `sql:"file_stats"`
+	Partial    bool      `sql:"partial,notnull"`
+	CreatedOn  time.Time `sql:"created_on,notnull"`
+}
+
+type CommitStatsRepository interface {
+	FindByCommitHashes(commitHashes []string) ([]*CommitStats, error)
+	Save(commitStats *CommitStats) error
+}
+
+type CommitStatsRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewCommitStatsRepositoryImpl(dbConnection *pg.DB) *CommitStatsRepositoryImpl {
+	return &CommitStatsRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl CommitStatsRepositoryImpl) FindByCommitHashes(commitHashes []string) ([]*CommitStats, error) {
+	var commitStats []*CommitStats
+	if len(commitHashes) == 0 {
+		return commitStats, nil
+	}
+	err := impl.dbConnection.Model(&commitStats).
+		Where("commit_hash in (?) ", pg.In(commitHashes)).
+		Select()
+	return commitStats, err
+}
+
+// Save replaces partial stats already saved for the same commit, complete ones are kept as they are
+func (impl CommitStatsRepositoryImpl) Save(commitStats *CommitStats) error {
+	_, err := impl.dbConnection.Model(commitStats).
+		OnConflict("(commit_hash) DO UPDATE").
+		Set("file_stats = EXCLUDED.file_stats").
+		Set("partial = EXCLUDED.partial").
+		Set("created_on = EXCLUDED.created_on").
+		Where("commit_stats.partial IS TRUE").
+		Insert()
+	return err
+}
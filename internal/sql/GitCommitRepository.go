@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package sql
+
+import (
+	"github.com/go-pg/pg"
+	"time"
+)
+
+// GitCommit is a commit seen in the repository of git material, SubmoduleChanges is json. Stats of commit are kept
+// in git_commit_stats
+type GitCommit struct {
+	tableName        struct{}  `sql:"git_commit" pg:",discard_unknown_columns"`
+	Id               int       `sql:"id,pk"`
+	GitMaterialId    int       `sql:"git_material_id,notnull"`
+	CommitHash       string    `sql:"commit_hash,notnull"`
+	Author           string    `sql:"author"`
+	CommitDate       time.Time `sql:"commit_date"`
+	Message          string    `sql:"message"`
+	SubmoduleChanges string    `sql:"submodule_changes"`
+	CreatedOn        time.Time `sql:"created_on,notnull"`
+}
+
+// GitBranchCommit associates a commit with the branch of a pipeline material, commits of a branch are ordered by
+// CommitOrder, head having the highest
+type GitBranchCommit struct {
+	tableName            struct{}  `sql:"git_branch_commit" pg:",discard_unknown_columns"`
+	Id                   int       `sql:"id,pk"`
+	CiPipelineMaterialId int       `sql:"ci_pipeline_material_id,notnull"`
+	BranchName           string    `sql:"branch_name,notnull"`
+	CommitHash           string    `sql:"commit_hash,notnull"`
+	CommitOrder          int       `sql:"commit_order,notnull"`
+	SkipReason           string    `sql:"skip_reason"`
+	Warning              string    `sql:"warning"`
+	CreatedOn            time.Time `sql:"created_on,notnull"`
+}
+
+type GitCommitRepository interface {
+	FindByGitMaterialIdAndCommitHashes(gitMaterialId int, commitHashes []string) ([]*GitCommit, error)
+	Save(commits []*GitCommit) error
+	FindBranchCommits(ciPipelineMaterialId int, branchName string, limit int) ([]*GitBranchCommit, error)
+	FindBranchCommitsBefore(ciPipelineMaterialId int, branchName string, beforeOrder int, limit int) ([]*GitBranchCommit, error)
+	FindBranchCommitsByHashes(ciPipelineMaterialId int, branchName string, commitHashes []string) ([]*GitBranchCommit, error)
+	FindOldestBranchCommit(ciPipelineMaterialId int, branchName string) (*GitBranchCommit, error)
+	FindBranchCommit(ciPipelineMaterialId int, branchName string, commitHash string) (*GitBranchCommit, error)
+	SaveBranchCommits(branchCommits []*GitBranchCommit) error
+	InsertBranchCommits(branchCommits []*GitBranchCommit) error
+	ReplaceBranchCommits(ciPipelineMaterialId int, branchName string, branchCommits []*GitBranchCommit) error
+}
+
+type GitCommitRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewGitCommitRepositoryImpl(dbConnection *pg.DB) *GitCommitRepositoryImpl {
+	return &GitCommitRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl GitCommitRepositoryImpl) FindByGitMaterialIdAndCommitHashes(gitMaterialId int, commitHashes []string) ([]*GitCommit, error) {
+	var commits []*GitCommit
+	if len(commitHashes) == 0 {
+		return commits, nil
+	}
+	err := impl.dbConnection.Model(&commits).
+		Where("git_material_id =? ", gitMaterialId).
+		Where("commit_hash in (?) ", pg.In(commitHashes)).
+		Select()
+	return commits, err
+}
+
+// Save inserts commits not saved yet, content of a commit never changes
+func (impl GitCommitRepositoryImpl) Save(commits []*GitCommit) error {
+	_, err := impl.dbConnection.Model(&commits).
+		OnConflict("DO NOTHING").
+		Insert()
+	return err
+}
+
+func (impl GitCommitRepositoryImpl) FindBranchCommits(ciPipelineMaterialId int, branchName string, limit int) ([]*GitBranchCommit, error) {
+	var branchCommits []*GitBranchCommit
+	err := impl.dbConnection.Model(&branchCommits).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("branch_name =? ", branchName).
+		Order("commit_order DESC").
+		Limit(limit).
+		Select()
+	return branchCommits, err
+}
+
+func (impl GitCommitRepositoryImpl) FindBranchCommitsBefore(ciPipelineMaterialId int, branchName string, beforeOrder int, limit int) ([]*GitBranchCommit, error) {
+	var branchCommits []*GitBranchCommit
+	err := impl.dbConnection.Model(&branchCommits).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("branch_name =? ", branchName).
+		Where("commit_order < ? ", beforeOrder).
+		Order("commit_order DESC").
+		Limit(limit).
+		Select()
+	return branchCommits, err
+}
+
+func (impl GitCommitRepositoryImpl) FindBranchCommitsByHashes(ciPipelineMaterialId int, branchName string, commitHashes []string) ([]*GitBranchCommit, error) {
+	var branchCommits []*GitBranchCommit
+	if len(commitHashes) == 0 {
+		return branchCommits, nil
+	}
+	err := impl.dbConnection.Model(&branchCommits).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("branch_name =? ", branchName).
+		Where("commit_hash in (?) ", pg.In(commitHashes)).
+		Select()
+	return branchCommits, err
+}
+
+func (impl GitCommitRepositoryImpl) FindOldestBranchCommit(ciPipelineMaterialId int, branchName string) (*GitBranchCommit, error) {
+	branchCommit := &GitBranchCommit{}
+	err := impl.dbConnection.Model(branchCommit).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("branch_name =? ", branchName).
+		Order("commit_order ASC").
+		Limit(1).
+		Select()
+	return branchCommit, err
+}
+
+func (impl GitCommitRepositoryImpl) FindBranchCommit(ciPipelineMaterialId int, branchName string, commitHash string) (*GitBranchCommit, error) {
+	branchCommit := &GitBranchCommit{}
+	err := impl.dbConnection.Model(branchCommit).
+		Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+		Where("branch_name =? ", branchName).
+		Where("commit_hash =? ", commitHash).
+		Select()
+	return branchCommit, err
+}
+
+// SaveBranchCommits inserts associations, order, skip reason and warning of commits already associated are updated
+func (impl GitCommitRepositoryImpl) SaveBranchCommits(branchCommits []*GitBranchCommit) error {
+	_, err := impl.dbConnection.Model(&branchCommits).
+		OnConflict("(ci_pipeline_material_id, branch_name, commit_hash) DO UPDATE").
+		Set("commit_order = EXCLUDED.commit_order").
+		Set("skip_reason = EXCLUDED.skip_reason").
+		Set("warning = EXCLUDED.warning").
+		Insert()
+	return err
+}
+
+// InsertBranchCommits inserts associations, commits already associated with branch are left as they are
+func (impl GitCommitRepositoryImpl) InsertBranchCommits(branchCommits []*GitBranchCommit) error {
+	_, err := impl.dbConnection.Model(&branchCommits).
+		OnConflict("DO NOTHING").
+		Insert()
+	return err
+}
+
+// ReplaceBranchCommits drops all associations of branch and inserts the given ones in a single transaction, so that
+// history of branch is never left empty
+func (impl GitCommitRepositoryImpl) ReplaceBranchCommits(ciPipelineMaterialId int, branchName string, branchCommits []*GitBranchCommit) error {
+	err := impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(&GitBranchCommit{}).
+			Where("ci_pipeline_material_id =? ", ciPipelineMaterialId).
+			Where("branch_name =? ", branchName).
+			Delete()
+		if err != nil || len(branchCommits) == 0 {
+			return err
+		}
+		_, err = tx.Model(&branchCommits).Insert()
+		return err
+	})
+	return err
+}
@@ -31,6 +31,15 @@ const (
 	SOURCE_TYPE_WEBHOOK      SourceType = "WEBHOOK"
 )
 
+type CloneStrategy string
+
+const (
+	CLONE_STRATEGY_FULL     CloneStrategy = "FULL"
+	CLONE_STRATEGY_BLOBLESS CloneStrategy = "BLOBLESS" // --filter=blob:none
+	CLONE_STRATEGY_TREELESS CloneStrategy = "TREELESS" // --filter=tree:0
+	CLONE_STRATEGY_SHALLOW  CloneStrategy = "SHALLOW"  // --depth=CloneDepth
+)
+
 //TODO: add support for submodule
 type GitMaterial struct {
 	tableName        struct{} `sql:"git_material"`
@@ -44,10 +53,22 @@ type GitMaterial struct {
 	CheckoutMsgAny   string   `sql:"checkout_msg_any"`
 	Deleted          bool     `sql:"deleted,notnull"`
 	//------
+	CloneStrategy CloneStrategy `sql:"clone_strategy"`
+	CloneDepth    int           `sql:"clone_depth"`
+	FetchRefSpecs []string      `sql:"fetch_ref_specs,array"`
+	//------
 	LastFetchTime       time.Time `json:"last_fetch_time"`
 	FetchStatus         bool      `json:"fetch_status"`
 	LastFetchErrorCount int       `json:"last_fetch_error_count"` //continues fetch error
 	FetchErrorMessage   string    `json:"fetch_error_message"`
+	//------
+	LastMaintenanceTime       time.Time `json:"last_maintenance_time"`
+	LastMaintenanceDurationMs int64     `json:"last_maintenance_duration_ms"`
+	MaintenanceErrorMessage   string    `json:"maintenance_error_message"`
+	//------
+	DiskUsageBytes      int64     `json:"disk_usage_bytes"`
+	LastUsedTime        time.Time `json:"last_used_time"`
+	Evicted             bool      `sql:"evicted,notnull"` // repository removed from disk to free space, cloned again on next use
 	GitProvider         *GitProvider
 	CiPipelineMaterials []*CiPipelineMaterial
 }
@@ -55,10 +76,17 @@ type GitMaterial struct {
 type MaterialRepository interface {
 	FindById(id int) (*GitMaterial, error)
 	Update(material *GitMaterial) error
+	UpdateFetchRefSpecs(material *GitMaterial) error
+	UpdateMaintenanceStatus(material *GitMaterial) error
+	UpdateLastUsedTime(material *GitMaterial) error
+	UpdateDiskUsageByLocation(location string, diskUsageBytes int64) error
+	UpdateEvictedByLocation(location string, evicted bool) error
 	Save(material *GitMaterial) error
 	FindActive() ([]*GitMaterial, error)
 	FindAll() ([]*GitMaterial, error)
 	FindAllActiveByUrls(urls []string) ([]*GitMaterial, error)
+	FindByCheckoutLocation(location string) ([]*GitMaterial, error)
+	FindDeletedWithCheckout() ([]*GitMaterial, error)
 }
 type MaterialRepositoryImpl struct {
 	dbConnection *pg.DB
@@ -73,8 +101,46 @@ func (repo MaterialRepositoryImpl) Save(material *GitMaterial) error {
 	return err
 }
 
+// Update skips disk accounting and maintenance columns, those are owned by disk quota and maintenance and updated only
+// by their own methods
 func (repo MaterialRepositoryImpl) Update(material *GitMaterial) error {
-	_, err := repo.dbConnection.Model(material).WherePK().Update()
+	_, err := repo.dbConnection.Model(material).
+		ExcludeColumn("disk_usage_bytes", "last_used_time", "evicted",
+			"last_maintenance_time", "last_maintenance_duration_ms", "maintenance_error_message").
+		WherePK().Update()
+	return err
+}
+
+func (repo MaterialRepositoryImpl) UpdateFetchRefSpecs(material *GitMaterial) error {
+	_, err := repo.dbConnection.Model(material).Column("fetch_ref_specs").WherePK().Update()
+	return err
+}
+
+func (repo MaterialRepositoryImpl) UpdateMaintenanceStatus(material *GitMaterial) error {
+	_, err := repo.dbConnection.Model(material).
+		Column("last_maintenance_time", "last_maintenance_duration_ms", "maintenance_error_message").
+		WherePK().Update()
+	return err
+}
+
+func (repo MaterialRepositoryImpl) UpdateLastUsedTime(material *GitMaterial) error {
+	_, err := repo.dbConnection.Model(material).Column("last_used_time").WherePK().Update()
+	return err
+}
+
+func (repo MaterialRepositoryImpl) UpdateDiskUsageByLocation(location string, diskUsageBytes int64) error {
+	_, err := repo.dbConnection.Model((*GitMaterial)(nil)).
+		Set("disk_usage_bytes = ?", diskUsageBytes).
+		Where("checkout_location = ?", location).
+		Update()
+	return err
+}
+
+func (repo MaterialRepositoryImpl) UpdateEvictedByLocation(location string, evicted bool) error {
+	_, err := repo.dbConnection.Model((*GitMaterial)(nil)).
+		Set("evicted = ?", evicted).
+		Where("checkout_location = ?", location).
+		Update()
 	return err
 }
 
@@ -122,3 +188,28 @@ func (repo MaterialRepositoryImpl) FindAllActiveByUrls(urls[] string) ([]*GitMat
 		Select()
 	return materials, err
 }
+
+// FindByCheckoutLocation returns checked out materials sharing the repository at location
+func (repo MaterialRepositoryImpl) FindByCheckoutLocation(location string) ([]*GitMaterial, error) {
+	var materials []*GitMaterial
+	err := repo.dbConnection.Model(&materials).
+		Column("git_material.*", "GitProvider").
+		Where("git_material.checkout_location =? ", location).
+		Where("git_material.deleted =? ", false).
+		Where("git_material.checkout_status=? ", true).
+		Order("git_material.id ASC").
+		Select()
+	return materials, err
+}
+
+// FindDeletedWithCheckout returns deleted materials whose repository may still be on disk
+func (repo MaterialRepositoryImpl) FindDeletedWithCheckout() ([]*GitMaterial, error) {
+	var materials []*GitMaterial
+	err := repo.dbConnection.Model(&materials).
+		Where("deleted =? ", true).
+		Where("checkout_status=? ", true).
+		Where("evicted =? ", false).
+		Order("id ASC").
+		Select()
+	return materials, err
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package sql
+
+import (
+	"github.com/go-pg/pg"
+	"time"
+)
+
+// GitMaterialTag is a tag already seen in the repository of git material, used to detect newly created tags
+type GitMaterialTag struct {
+	tableName     struct{}  `sql:"git_material_tag" pg:",discard_unknown_columns"`
+	Id            int       `sql:"id,pk"`
+	GitMaterialId int       `sql:"git_material_id,notnull"`
+	TagName       string    `sql:"tag_name,notnull"`
+	CommitHash    string    `sql:"commit_hash,notnull"`
+	CommitAuthor  string    `sql:"commit_author"`
+	CommitDate    time.Time `sql:"commit_date"`
+	CommitMessage string    `sql:"commit_message"`
+	CreatedOn     time.Time `sql:"created_on,notnull"`
+}
+
+type GitMaterialTagRepository interface {
+	FindByGitMaterialId(gitMaterialId int) ([]*GitMaterialTag, error)
+	Exists(gitMaterialId int) (bool, error)
+	Save(tags []*GitMaterialTag) error
+	DeleteByIds(ids []int) error
+}
+
+type GitMaterialTagRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewGitMaterialTagRepositoryImpl(dbConnection *pg.DB) *GitMaterialTagRepositoryImpl {
+	return &GitMaterialTagRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl GitMaterialTagRepositoryImpl) FindByGitMaterialId(gitMaterialId int) ([]*GitMaterialTag, error) {
+	var tags []*GitMaterialTag
+	err := impl.dbConnection.Model(&tags).
+		Where("git_material_id =? ", gitMaterialId).
+		Order("commit_date DESC").
+		Select()
+	return tags, err
+}
+
+func (impl GitMaterialTagRepositoryImpl) Exists(gitMaterialId int) (bool, error) {
+	var tag GitMaterialTag
+	exists, err := impl.dbConnection.Model(&tag).Where("git_material_id =? ", gitMaterialId).Exists()
+	return exists, err
+}
+
+func (impl GitMaterialTagRepositoryImpl) Save(tags []*GitMaterialTag) error {
+	_, err := impl.dbConnection.Model(&tags).Insert()
+	return err
+}
+
+func (impl GitMaterialTagRepositoryImpl) DeleteByIds(ids []int) error {
+	_, err := impl.dbConnection.Model(&GitMaterialTag{}).
+		Where("id in (?) ", pg.In(ids)).
+		Delete()
+	return err
+}
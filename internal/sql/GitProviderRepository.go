@@ -38,6 +38,9 @@ type GitProvider struct {
 	AccessToken   string   `sql:"access_token"`
 	AuthMode      AuthMode `sql:"auth_mode,notnull"`
 	Active        bool     `sql:"active,notnull"`
+	// armored public gpg keys and authorized_keys formatted ssh keys trusted for commit signature verification
+	TrustedGpgKeys string `sql:"trusted_gpg_keys"`
+	TrustedSshKeys string `sql:"trusted_ssh_keys"`
 	//models.AuditLog
 }
 
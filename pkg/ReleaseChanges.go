@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/devtron-labs/git-sensor/pkg/git"
+	"github.com/gammazero/workerpool"
+)
+
+// RELEASE_CHANGES_WORKER limits materials of a batch computed in parallel, each under lock of its repository
+const RELEASE_CHANGES_WORKER = 5
+
+type BatchReleaseChangesRequest struct {
+	Materials []*ReleaseChangesRequest `json:"materials"`
+}
+
+type MaterialReleaseChanges struct {
+	PipelineMaterialId int             `json:"pipelineMaterialId"`
+	GitMaterialId      int             `json:"gitMaterialId,omitempty"`
+	OldCommit          string          `json:"oldCommit"`
+	NewCommit          string          `json:"newCommit"`
+	Changes            *git.GitChanges `json:"changes,omitempty"`
+	Error              string          `json:"error,omitempty"`
+}
+
+type ReleaseAuthor struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Commits int    `json:"commits"`
+}
+
+// RepositoryReleaseChanges aggregates changes of materials of a repository. Commits, authors and files changed are
+// distinct across its materials, additions and deletions are summed per distinct commit range, so lines changed in
+// overlapping ranges of materials are counted for each of them
+type RepositoryReleaseChanges struct {
+	GitMaterialId       int              `json:"gitMaterialId"`
+	Url                 string           `json:"url"`
+	PipelineMaterialIds []int            `json:"pipelineMaterialIds"`
+	Commits             int              `json:"commits"`
+	Authors             []*ReleaseAuthor `json:"authors"`
+	FilesChanged        int              `json:"filesChanged"`
+	Additions           int              `json:"additions"`
+	Deletions           int              `json:"deletions"`
+}
+
+type ReleaseChangesSummary struct {
+	Repositories    int `json:"repositories"`
+	Materials       int `json:"materials"`
+	FailedMaterials int `json:"failedMaterials"`
+	Commits         int `json:"commits"`
+	Authors         int `json:"authors"`
+	FilesChanged    int `json:"filesChanged"`
+	Additions       int `json:"additions"`
+	Deletions       int `json:"deletions"`
+}
+
+type BatchReleaseChangesResponse struct {
+	Materials    []*MaterialReleaseChanges   `json:"materials"`
+	Repositories []*RepositoryReleaseChanges `json:"repositories"`
+	Summary      *ReleaseChangesSummary      `json:"summary"`
+}
+
+// GetBatchReleaseChanges computes changes of every material of request in parallel, failure of a material is reported
+// in its result without failing the others
+func (impl RepoManagerImpl) GetBatchReleaseChanges(ctx context.Context, request *BatchReleaseChangesRequest) (*BatchReleaseChangesResponse, error) {
+	if len(request.Materials) == 0 {
+		return nil, errors.New("materials are required")
+	}
+	results := make([]*MaterialReleaseChanges, len(request.Materials))
+	gitMaterials := make([]*RepositoryReleaseChanges, len(request.Materials))
+	wp := workerpool.New(RELEASE_CHANGES_WORKER)
+	for i, materialRequest := range request.Materials {
+		i, materialRequest := i, materialRequest
+		results[i] = &MaterialReleaseChanges{
+			PipelineMaterialId: materialRequest.PipelineMaterialId,
+			OldCommit:          materialRequest.OldCommit,
+			NewCommit:          materialRequest.NewCommit,
+		}
+		wp.Submit(func() {
+			gitMaterial, gitChanges, err := impl.getReleaseChanges(ctx, materialRequest)
+			if gitMaterial != nil {
+				results[i].GitMaterialId = gitMaterial.Id
+				gitMaterials[i] = &RepositoryReleaseChanges{GitMaterialId: gitMaterial.Id, Url: gitMaterial.Url}
+			}
+			if err != nil {
+				impl.logger.Errorw("error in computing changes of material", "pipelineMaterialId", materialRequest.PipelineMaterialId, "err", err)
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Changes = gitChanges
+		})
+	}
+	wp.StopWait()
+	repositories, summary := aggregateReleaseChanges(results, gitMaterials)
+	return &BatchReleaseChangesResponse{Materials: results, Repositories: repositories, Summary: summary}, nil
+}
+
+// aggregateReleaseChanges groups successful results by repository in order of request. Additions and deletions of a
+// repository are sums over its distinct commit ranges, materials with the same range add them once but overlapping
+// ranges add lines they share again
+func aggregateReleaseChanges(results []*MaterialReleaseChanges, gitMaterials []*RepositoryReleaseChanges) ([]*RepositoryReleaseChanges, *ReleaseChangesSummary) {
+	summary := &ReleaseChangesSummary{Materials: len(results)}
+	repositories := make([]*RepositoryReleaseChanges, 0)
+	repositoryById := make(map[int]*RepositoryReleaseChanges)
+	commitsById := make(map[int]map[string]bool)
+	filesById := make(map[int]map[string]bool)
+	rangesById := make(map[int]map[string]bool)
+	authorsById := make(map[int]map[string]*ReleaseAuthor)
+	allAuthors := make(map[string]bool)
+	for i, result := range results {
+		if result.Changes == nil {
+			summary.FailedMaterials++
+			continue
+		}
+		id := result.GitMaterialId
+		repository, ok := repositoryById[id]
+		if !ok {
+			repository = gitMaterials[i]
+			repository.Authors = []*ReleaseAuthor{}
+			repositoryById[id] = repository
+			repositories = append(repositories, repository)
+			commitsById[id] = make(map[string]bool)
+			filesById[id] = make(map[string]bool)
+			rangesById[id] = make(map[string]bool)
+			authorsById[id] = make(map[string]*ReleaseAuthor)
+		}
+		repository.PipelineMaterialIds = append(repository.PipelineMaterialIds, result.PipelineMaterialId)
+		for _, commit := range result.Changes.Commits {
+			if commit == nil || commitsById[id][commit.Hash.Long] {
+				continue
+			}
+			commitsById[id][commit.Hash.Long] = true
+			repository.Commits++
+			author, ok := authorsById[id][commit.Author.Email]
+			if !ok {
+				author = &ReleaseAuthor{Name: commit.Author.Name, Email: commit.Author.Email}
+				authorsById[id][commit.Author.Email] = author
+				repository.Authors = append(repository.Authors, author)
+			}
+			author.Commits++
+			allAuthors[commit.Author.Email] = true
+		}
+		commitRange := result.OldCommit + ".." + result.NewCommit
+		if rangesById[id][commitRange] {
+			continue
+		}
+		rangesById[id][commitRange] = true
+		for _, fileStat := range result.Changes.FileStats {
+			if !filesById[id][fileStat.Name] {
+				filesById[id][fileStat.Name] = true
+				repository.FilesChanged++
+			}
+			repository.Additions += fileStat.Addition
+			repository.Deletions += fileStat.Deletion
+		}
+	}
+	summary.Repositories = len(repositories)
+	summary.Authors = len(allAuthors)
+	for _, repository := range repositories {
+		summary.Commits += repository.Commits
+		summary.FilesChanged += repository.FilesChanged
+		summary.Additions += repository.Additions
+		summary.Deletions += repository.Deletions
+	}
+	return repositories, summary
+}
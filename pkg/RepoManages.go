@@ -17,6 +17,7 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,24 +26,37 @@ import (
 	"github.com/devtron-labs/git-sensor/pkg/git"
 	_ "github.com/robfig/cron/v3"
 	"go.uber.org/zap"
+	"sort"
+	"time"
 )
 
+// LAST_USED_TIME_UPDATE_INTERVAL limits writes of last used time of a material, which only orders disk eviction
+const LAST_USED_TIME_UPDATE_INTERVAL = time.Hour
+
 type RepoManager interface {
 	GetHeadForPipelineMaterials(ids []int) ([]*git.CiPipelineMaterialBean, error)
-	FetchChanges(pipelineMaterialId int, from string, to string, count int) (*git.MaterialChangeResp, error) //limit
-	GetCommitMetadata(pipelineMaterialId int, gitHash string) (*git.GitCommit, error)
-	GetLatestCommitForBranch(pipelineMaterialId int, branchName string) (*git.GitCommit, error)
-	GetCommitMetadataForPipelineMaterial(pipelineMaterialId int, gitHash string) (*git.GitCommit, error)
+	FetchChanges(ctx context.Context, request *git.FetchScmChangesRequest) (*git.MaterialChangeResp, error) //limit
+	GetCommitMetadata(ctx context.Context, pipelineMaterialId int, gitHash string) (*git.GitCommit, error)
+	GetLatestCommitForBranch(ctx context.Context, pipelineMaterialId int, branchName string) (*git.GitCommit, error)
+	GetCommitMetadataForPipelineMaterial(ctx context.Context, pipelineMaterialId int, gitHash string) (*git.GitCommit, error)
 
 	SaveGitProvider(provider *sql.GitProvider) (*sql.GitProvider, error)
-	AddRepo(material []*sql.GitMaterial) ([]*sql.GitMaterial, error)
-	UpdateRepo(material *sql.GitMaterial) (*sql.GitMaterial, error)
-	SavePipelineMaterial(material []*sql.CiPipelineMaterial) ([]*sql.CiPipelineMaterial, error)
+	AddRepo(ctx context.Context, material []*sql.GitMaterial) ([]*sql.GitMaterial, error)
+	UpdateRepo(ctx context.Context, material *sql.GitMaterial) (*sql.GitMaterial, error)
+	SavePipelineMaterial(ctx context.Context, material []*sql.CiPipelineMaterial) ([]*sql.CiPipelineMaterial, error)
 	ReloadAllRepo()
-	ResetRepo(materialId int) error
-	GetReleaseChanges(request *ReleaseChangesRequest) (*git.GitChanges, error)
-	GetCommitInfoForTag(request *git.CommitMetadataRequest) (*git.GitCommit, error)
-	RefreshGitMaterial(req *git.RefreshGitMaterialRequest) (*git.RefreshGitMaterialResponse, error)
+	ResetRepo(ctx context.Context, materialId int) error
+	GetReleaseChanges(ctx context.Context, request *ReleaseChangesRequest) (*git.GitChanges, error)
+	GetBatchReleaseChanges(ctx context.Context, request *BatchReleaseChangesRequest) (*BatchReleaseChangesResponse, error)
+	GetFileDiff(ctx context.Context, request *git.FileDiffRequest) (*git.FileDiffResponse, error)
+	GetTree(ctx context.Context, request *git.TreeRequest) (*git.TreeResponse, error)
+	GetFileContent(ctx context.Context, request *git.FileContentRequest) (*git.FileContentResponse, error)
+	ListBranches(ctx context.Context, request *git.BranchListRequest) (*git.BranchListResponse, error)
+	ListTags(ctx context.Context, request *git.TagListRequest) (*git.TagListResponse, error)
+	SearchCommits(ctx context.Context, request *git.CommitSearchRequest) (*git.CommitSearchResponse, error)
+	CompareRefs(ctx context.Context, request *git.CompareRefsRequest) (*git.CompareRefsResponse, error)
+	GetCommitInfoForTag(ctx context.Context, request *git.CommitMetadataRequest) (*git.GitCommit, error)
+	RefreshGitMaterial(ctx context.Context, req *git.RefreshGitMaterialRequest) (*git.RefreshGitMaterialResponse, error)
 
 	GetWebhookDataById(id int) (*git.WebhookData, error)
 	GetAllWebhookEventConfigForHost(gitHostId int) ([]*git.WebhookEventConfig, error)
@@ -64,6 +78,10 @@ type RepoManagerImpl struct {
 	webhookEventDataMappingRepository             sql.WebhookEventDataMappingRepository
 	webhookEventDataMappingFilterResultRepository sql.WebhookEventDataMappingFilterResultRepository
 	webhookEventBeanConverter                     git.WebhookEventBeanConverter
+	ciPipelineMaterialBranchRepository            sql.CiPipelineMaterialBranchRepository
+	gitMaterialTagRepository                      sql.GitMaterialTagRepository
+	diskQuotaService                              git.DiskQuotaService
+	commitStoreService                            git.CommitStoreService
 }
 
 func NewRepoManagerImpl(
@@ -78,6 +96,10 @@ func NewRepoManagerImpl(
 	webhookEventDataMappingRepository sql.WebhookEventDataMappingRepository,
 	webhookEventDataMappingFilterResultRepository sql.WebhookEventDataMappingFilterResultRepository,
 	webhookEventBeanConverter git.WebhookEventBeanConverter,
+	ciPipelineMaterialBranchRepository sql.CiPipelineMaterialBranchRepository,
+	gitMaterialTagRepository sql.GitMaterialTagRepository,
+	diskQuotaService git.DiskQuotaService,
+	commitStoreService git.CommitStoreService,
 ) *RepoManagerImpl {
 	return &RepoManagerImpl{
 		logger:                            logger,
@@ -92,13 +114,27 @@ func NewRepoManagerImpl(
 		webhookEventDataMappingRepository: webhookEventDataMappingRepository,
 		webhookEventDataMappingFilterResultRepository: webhookEventDataMappingFilterResultRepository,
 		webhookEventBeanConverter:                     webhookEventBeanConverter,
+		ciPipelineMaterialBranchRepository:            ciPipelineMaterialBranchRepository,
+		gitMaterialTagRepository:                      gitMaterialTagRepository,
+		diskQuotaService:                              diskQuotaService,
+		commitStoreService:                            commitStoreService,
 	}
 }
 
-func (impl RepoManagerImpl) SavePipelineMaterial(materials []*sql.CiPipelineMaterial) ([]*sql.CiPipelineMaterial, error) {
+func (impl RepoManagerImpl) SavePipelineMaterial(ctx context.Context, materials []*sql.CiPipelineMaterial) ([]*sql.CiPipelineMaterial, error) {
 	var old []*sql.CiPipelineMaterial
 	var newMaterial []*sql.CiPipelineMaterial
 	for _, material := range materials {
+		_, err := git.NewPathMatcher(material.PathFilter)
+		if err != nil {
+			impl.logger.Errorw("invalid path filter", "materialId", material.Id, "pathFilter", material.PathFilter, "err", err)
+			return materials, err
+		}
+		_, err = git.NewSuppressionRuleMatcher(material.SuppressionRules)
+		if err != nil {
+			impl.logger.Errorw("invalid suppression rules", "materialId", material.Id, "suppressionRules", material.SuppressionRules, "err", err)
+			return materials, err
+		}
 		exists, err := impl.ciPipelineMaterialRepository.Exists(material.Id)
 		if err != nil {
 			return materials, err
@@ -132,13 +168,110 @@ func (impl RepoManagerImpl) SavePipelineMaterial(materials []*sql.CiPipelineMate
 			oldNotDeleted = append(oldNotDeleted, material)
 		}
 	}
-	err := impl.updatePipelineMaterialCommit(append(newMaterial, oldNotDeleted...))
+	err := impl.updateFetchRefSpecs(ctx, materials)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.updatePipelineMaterialCommit(ctx, append(newMaterial, oldNotDeleted...))
 	if err != nil {
 		return nil, err
 	}
 	return materials, nil
 }
 
+// updateFetchRefSpecs recomputes refspecs of git materials of given pipeline materials from their active pipeline
+// materials, and fetches the repository again when refspecs changed so that newly tracked refs are present
+func (impl RepoManagerImpl) updateFetchRefSpecs(ctx context.Context, materials []*sql.CiPipelineMaterial) error {
+	processed := make(map[int]bool)
+	for _, pipelineMaterial := range materials {
+		gitMaterialId := pipelineMaterial.GitMaterialId
+		if processed[gitMaterialId] {
+			continue
+		}
+		processed[gitMaterialId] = true
+		activeMaterials, err := impl.ciPipelineMaterialRepository.FindByGitMaterialId(gitMaterialId)
+		if err != nil {
+			impl.logger.Errorw("error in fetching pipeline materials of git material", "gitMaterialId", gitMaterialId, "err", err)
+			return err
+		}
+		material, err := impl.materialRepository.FindById(gitMaterialId)
+		if err != nil {
+			impl.logger.Errorw("error in fetching material", "gitMaterialId", gitMaterialId, "err", err)
+			continue
+		}
+		refSpecs := git.GetFetchRefSpecs(activeMaterials)
+		if !git.RefSpecsChanged(material, refSpecs) {
+			continue
+		}
+		impl.logger.Infow("updating fetch refspecs of material", "gitMaterialId", gitMaterialId, "old", material.FetchRefSpecs, "new", refSpecs)
+		material.FetchRefSpecs = refSpecs
+		err = impl.materialRepository.UpdateFetchRefSpecs(material)
+		if err != nil {
+			impl.logger.Errorw("error in updating fetch refspecs of material", "gitMaterialId", gitMaterialId, "err", err)
+			return err
+		}
+		if material.CheckoutStatus && !material.Deleted {
+			impl.fetchMaterial(ctx, material)
+		}
+	}
+	return nil
+}
+
+// fetchMaterial fetches repository with current refspecs, failure is only logged as next poll fetches again
+func (impl RepoManagerImpl) fetchMaterial(ctx context.Context, material *sql.GitMaterial) {
+	if material.Evicted {
+		// cloned again with current refspecs
+		err := impl.useMaterial(ctx, material)
+		if err != nil {
+			impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", material.Id, "err", err)
+		}
+		return
+	}
+	repoLock := impl.locker.LeaseLocker(material.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(material.CheckoutLocation)
+	}()
+	userName, password, err := git.GetUserNamePassword(material.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", material.Id, "err", err)
+		return
+	}
+	_, _, err = impl.repositoryManager.Fetch(ctx, userName, password, material.Url, material.CheckoutLocation, impl.getCloneOptions(material, material.CheckoutLocation))
+	if err != nil {
+		impl.logger.Errorw("error in fetching material after refspec change", "gitMaterialId", material.Id, "err", err)
+	}
+}
+
+// useMaterial records use of material for order of disk eviction, and clones its repository again if it was evicted
+func (impl RepoManagerImpl) useMaterial(ctx context.Context, material *sql.GitMaterial) error {
+	if time.Since(material.LastUsedTime) > LAST_USED_TIME_UPDATE_INTERVAL {
+		material.LastUsedTime = time.Now()
+		err := impl.materialRepository.UpdateLastUsedTime(material)
+		if err != nil {
+			impl.logger.Errorw("error in updating last used time of material", "gitMaterialId", material.Id, "err", err)
+		}
+	}
+	return impl.gitWatcher.RestoreEvictedMaterial(ctx, material)
+}
+
+// getCloneOptions returns options to fetch repository of material at location, covering all materials sharing it
+func (impl RepoManagerImpl) getCloneOptions(material *sql.GitMaterial, location string) *git.CloneOptions {
+	materials, err := impl.materialRepository.FindByCheckoutLocation(location)
+	if err != nil {
+		impl.logger.Errorw("error in fetching materials sharing location", "location", location, "err", err)
+		return git.GetCloneOptions(material)
+	}
+	sharingMaterials := []*sql.GitMaterial{material}
+	for _, sharingMaterial := range materials {
+		if sharingMaterial.Id != material.Id {
+			sharingMaterials = append(sharingMaterials, sharingMaterial)
+		}
+	}
+	return git.MergeCloneOptions(sharingMaterials)
+}
+
 func (impl RepoManagerImpl) InactivateWebhookDataMappingForPipelineMaterials(oldMaterials []*sql.CiPipelineMaterial) error {
 	var ciPipelineMaterialIdsWebhookMappingDeactivate []int
 	for _, oldMaterial := range oldMaterials {
@@ -169,7 +302,7 @@ func (impl RepoManagerImpl) InactivateWebhookDataMappingForPipelineMaterials(old
 	return nil
 }
 
-func (impl RepoManagerImpl) updatePipelineMaterialCommit(materials []*sql.CiPipelineMaterial) error {
+func (impl RepoManagerImpl) updatePipelineMaterialCommit(ctx context.Context, materials []*sql.CiPipelineMaterial) error {
 	var materialCommits []*sql.CiPipelineMaterial
 	for _, pipelineMaterial := range materials {
 
@@ -185,26 +318,47 @@ func (impl RepoManagerImpl) updatePipelineMaterialCommit(materials []*sql.CiPipe
 			impl.logger.Errorw("error in fetching material", "err", err)
 			continue
 		}
+		err = impl.useMaterial(ctx, material)
+		if err != nil {
+			impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", material.Id, "err", err)
+		}
+		if pipelineMaterial.Type == sql.SOURCE_TYPE_BRANCH_REGEX {
+			err = impl.gitWatcher.SyncBranchRegexMaterial(ctx, material.CheckoutLocation, pipelineMaterial)
+			if err != nil {
+				pipelineMaterial.Errored = true
+				pipelineMaterial.ErrorMsg = err.Error()
+				pipelineMaterial.LastSeenHash = ""
+			}
+			materialCommits = append(materialCommits, pipelineMaterial)
+			continue
+		}
+		if pipelineMaterial.Type == sql.SOURCE_TYPE_TAG_ANY {
+			err = impl.gitWatcher.SyncTagMaterial(material.CheckoutLocation, pipelineMaterial)
+			if err != nil {
+				pipelineMaterial.Errored = true
+				pipelineMaterial.ErrorMsg = err.Error()
+			} else {
+				pipelineMaterial.Errored = false
+				pipelineMaterial.ErrorMsg = ""
+			}
+			materialCommits = append(materialCommits, pipelineMaterial)
+			continue
+		}
 		commits, err := impl.repositoryManager.ChangesSince(material.CheckoutLocation, pipelineMaterial.Value, "", "", 0)
 		//commits, err := impl.FetchChanges(pipelineMaterial.Id, "", "", 0)
 		if err == nil {
+			impl.repositoryManager.VerifyCommitSignatures(material.CheckoutLocation, commits, material.GitProvider)
 			impl.logger.Infow("commits found", "commit", commits)
-			b, err := json.Marshal(commits)
-			if err == nil {
-				pipelineMaterial.CommitHistory = string(b)
-				if len(commits) > 0 {
-					latestCommit := commits[0]
-					pipelineMaterial.LastSeenHash = latestCommit.Commit
-					pipelineMaterial.CommitAuthor = latestCommit.Author
-					pipelineMaterial.CommitDate = latestCommit.Date
-				}
-				pipelineMaterial.Errored = false
-				pipelineMaterial.ErrorMsg = ""
-			} else {
-				pipelineMaterial.Errored = true
-				pipelineMaterial.ErrorMsg = err.Error()
-				pipelineMaterial.LastSeenHash = ""
+			// branch of material might have changed, history is recorded afresh
+			err = impl.commitStoreService.SaveBranchCommits(material.Id, pipelineMaterial.Id, pipelineMaterial.Value, commits, true)
+		}
+		if err == nil {
+			if len(commits) > 0 {
+				pipelineMaterial.LastSeenHash = commits[0].Commit
 			}
+			pipelineMaterial.Errored = false
+			pipelineMaterial.ErrorMsg = ""
+			pipelineMaterial.State = sql.MATERIAL_STATE_NORMAL
 		} else {
 			pipelineMaterial.Errored = true
 			pipelineMaterial.ErrorMsg = err.Error()
@@ -238,9 +392,14 @@ func (impl RepoManagerImpl) SaveGitProvider(provider *sql.GitProvider) (*sql.Git
 }
 
 //handle update
-func (impl RepoManagerImpl) AddRepo(materials []*sql.GitMaterial) ([]*sql.GitMaterial, error) {
+func (impl RepoManagerImpl) AddRepo(ctx context.Context, materials []*sql.GitMaterial) ([]*sql.GitMaterial, error) {
+	err := impl.diskQuotaService.CheckHeadroom()
+	if err != nil {
+		impl.logger.Errorw("refusing to add repository", "err", err)
+		return materials, err
+	}
 	for _, material := range materials {
-		_, err := impl.addRepo(material)
+		_, err := impl.addRepo(ctx, material)
 		if err != nil {
 			impl.logger.Errorw("error in saving material ", "material", material, "err", err)
 			return materials, err
@@ -249,7 +408,7 @@ func (impl RepoManagerImpl) AddRepo(materials []*sql.GitMaterial) ([]*sql.GitMat
 	return materials, nil
 }
 
-func (impl RepoManagerImpl) UpdateRepo(material *sql.GitMaterial) (*sql.GitMaterial, error) {
+func (impl RepoManagerImpl) UpdateRepo(ctx context.Context, material *sql.GitMaterial) (*sql.GitMaterial, error) {
 	existingMaterial, err := impl.materialRepository.FindById(material.Id)
 	if err != nil {
 		impl.logger.Errorw("err", err)
@@ -261,6 +420,8 @@ func (impl RepoManagerImpl) UpdateRepo(material *sql.GitMaterial) (*sql.GitMater
 	existingMaterial.Deleted = material.Deleted
 	existingMaterial.CheckoutStatus = false
 	existingMaterial.FetchSubmodules = material.FetchSubmodules
+	existingMaterial.CloneStrategy = material.CloneStrategy
+	existingMaterial.CloneDepth = material.CloneDepth
 
 	err = impl.materialRepository.Update(existingMaterial)
 	if err != nil {
@@ -268,21 +429,14 @@ func (impl RepoManagerImpl) UpdateRepo(material *sql.GitMaterial) (*sql.GitMater
 		return nil, err
 	}
 
-	repoLock := impl.locker.LeaseLocker(material.Id)
-	repoLock.Mutex.Lock()
-	defer func() {
-		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(material.Id)
-	}()
-
-	err = impl.repositoryManager.Clean(existingMaterial.CheckoutLocation)
+	err = impl.cleanUnusedLocation(existingMaterial.CheckoutLocation)
 	if err != nil {
 		impl.logger.Errorw("err", err)
 		return nil, err
 	}
 
 	if !existingMaterial.Deleted {
-		err = impl.checkoutUpdatedRepo(material.Id)
+		err = impl.checkoutUpdatedRepo(ctx, material.Id)
 		if err != nil {
 			impl.logger.Errorw("err", err)
 			return nil, err
@@ -291,13 +445,37 @@ func (impl RepoManagerImpl) UpdateRepo(material *sql.GitMaterial) (*sql.GitMater
 	return existingMaterial, nil
 }
 
-func (impl RepoManagerImpl) checkoutUpdatedRepo(materialId int) error {
+// cleanUnusedLocation removes repository at location once no checked out material uses it, a shared object store is
+// kept as long as another material is using it
+func (impl RepoManagerImpl) cleanUnusedLocation(location string) error {
+	if len(location) == 0 {
+		return nil
+	}
+	repoLock := impl.locker.LeaseLocker(location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(location)
+	}()
+	materials, err := impl.materialRepository.FindByCheckoutLocation(location)
+	if err != nil {
+		impl.logger.Errorw("error in fetching materials sharing location", "location", location, "err", err)
+		return err
+	}
+	if len(materials) > 0 {
+		impl.logger.Infow("location still in use, not cleaning", "location", location, "materials", len(materials))
+		return nil
+	}
+	return impl.repositoryManager.Clean(location)
+}
+
+func (impl RepoManagerImpl) checkoutUpdatedRepo(ctx context.Context, materialId int) error {
 	material, err := impl.materialRepository.FindById(materialId)
 	if err != nil {
 		impl.logger.Errorw("error in fetching material", "id", materialId, "err", err)
 		return err
 	}
-	_, err = impl.checkoutMaterial(material)
+	_, err = impl.checkoutRepo(ctx, material, true)
 	if err != nil {
 		impl.logger.Errorw("error in repo refresh", "id", material, "err", err)
 		return err
@@ -305,26 +483,32 @@ func (impl RepoManagerImpl) checkoutUpdatedRepo(materialId int) error {
 	return nil
 }
 
-func (impl RepoManagerImpl) addRepo(material *sql.GitMaterial) (*sql.GitMaterial, error) {
+func (impl RepoManagerImpl) addRepo(ctx context.Context, material *sql.GitMaterial) (*sql.GitMaterial, error) {
 	err := impl.materialRepository.Save(material)
 	if err != nil {
 		impl.logger.Errorw("error in saving material ", "material", material, "err", err)
 		return material, err
 	}
-	return impl.checkoutRepo(material)
+	return impl.checkoutRepo(ctx, material, true)
 }
 
-func (impl RepoManagerImpl) checkoutRepo(material *sql.GitMaterial) (*sql.GitMaterial, error) {
-	repoLock := impl.locker.LeaseLocker(material.Id)
+// checkoutRepo checks out material into object store for its remote, an existing store is reused when reuseStore is
+// set and cloned again otherwise
+func (impl RepoManagerImpl) checkoutRepo(ctx context.Context, material *sql.GitMaterial, reuseStore bool) (*sql.GitMaterial, error) {
+	checkoutPath, err := git.GetLocationForMaterial(material)
+	if err != nil {
+		return material, err
+	}
+	repoLock := impl.locker.LeaseLocker(checkoutPath)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(material.Id)
+		impl.locker.ReturnLocker(checkoutPath)
 	}()
-	return impl.checkoutMaterial(material)
+	return impl.checkoutMaterial(ctx, material, reuseStore)
 }
 
-func (impl RepoManagerImpl) checkoutMaterial(material *sql.GitMaterial) (*sql.GitMaterial, error) {
+func (impl RepoManagerImpl) checkoutMaterial(ctx context.Context, material *sql.GitMaterial, reuseStore bool) (*sql.GitMaterial, error) {
 	impl.logger.Infow("checking out material", "id", material.Id)
 	gitProvider, err := impl.gitProviderRepository.GetById(material.GitProviderId)
 	if err != nil {
@@ -338,7 +522,12 @@ func (impl RepoManagerImpl) checkoutMaterial(material *sql.GitMaterial) (*sql.Gi
 	if err != nil {
 		return material, err
 	}
-	err = impl.repositoryManager.Add(material.GitProviderId, checkoutPath, material.Url, userName, password, gitProvider.AuthMode, gitProvider.SshPrivateKey)
+	if reuseStore && impl.repositoryManager.IsRepository(checkoutPath) {
+		impl.logger.Infow("reusing shared store for material", "id", material.Id, "location", checkoutPath)
+		_, _, err = impl.repositoryManager.Fetch(ctx, userName, password, material.Url, checkoutPath, impl.getCloneOptions(material, checkoutPath))
+	} else {
+		err = impl.repositoryManager.Add(ctx, material.GitProviderId, checkoutPath, material.Url, userName, password, gitProvider.AuthMode, gitProvider.SshPrivateKey, impl.getCloneOptions(material, checkoutPath))
+	}
 	if err == nil {
 		material.CheckoutLocation = checkoutPath
 		material.CheckoutStatus = true
@@ -352,37 +541,72 @@ func (impl RepoManagerImpl) checkoutMaterial(material *sql.GitMaterial) (*sql.Gi
 		impl.logger.Errorw("error in updating material repo", "err", err, "material", material)
 		return nil, err
 	}
+	if material.CheckoutStatus {
+		// repository is on disk again for all materials sharing it
+		err = impl.materialRepository.UpdateEvictedByLocation(checkoutPath, false)
+		if err != nil {
+			impl.logger.Errorw("error in updating evicted status", "location", checkoutPath, "err", err)
+			return nil, err
+		}
+		material.Evicted = false
+	}
 	ciPipelineMaterial, err := impl.ciPipelineMaterialRepository.FindByGitMaterialId(material.Id)
 	if err != nil {
 		impl.logger.Errorw("unable to load material", "err", err)
 		return nil, err
 	}
-	err = impl.updatePipelineMaterialCommit(ciPipelineMaterial)
+	err = impl.updatePipelineMaterialCommit(ctx, ciPipelineMaterial)
 	if err != nil {
 		impl.logger.Errorw("error in updating pipeline material", "err", err)
 	}
 	return material, nil
 }
 
+// ReloadAllRepo clones every repository again, once per location under its lock, materials sharing the location reuse
+// the store cloned for the first of them
 func (impl RepoManagerImpl) ReloadAllRepo() {
 	materials, err := impl.materialRepository.FindAll()
 	if err != nil {
 		impl.logger.Errorw("error in reloading materials")
 	}
+	var locations []string
+	materialsByLocation := make(map[string][]*sql.GitMaterial)
 	for _, material := range materials {
-		if _, err := impl.checkoutRepo(material); err != nil {
-			impl.logger.Errorw("error in checkout", "material", material, "err", err)
+		checkoutPath, err := git.GetLocationForMaterial(material)
+		if err != nil {
+			impl.logger.Errorw("error in getting location of material", "material", material, "err", err)
+			continue
 		}
+		if _, ok := materialsByLocation[checkoutPath]; !ok {
+			locations = append(locations, checkoutPath)
+		}
+		materialsByLocation[checkoutPath] = append(materialsByLocation[checkoutPath], material)
+	}
+	for _, location := range locations {
+		impl.reloadLocation(location, materialsByLocation[location])
+	}
+}
 
+func (impl RepoManagerImpl) reloadLocation(location string, materials []*sql.GitMaterial) {
+	repoLock := impl.locker.LeaseLocker(location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(location)
+	}()
+	for i, material := range materials {
+		if _, err := impl.checkoutMaterial(context.Background(), material, i > 0); err != nil {
+			impl.logger.Errorw("error in checkout", "material", material, "err", err)
+		}
 	}
 }
-func (impl RepoManagerImpl) ResetRepo(materialId int) error {
+func (impl RepoManagerImpl) ResetRepo(ctx context.Context, materialId int) error {
 	material, err := impl.materialRepository.FindById(materialId)
 	if err != nil {
 		impl.logger.Errorw("error in fetching material", "id", materialId, "err", err)
 		return err
 	}
-	_, err = impl.checkoutRepo(material)
+	_, err = impl.checkoutRepo(ctx, material, false)
 	if err != nil {
 		impl.logger.Errorw("error in repo refresh", "id", material, "err", err)
 		return err
@@ -392,14 +616,32 @@ func (impl RepoManagerImpl) ResetRepo(materialId int) error {
 
 func (impl RepoManagerImpl) GetHeadForPipelineMaterials(ids []int) (materialBeans []*git.CiPipelineMaterialBean, err error) {
 	materials, err := impl.ciPipelineMaterialRepository.FindByIds(ids)
+	if err != nil {
+		return materialBeans, err
+	}
+	// heads are looked up once per git material
+	headsByGitMaterialId := make(map[int][]string)
+	for _, material := range materials {
+		if len(material.LastSeenHash) > 0 {
+			headsByGitMaterialId[material.GitMaterialId] = append(headsByGitMaterialId[material.GitMaterialId], material.LastSeenHash)
+		}
+	}
+	headCommits := make(map[int]map[string]*git.GitCommit)
+	for gitMaterialId, heads := range headsByGitMaterialId {
+		headCommits[gitMaterialId], err = impl.commitStoreService.GetCommits(gitMaterialId, heads)
+		if err != nil {
+			impl.logger.Errorw("error in getting heads of materials", "gitMaterialId", gitMaterialId, "err", err)
+			return materialBeans, err
+		}
+	}
 	for _, material := range materials {
-		materialBean := impl.materialTOMaterialBeanConverter(material)
+		materialBean := impl.materialTOMaterialBeanConverter(material, headCommits[material.GitMaterialId][material.LastSeenHash])
 		materialBeans = append(materialBeans, materialBean)
 	}
 	return materialBeans, err
 }
 
-func (impl RepoManagerImpl) materialTOMaterialBeanConverter(material *sql.CiPipelineMaterial) *git.CiPipelineMaterialBean {
+func (impl RepoManagerImpl) materialTOMaterialBeanConverter(material *sql.CiPipelineMaterial, headCommit *git.GitCommit) *git.CiPipelineMaterialBean {
 	materialBean := &git.CiPipelineMaterialBean{
 		Id:            material.Id,
 		Type:          material.Type,
@@ -408,15 +650,17 @@ func (impl RepoManagerImpl) materialTOMaterialBeanConverter(material *sql.CiPipe
 		Active:        material.Active,
 		GitCommit: &git.GitCommit{
 			Commit: material.LastSeenHash,
-			Author: material.CommitAuthor,
-			Date:   material.CommitDate,
 		},
 	}
+	if headCommit != nil {
+		materialBean.GitCommit.Author = headCommit.Author
+		materialBean.GitCommit.Date = headCommit.Date
+	}
 	return materialBean
 }
 
-func (impl RepoManagerImpl) FetchChanges(pipelineMaterialId int, from string, to string, count int) (*git.MaterialChangeResp, error) {
-	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(pipelineMaterialId)
+func (impl RepoManagerImpl) FetchChanges(ctx context.Context, request *git.FetchScmChangesRequest) (*git.MaterialChangeResp, error) {
+	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(request.PipelineMaterialId)
 	if err != nil {
 		return nil, err
 	}
@@ -424,11 +668,20 @@ func (impl RepoManagerImpl) FetchChanges(pipelineMaterialId int, from string, to
 	if err != nil {
 		return nil, err
 	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
 
 	pipelineMaterialType := pipelineMaterial.Type
 
 	if pipelineMaterialType == sql.SOURCE_TYPE_BRANCH_FIXED {
-		return impl.FetchGitCommitsForBranchFixPipeline(pipelineMaterial, gitMaterial)
+		return impl.FetchGitCommitsForBranchFixPipeline(pipelineMaterial, gitMaterial, request)
+	} else if pipelineMaterialType == sql.SOURCE_TYPE_BRANCH_REGEX {
+		return impl.FetchGitCommitsForBranchRegexPipeline(pipelineMaterial, gitMaterial)
+	} else if pipelineMaterialType == sql.SOURCE_TYPE_TAG_ANY {
+		return impl.FetchGitCommitsForTagAnyPipeline(pipelineMaterial, gitMaterial)
 	} else if pipelineMaterialType == sql.SOURCE_TYPE_WEBHOOK {
 		return impl.FetchGitCommitsForWebhookTypePipeline(pipelineMaterial, gitMaterial)
 	} else {
@@ -438,7 +691,7 @@ func (impl RepoManagerImpl) FetchChanges(pipelineMaterialId int, from string, to
 	return nil, err
 }
 
-func (impl RepoManagerImpl) FetchGitCommitsForBranchFixPipeline(pipelineMaterial *sql.CiPipelineMaterial, gitMaterial *sql.GitMaterial) (*git.MaterialChangeResp, error) {
+func (impl RepoManagerImpl) FetchGitCommitsForBranchFixPipeline(pipelineMaterial *sql.CiPipelineMaterial, gitMaterial *sql.GitMaterial, request *git.FetchScmChangesRequest) (*git.MaterialChangeResp, error) {
 	response := &git.MaterialChangeResp{}
 	response.LastFetchTime = gitMaterial.LastFetchTime
 	if pipelineMaterial.Errored {
@@ -453,11 +706,166 @@ func (impl RepoManagerImpl) FetchGitCommitsForBranchFixPipeline(pipelineMaterial
 
 		return response, nil
 	}
-	commits := make([]*git.GitCommit, 0)
-	err := json.Unmarshal([]byte(pipelineMaterial.CommitHistory), &commits)
+	response.MaterialState = pipelineMaterial.State
+	if git.IsStoredHistoryRequest(request) {
+		var err error
+		response.Commits, response.NextCursor, err = impl.getStoredHistoryPage(gitMaterial, pipelineMaterial, request)
+		if err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	var err error
+	response.Commits, response.NextCursor, err = impl.repositoryManager.GetCommitHistory(gitMaterial.CheckoutLocation, pipelineMaterial.Value, request)
+	if err != nil {
+		impl.logger.Errorw("error in getting commit history", "pipelineMaterialId", pipelineMaterial.Id, "err", err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// getStoredHistoryPage returns a page of stored history of branch. Stored history is extended with older commits of
+// branch from repository once a page reaches its end, so that all pages are in the same order
+func (impl RepoManagerImpl) getStoredHistoryPage(gitMaterial *sql.GitMaterial, pipelineMaterial *sql.CiPipelineMaterial, request *git.FetchScmChangesRequest) ([]*git.GitCommit, string, error) {
+	cursor, err := git.ParseStoredHistoryCursor(request.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	size := git.GetCommitPageSize(request.Count)
+	commits, nextCursor, err := impl.commitStoreService.GetBranchHistoryPage(gitMaterial.Id, gitMaterial.CheckoutLocation, pipelineMaterial.Id, pipelineMaterial.Value, cursor, size)
+	if err != nil {
+		impl.logger.Errorw("error in getting stored history", "pipelineMaterialId", pipelineMaterial.Id, "err", err)
+		return nil, "", err
+	}
+	if nextCursor == nil {
+		// one more commit than missing in page is stored to know if there is a next page
+		added, err := impl.extendStoredHistory(gitMaterial, pipelineMaterial, size-len(commits)+1)
+		if err != nil {
+			return nil, "", err
+		}
+		if added > 0 {
+			commits, nextCursor, err = impl.commitStoreService.GetBranchHistoryPage(gitMaterial.Id, gitMaterial.CheckoutLocation, pipelineMaterial.Id, pipelineMaterial.Value, cursor, size)
+			if err != nil {
+				impl.logger.Errorw("error in getting stored history", "pipelineMaterialId", pipelineMaterial.Id, "err", err)
+				return nil, "", err
+			}
+		}
+	}
+	if nextCursor == nil {
+		return commits, "", nil
+	}
+	return commits, nextCursor.String(), nil
+}
+
+// extendStoredHistory stores up to count commits of branch not stored yet below the oldest stored commit, in log order
+// of repository. Returns number of commits stored, 0 once stored history has all commits of branch
+func (impl RepoManagerImpl) extendStoredHistory(gitMaterial *sql.GitMaterial, pipelineMaterial *sql.CiPipelineMaterial, count int) (int, error) {
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	isStored := func(commitHashes []string) (map[string]bool, error) {
+		return impl.commitStoreService.GetStoredBranchCommits(pipelineMaterial.Id, pipelineMaterial.Value, commitHashes)
+	}
+	commits, err := impl.repositoryManager.GetUnstoredCommits(gitMaterial.CheckoutLocation, pipelineMaterial.Value, isStored, count)
+	if _, ok := err.(*git.BranchNotFoundError); ok {
+		// stored history of deleted branch is all there is
+		return 0, nil
+	} else if err != nil {
+		impl.logger.Errorw("error in getting older history", "pipelineMaterialId", pipelineMaterial.Id, "err", err)
+		return 0, err
+	}
+	err = impl.commitStoreService.SaveOlderBranchCommits(gitMaterial.Id, pipelineMaterial.Id, pipelineMaterial.Value, commits)
+	if err != nil {
+		return 0, err
+	}
+	return len(commits), nil
+}
+
+func (impl RepoManagerImpl) FetchGitCommitsForBranchRegexPipeline(pipelineMaterial *sql.CiPipelineMaterial, gitMaterial *sql.GitMaterial) (*git.MaterialChangeResp, error) {
+	response := &git.MaterialChangeResp{}
+	response.LastFetchTime = gitMaterial.LastFetchTime
+	if pipelineMaterial.Errored {
+		impl.logger.Infow("errored material ", "id", pipelineMaterial.Id, "errMsg", pipelineMaterial.ErrorMsg)
+		if !gitMaterial.CheckoutStatus {
+			response.IsRepoError = true
+			response.RepoErrorMsg = gitMaterial.FetchErrorMessage
+		} else {
+			response.IsBranchError = true
+			response.BranchErrorMsg = pipelineMaterial.ErrorMsg
+		}
+		return response, nil
+	}
+	branches, err := impl.ciPipelineMaterialBranchRepository.FindActiveByCiPipelineMaterialId(pipelineMaterial.Id)
 	if err != nil {
+		impl.logger.Errorw("error in getting branches of material", "id", pipelineMaterial.Id, "err", err)
 		return nil, err
 	}
+	branchCommits := make([]*git.BranchCommits, 0)
+	for _, branch := range branches {
+		commits, err := impl.commitStoreService.GetBranchCommits(gitMaterial.Id, gitMaterial.CheckoutLocation, pipelineMaterial.Id, branch.BranchName, git.COMMIT_HISTORY_CACHE_SIZE)
+		if err != nil {
+			impl.logger.Errorw("error in getting commit history", "id", pipelineMaterial.Id, "branch", branch.BranchName, "err", err)
+			return nil, err
+		}
+		branchCommits = append(branchCommits, &git.BranchCommits{Branch: branch.BranchName, Commits: commits})
+	}
+	// branches are sorted by recency of their head commit
+	sort.SliceStable(branchCommits, func(i, j int) bool {
+		return branchHeadDate(branchCommits[i]).After(branchHeadDate(branchCommits[j]))
+	})
+	response.BranchCommits = branchCommits
+	return response, nil
+}
+
+func (impl RepoManagerImpl) FetchGitCommitsForTagAnyPipeline(pipelineMaterial *sql.CiPipelineMaterial, gitMaterial *sql.GitMaterial) (*git.MaterialChangeResp, error) {
+	response := &git.MaterialChangeResp{}
+	response.LastFetchTime = gitMaterial.LastFetchTime
+	if pipelineMaterial.Errored {
+		impl.logger.Infow("errored material ", "id", pipelineMaterial.Id, "errMsg", pipelineMaterial.ErrorMsg)
+		if !gitMaterial.CheckoutStatus {
+			response.IsRepoError = true
+			response.RepoErrorMsg = gitMaterial.FetchErrorMessage
+		} else {
+			response.IsBranchError = true
+			response.BranchErrorMsg = pipelineMaterial.ErrorMsg
+		}
+		return response, nil
+	}
+	tagFilter, err := git.NewTagFilter(pipelineMaterial.Value)
+	if err != nil {
+		return nil, err
+	}
+	// tags are sorted by recency of their commit
+	tags, err := impl.gitMaterialTagRepository.FindByGitMaterialId(gitMaterial.Id)
+	if err != nil {
+		impl.logger.Errorw("error in getting tags of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	commits := make([]*git.GitCommit, 0)
+	for _, tag := range tags {
+		if len(commits) == 15 {
+			break
+		}
+		if !tagFilter.Matches(tag.TagName) {
+			continue
+		}
+		commits = append(commits, &git.GitCommit{
+			Commit:  tag.CommitHash,
+			Author:  tag.CommitAuthor,
+			Date:    tag.CommitDate,
+			Message: tag.CommitMessage,
+			Tag:     tag.TagName,
+		})
+	}
 	response.Commits = commits
 	return response, nil
 }
@@ -517,7 +925,7 @@ func (impl RepoManagerImpl) FetchGitCommitsForWebhookTypePipeline(pipelineMateri
 	return response, nil
 }
 
-func (impl RepoManagerImpl) GetCommitInfoForTag(request *git.CommitMetadataRequest) (*git.GitCommit, error) {
+func (impl RepoManagerImpl) GetCommitInfoForTag(ctx context.Context, request *git.CommitMetadataRequest) (*git.GitCommit, error) {
 	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(request.PipelineMaterialId)
 	if err != nil {
 		return nil, err
@@ -529,25 +937,34 @@ func (impl RepoManagerImpl) GetCommitInfoForTag(request *git.CommitMetadataReque
 	if !gitMaterial.CheckoutStatus {
 		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
 	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
 	//refresh repo. and notify all pending
 	//lock inside watcher itself
-	_, err = impl.gitWatcher.PollAndUpdateGitMaterial(gitMaterial)
+	gitMaterial, err = impl.gitWatcher.PollAndUpdateGitMaterial(ctx, gitMaterial)
 	if err != nil {
 		impl.logger.Infow("error in refreshing repo", "req", request, "err", err)
 		return nil, err
 	}
 	//lock for getting commit
-	repoLock := impl.locker.LeaseLocker(gitMaterial.Id)
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(gitMaterial.Id)
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
 	}()
 	commit, err := impl.repositoryManager.GetCommitForTag(gitMaterial.CheckoutLocation, request.GitTag)
-	return commit, err
+	if err != nil {
+		return nil, err
+	}
+	impl.repositoryManager.VerifyCommitSignatures(gitMaterial.CheckoutLocation, []*git.GitCommit{commit}, gitMaterial.GitProvider)
+	return commit, nil
 }
 
-func (impl RepoManagerImpl) GetCommitMetadata(pipelineMaterialId int, gitHash string) (*git.GitCommit, error) {
+func (impl RepoManagerImpl) GetCommitMetadata(ctx context.Context, pipelineMaterialId int, gitHash string) (*git.GitCommit, error) {
 	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(pipelineMaterialId)
 	if err != nil {
 		return nil, err
@@ -559,17 +976,49 @@ func (impl RepoManagerImpl) GetCommitMetadata(pipelineMaterialId int, gitHash st
 	if !gitMaterial.CheckoutStatus {
 		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
 	}
-	repoLock := impl.locker.LeaseLocker(gitMaterial.Id)
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(gitMaterial.Id)
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
 	}()
-	commit, err := impl.repositoryManager.GetCommitMetadata(gitMaterial.CheckoutLocation, gitHash)
-	return commit, err
+	commit, err := impl.getStoredCommit(gitMaterial.Id, gitMaterial.CheckoutLocation, gitHash)
+	if err != nil {
+		return nil, err
+	}
+	if commit == nil {
+		commit, err = impl.repositoryManager.GetCommitMetadata(gitMaterial.CheckoutLocation, gitHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	impl.repositoryManager.VerifyCommitSignatures(gitMaterial.CheckoutLocation, []*git.GitCommit{commit}, gitMaterial.GitProvider)
+	return commit, nil
+}
+
+// getStoredCommit returns commit seen by watcher if its stats are complete, nil otherwise i.e. commits whose stats are
+// not computed yet are read from repository again
+func (impl RepoManagerImpl) getStoredCommit(gitMaterialId int, location string, gitHash string) (*git.GitCommit, error) {
+	commit, err := impl.commitStoreService.GetCommit(gitMaterialId, location, gitHash)
+	if err != nil || commit == nil || commit.StatsStatus != git.COMMIT_STATS_STATUS_COMPLETE {
+		return nil, err
+	}
+	return commit, nil
+}
+
+func branchHeadDate(branchCommits *git.BranchCommits) time.Time {
+	if len(branchCommits.Commits) == 0 {
+		return time.Time{}
+	}
+	return branchCommits.Commits[0].Date
 }
 
-func (impl RepoManagerImpl) GetLatestCommitForBranch(pipelineMaterialId int, branchName string) (*git.GitCommit, error) {
+func (impl RepoManagerImpl) GetLatestCommitForBranch(ctx context.Context, pipelineMaterialId int, branchName string) (*git.GitCommit, error) {
 	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(pipelineMaterialId)
 
 	if err != nil {
@@ -586,16 +1035,21 @@ func (impl RepoManagerImpl) GetLatestCommitForBranch(pipelineMaterialId int, bra
 	if !gitMaterial.CheckoutStatus {
 		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
 	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
 
-	repoLock := impl.locker.LeaseLocker(gitMaterial.Id)
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(gitMaterial.Id)
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
 	}()
 
 	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
-	updated, repo, err := impl.repositoryManager.Fetch(userName, password, gitMaterial.Url, gitMaterial.CheckoutLocation)
+	updated, _, err := impl.repositoryManager.Fetch(ctx, userName, password, gitMaterial.Url, gitMaterial.CheckoutLocation, impl.getCloneOptions(gitMaterial, gitMaterial.CheckoutLocation))
 
 	if err != nil {
 		impl.logger.Errorw("error in fetching the repository ", "err", err)
@@ -604,12 +1058,14 @@ func (impl RepoManagerImpl) GetLatestCommitForBranch(pipelineMaterialId int, bra
 	if !updated {
 		impl.logger.Warn("repository is up to date")
 	}
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, branchName)
 	if err != nil {
 		impl.logger.Errorw("error in fetching the repository ", "err", err)
 		return nil, err
 	}
 
-	commits, err := impl.repositoryManager.ChangesSinceByRepository(repo, branchName, "", "", 1)
+	commits, err := impl.repositoryManager.ChangesSince(gitMaterial.CheckoutLocation, branchName, "", "", 1)
+	impl.repositoryManager.VerifyCommitSignatures(gitMaterial.CheckoutLocation, commits, gitMaterial.GitProvider)
 
 	if commits == nil {
 		return nil, err
@@ -618,7 +1074,7 @@ func (impl RepoManagerImpl) GetLatestCommitForBranch(pipelineMaterialId int, bra
 	}
 }
 
-func (impl RepoManagerImpl) GetCommitMetadataForPipelineMaterial(pipelineMaterialId int, gitHash string) (*git.GitCommit, error) {
+func (impl RepoManagerImpl) GetCommitMetadataForPipelineMaterial(ctx context.Context, pipelineMaterialId int, gitHash string) (*git.GitCommit, error) {
 	// fetch ciPipelineMaterial
 	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(pipelineMaterialId)
 	if err != nil {
@@ -630,6 +1086,12 @@ func (impl RepoManagerImpl) GetCommitMetadataForPipelineMaterial(pipelineMateria
 	if pipelineMaterial.Type == sql.SOURCE_TYPE_WEBHOOK {
 		return nil, errors.New("fetching commit info is not supported for webhook based ci pipeline material")
 	}
+	if pipelineMaterial.Type == sql.SOURCE_TYPE_BRANCH_REGEX {
+		return nil, errors.New("fetching commit info is not supported for branch regex based ci pipeline material")
+	}
+	if pipelineMaterial.Type == sql.SOURCE_TYPE_TAG_ANY {
+		return nil, errors.New("fetching commit info is not supported for tag based ci pipeline material")
+	}
 	branchName := pipelineMaterial.Value
 	if len(branchName) == 0 {
 		impl.logger.Errorw("branch name is empty", "pipelineMaterialId", pipelineMaterialId)
@@ -649,15 +1111,30 @@ func (impl RepoManagerImpl) GetCommitMetadataForPipelineMaterial(pipelineMateria
 		impl.logger.Errorw("checkout not success", "gitMaterialId", gitMaterialId)
 		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
 	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
 
 	// lock-unlock
-	repoLock := impl.locker.LeaseLocker(gitMaterial.Id)
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(gitMaterial.Id)
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
 	}()
 
+	commit, err := impl.commitStoreService.GetBranchCommit(gitMaterial.Id, gitMaterial.CheckoutLocation, pipelineMaterialId, branchName, gitHash)
+	if err != nil {
+		impl.logger.Errorw("error while fetching stored commit info", "pipelineMaterialId", pipelineMaterialId, "gitHash", gitHash, "err", err)
+		return nil, err
+	}
+	if commit != nil && commit.StatsStatus == git.COMMIT_STATS_STATUS_COMPLETE {
+		impl.repositoryManager.VerifyCommitSignatures(gitMaterial.CheckoutLocation, []*git.GitCommit{commit}, gitMaterial.GitProvider)
+		return commit, nil
+	}
+
 	commits, err := impl.repositoryManager.ChangesSince(gitMaterial.CheckoutLocation, branchName, "", gitHash, 1)
 	if err != nil {
 		impl.logger.Errorw("error while fetching commit info", "pipelineMaterialId", pipelineMaterialId, "gitHash", gitHash, "err", err)
@@ -673,50 +1150,361 @@ func (impl RepoManagerImpl) GetCommitMetadataForPipelineMaterial(pipelineMateria
 		impl.logger.Errorw("no commits found", "commitHash", gitHash, "pipelineMaterialId", pipelineMaterialId, "branch", branchName)
 		return nil, nil
 	}
+	impl.repositoryManager.VerifyCommitSignatures(gitMaterial.CheckoutLocation, commits, gitMaterial.GitProvider)
 
 	return commits[0], err
 }
 
-func (impl RepoManagerImpl) GetReleaseChanges(request *ReleaseChangesRequest) (*git.GitChanges, error) {
+func (impl RepoManagerImpl) GetReleaseChanges(ctx context.Context, request *ReleaseChangesRequest) (*git.GitChanges, error) {
+	_, gitChanges, err := impl.getReleaseChanges(ctx, request)
+	return gitChanges, err
+}
+
+// getReleaseChanges computes changes of release under lock of repository, git material is returned once it is found
+// even if changes could not be computed
+func (impl RepoManagerImpl) getReleaseChanges(ctx context.Context, request *ReleaseChangesRequest) (*sql.GitMaterial, *git.GitChanges, error) {
 	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(request.PipelineMaterialId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	gitMaterial, err := impl.materialRepository.FindById(pipelineMaterial.GitMaterialId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if !gitMaterial.CheckoutStatus {
-		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
+		return gitMaterial, nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
+	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return gitMaterial, nil, err
 	}
-	repoLock := impl.locker.LeaseLocker(gitMaterial.Id)
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(gitMaterial.Id)
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
 	}()
-	gitChanges, err := impl.repositoryManager.ChangesSinceByRepositoryForAnalytics(gitMaterial.CheckoutLocation, pipelineMaterial.Value, request.OldCommit, request.NewCommit)
+	gitChanges, err := impl.repositoryManager.ChangesSinceByRepositoryForAnalytics(ctx, gitMaterial.CheckoutLocation, pipelineMaterial.Value, request.OldCommit, request.NewCommit)
 	if err != nil {
 		impl.logger.Errorw("error in computing changes", "req", request, "err", err)
-	} else {
-		impl.logger.Infow("commits found for ", "req", request, "commits", len(gitChanges.Commits))
+		return gitMaterial, gitChanges, err
+	}
+	impl.logger.Infow("commits found for ", "req", request, "commits", len(gitChanges.Commits))
+	if request.ReleaseNotes {
+		gitChanges.ReleaseNotes, err = git.BuildReleaseNotes(gitChanges.Commits, request.IssueKeyPattern)
+		if err != nil {
+			impl.logger.Errorw("error in building release notes", "req", request, "err", err)
+			return gitMaterial, nil, err
+		}
 	}
+	return gitMaterial, gitChanges, nil
+}
 
-	return gitChanges, err
+func (impl RepoManagerImpl) GetFileDiff(ctx context.Context, request *git.FileDiffRequest) (*git.FileDiffResponse, error) {
+	if len(request.OldRef) == 0 {
+		return nil, errors.New("old ref is required")
+	}
+	pipelineMaterial, gitMaterial, err := impl.getMaterialForRead(ctx, request.PipelineMaterialId)
+	if err != nil {
+		return nil, err
+	}
+	request.NewRef, err = getRefOrBranch(pipelineMaterial, request.NewRef)
+	if err != nil {
+		return nil, err
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, request.OldRef, request.NewRef)
+	if err != nil {
+		return nil, err
+	}
+	return impl.repositoryManager.GetFileDiff(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+}
+
+func (impl RepoManagerImpl) GetTree(ctx context.Context, request *git.TreeRequest) (*git.TreeResponse, error) {
+	pipelineMaterial, gitMaterial, err := impl.getMaterialForRead(ctx, request.PipelineMaterialId)
+	if err != nil {
+		return nil, err
+	}
+	request.Ref, err = getRefOrBranch(pipelineMaterial, request.Ref)
+	if err != nil {
+		return nil, err
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, request.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return impl.repositoryManager.GetTree(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+}
+
+func (impl RepoManagerImpl) GetFileContent(ctx context.Context, request *git.FileContentRequest) (*git.FileContentResponse, error) {
+	pipelineMaterial, gitMaterial, err := impl.getMaterialForRead(ctx, request.PipelineMaterialId)
+	if err != nil {
+		return nil, err
+	}
+	request.Ref, err = getRefOrBranch(pipelineMaterial, request.Ref)
+	if err != nil {
+		return nil, err
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, request.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return impl.repositoryManager.GetFileContent(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+}
+
+func (impl RepoManagerImpl) ListBranches(ctx context.Context, request *git.BranchListRequest) (*git.BranchListResponse, error) {
+	gitMaterial, err := impl.getGitMaterialForListing(ctx, request.GitMaterialId, request.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	response, err := impl.repositoryManager.ListBranches(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+	if err != nil {
+		impl.logger.Errorw("error in listing branches", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	response.LastFetchTime = gitMaterial.LastFetchTime
+	return response, nil
+}
+
+func (impl RepoManagerImpl) ListTags(ctx context.Context, request *git.TagListRequest) (*git.TagListResponse, error) {
+	gitMaterial, err := impl.getGitMaterialForListing(ctx, request.GitMaterialId, request.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	response, err := impl.repositoryManager.ListTags(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+	if err != nil {
+		impl.logger.Errorw("error in listing tags", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	response.LastFetchTime = gitMaterial.LastFetchTime
+	return response, nil
+}
+
+func (impl RepoManagerImpl) SearchCommits(ctx context.Context, request *git.CommitSearchRequest) (*git.CommitSearchResponse, error) {
+	pipelineMaterial, gitMaterial, err := impl.getPipelineOrGitMaterialForRead(ctx, request.PipelineMaterialId, request.GitMaterialId)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Branch) == 0 && pipelineMaterial != nil && pipelineMaterial.Type == sql.SOURCE_TYPE_BRANCH_FIXED {
+		request.Branch = pipelineMaterial.Value
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, request.Branch)
+	if err != nil {
+		return nil, err
+	}
+	response, err := impl.repositoryManager.SearchCommits(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+	if err != nil {
+		impl.logger.Errorw("error in searching commits", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	response.LastFetchTime = gitMaterial.LastFetchTime
+	return response, nil
+}
+
+func (impl RepoManagerImpl) CompareRefs(ctx context.Context, request *git.CompareRefsRequest) (*git.CompareRefsResponse, error) {
+	if len(request.BaseRef) == 0 {
+		return nil, errors.New("base ref is required")
+	}
+	pipelineMaterial, gitMaterial, err := impl.getPipelineOrGitMaterialForRead(ctx, request.PipelineMaterialId, request.GitMaterialId)
+	if err != nil {
+		return nil, err
+	}
+	if pipelineMaterial != nil {
+		request.HeadRef, err = getRefOrBranch(pipelineMaterial, request.HeadRef)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(request.HeadRef) == 0 {
+		return nil, errors.New("head ref is required")
+	}
+	userName, password, err := git.GetUserNamePassword(gitMaterial.GitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials of material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	repoLock := impl.locker.LeaseLocker(gitMaterial.CheckoutLocation)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(gitMaterial.CheckoutLocation)
+	}()
+	err = impl.fetchRequestedBranches(ctx, gitMaterial, userName, password, request.BaseRef, request.HeadRef)
+	if err != nil {
+		return nil, err
+	}
+	response, err := impl.repositoryManager.CompareRefs(ctx, gitMaterial.CheckoutLocation, userName, password, request)
+	if err != nil {
+		impl.logger.Errorw("error in comparing refs", "gitMaterialId", gitMaterial.Id, "baseRef", request.BaseRef, "headRef", request.HeadRef, "err", err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// fetchRequestedBranches fetches requested refs which are branches outside of refspecs fetched for pipeline materials
+// of repository, caller holds lock of checkout location
+func (impl RepoManagerImpl) fetchRequestedBranches(ctx context.Context, gitMaterial *sql.GitMaterial, userName, password string, refs ...string) error {
+	err := impl.repositoryManager.FetchBranches(ctx, userName, password, gitMaterial.CheckoutLocation, impl.getCloneOptions(gitMaterial, gitMaterial.CheckoutLocation), refs...)
+	if err != nil {
+		impl.logger.Errorw("error in fetching requested branches", "gitMaterialId", gitMaterial.Id, "refs", refs, "err", err)
+	}
+	return err
+}
+
+// getPipelineOrGitMaterialForRead loads material of request scoped to either a pipeline material or a git material,
+// pipeline material is nil for the latter
+func (impl RepoManagerImpl) getPipelineOrGitMaterialForRead(ctx context.Context, pipelineMaterialId int, gitMaterialId int) (*sql.CiPipelineMaterial, *sql.GitMaterial, error) {
+	if pipelineMaterialId > 0 {
+		return impl.getMaterialForRead(ctx, pipelineMaterialId)
+	} else if gitMaterialId > 0 {
+		gitMaterial, err := impl.getGitMaterialForListing(ctx, gitMaterialId, false)
+		return nil, gitMaterial, err
+	}
+	return nil, nil, errors.New("pipeline material or git material is required")
+}
+
+// getGitMaterialForListing loads checked out git material, fetching it first if refresh is asked
+func (impl RepoManagerImpl) getGitMaterialForListing(ctx context.Context, gitMaterialId int, refresh bool) (*sql.GitMaterial, error) {
+	gitMaterial, err := impl.materialRepository.FindById(gitMaterialId)
+	if err != nil {
+		impl.logger.Errorw("error in getting material", "gitMaterialId", gitMaterialId, "err", err)
+		return nil, err
+	}
+	if !gitMaterial.CheckoutStatus {
+		return nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
+	}
+	if refresh {
+		//lock inside watcher itself
+		gitMaterial, err = impl.gitWatcher.PollAndUpdateGitMaterial(ctx, gitMaterial)
+		if err != nil {
+			impl.logger.Errorw("error in refreshing material", "gitMaterialId", gitMaterialId, "err", err)
+			return nil, err
+		}
+	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, err
+	}
+	return gitMaterial, nil
+}
+
+// getMaterialForRead loads pipeline material and its checked out git material, whose repository is cloned again if
+// it was evicted
+func (impl RepoManagerImpl) getMaterialForRead(ctx context.Context, pipelineMaterialId int) (*sql.CiPipelineMaterial, *sql.GitMaterial, error) {
+	pipelineMaterial, err := impl.ciPipelineMaterialRepository.FindById(pipelineMaterialId)
+	if err != nil {
+		impl.logger.Errorw("error in getting pipeline material", "pipelineMaterialId", pipelineMaterialId, "err", err)
+		return nil, nil, err
+	}
+	gitMaterial, err := impl.materialRepository.FindById(pipelineMaterial.GitMaterialId)
+	if err != nil {
+		impl.logger.Errorw("error in getting material", "gitMaterialId", pipelineMaterial.GitMaterialId, "err", err)
+		return nil, nil, err
+	}
+	if !gitMaterial.CheckoutStatus {
+		return nil, nil, fmt.Errorf("checkout not succeed please checkout first %s", gitMaterial.Url)
+	}
+	err = impl.useMaterial(ctx, gitMaterial)
+	if err != nil {
+		impl.logger.Errorw("error in restoring evicted material", "gitMaterialId", gitMaterial.Id, "err", err)
+		return nil, nil, err
+	}
+	return pipelineMaterial, gitMaterial, nil
+}
+
+// getRefOrBranch defaults ref to branch of pipeline material of fixed branch
+func getRefOrBranch(pipelineMaterial *sql.CiPipelineMaterial, ref string) (string, error) {
+	if len(ref) > 0 {
+		return ref, nil
+	}
+	if pipelineMaterial.Type != sql.SOURCE_TYPE_BRANCH_FIXED {
+		return "", errors.New("ref is required for ci pipeline material not of fixed branch")
+	}
+	return pipelineMaterial.Value, nil
 }
 
 type ReleaseChangesRequest struct {
 	PipelineMaterialId int    `json:"pipelineMaterialId"`
 	OldCommit          string `json:"oldCommit"`
 	NewCommit          string `json:"newCommit"`
+	// release notes are built from conventional commit messages when enabled
+	ReleaseNotes bool `json:"releaseNotes"`
+	// regex of issue keys mentioned in messages, keys of jira are matched if empty
+	IssueKeyPattern string `json:"issueKeyPattern"`
 }
 
-func (impl RepoManagerImpl) RefreshGitMaterial(req *git.RefreshGitMaterialRequest) (*git.RefreshGitMaterialResponse, error) {
+func (impl RepoManagerImpl) RefreshGitMaterial(ctx context.Context, req *git.RefreshGitMaterialRequest) (*git.RefreshGitMaterialResponse, error) {
 	material := &sql.GitMaterial{Id: req.GitMaterialId}
 	res := &git.RefreshGitMaterialResponse{}
 	//refresh repo. and notify all pipeline for changes
 	//lock inside watcher itself
-	material, err := impl.gitWatcher.PollAndUpdateGitMaterial(material)
+	material, err := impl.gitWatcher.PollAndUpdateGitMaterial(ctx, material)
 	if err != nil {
 		res.ErrorMsg = err.Error()
 	} else if material.LastFetchErrorCount > 0 {
@@ -27,6 +27,11 @@ type FetchScmChangesRequest struct {
 	From               string `json:"from"`
 	To                 string `json:"to"`
 	Count              int    `json:"count"`
+	// cursor of next page returned with previous page
+	Cursor string `json:"cursor"`
+	// bounds of committer date of commits
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
 }
 
 type HeadRequest struct {
@@ -40,15 +45,37 @@ type CiPipelineMaterialBean struct {
 	Value         string
 	Active        bool
 	GitCommit     *GitCommit
+	NewCommits    []*GitCommit `json:",omitempty"` //all commits since last seen head, newest first, GitCommit is the first of them
+	Branch        string       `json:",omitempty"` //branch matched by SOURCE_TYPE_BRANCH_REGEX material
 }
 
 type MaterialChangeResp struct {
-	Commits        []*GitCommit `json:"commits"`
-	LastFetchTime  time.Time    `json:"lastFetchTime"`
-	IsRepoError    bool         `json:"isRepoError"`
-	RepoErrorMsg   string       `json:"repoErrorMsg"`
-	IsBranchError  bool         `json:"isBranchError"`
-	BranchErrorMsg string       `json:"branchErrorMsg"`
+	Commits        []*GitCommit      `json:"commits"`
+	LastFetchTime  time.Time         `json:"lastFetchTime"`
+	IsRepoError    bool              `json:"isRepoError"`
+	RepoErrorMsg   string            `json:"repoErrorMsg"`
+	IsBranchError  bool              `json:"isBranchError"`
+	BranchErrorMsg string            `json:"branchErrorMsg"`
+	BranchCommits  []*BranchCommits  `json:"branchCommits,omitempty"`
+	MaterialState  sql.MaterialState `json:"materialState,omitempty"`
+	NextCursor     string            `json:"nextCursor,omitempty"`
+}
+
+// CiPipelineMaterialStateChange is published when tracked branch of material is force-pushed or deleted
+type CiPipelineMaterialStateChange struct {
+	Id              int
+	GitMaterialId   int
+	Type            sql.SourceType
+	Value           string
+	State           sql.MaterialState
+	PreviousHead    string
+	NewHead         string       `json:",omitempty"`
+	OrphanedCommits []*GitCommit `json:",omitempty"`
+}
+
+type BranchCommits struct {
+	Branch  string       `json:"branch"`
+	Commits []*GitCommit `json:"commits"`
 }
 
 type GitCommit struct {
@@ -57,8 +84,23 @@ type GitCommit struct {
 	Date        time.Time
 	Message     string
 	Changes     []string          `json:",omitempty"`
+	StatsStatus CommitStatsStatus `json:",omitempty"` // changes are not listed while stats are pending, failed or unavailable
 	FileStats   *object.FileStats `json:",omitempty"`
 	WebhookData *WebhookData      `json:"webhookData"`
+	Tag         string            `json:",omitempty"`
+	// submodule pointers moved by the commit, old sha is blank for added and new sha for removed submodule
+	SubmoduleChanges []*SubmoduleChange `json:",omitempty"`
+	// reason for not notifying this commit as new head of material
+	SkipReason string `json:",omitempty"`
+	// filter of material which could not be evaluated when this commit was notified
+	Warning   string           `json:",omitempty"`
+	Signature *CommitSignature `json:",omitempty"`
+}
+
+type SubmoduleChange struct {
+	Path   string
+	OldSha string `json:",omitempty"`
+	NewSha string `json:",omitempty"`
 }
 
 type WebhookData struct {
@@ -74,6 +116,242 @@ type CommitMetadataRequest struct {
 	BranchName         string `json:"branchName"`
 }
 
+type FileDiffRequest struct {
+	PipelineMaterialId int    `json:"pipelineMaterialId"`
+	OldRef             string `json:"oldRef"` // commit hash, branch or tag
+	NewRef             string `json:"newRef"` // commit hash, branch or tag, branch of material if empty
+	Offset             int    `json:"offset"`
+	Size               int    `json:"size"`            // no of files in page
+	MaxBytesPerFile    int    `json:"maxBytesPerFile"` // patch of a file is truncated beyond it
+}
+
+type FileChangeType string
+
+const (
+	FILE_CHANGE_TYPE_ADDED        FileChangeType = "ADDED"
+	FILE_CHANGE_TYPE_MODIFIED     FileChangeType = "MODIFIED"
+	FILE_CHANGE_TYPE_DELETED      FileChangeType = "DELETED"
+	FILE_CHANGE_TYPE_RENAMED      FileChangeType = "RENAMED"
+	FILE_CHANGE_TYPE_COPIED       FileChangeType = "COPIED"
+	FILE_CHANGE_TYPE_TYPE_CHANGED FileChangeType = "TYPE_CHANGED"
+)
+
+type FileDiff struct {
+	ChangeType FileChangeType `json:"changeType"`
+	OldPath    string         `json:"oldPath,omitempty"`
+	NewPath    string         `json:"newPath,omitempty"`
+	Similarity int            `json:"similarity,omitempty"` // percentage, for renamed and copied files
+	Binary     bool           `json:"binary"`
+	Additions  int            `json:"additions"`
+	Deletions  int            `json:"deletions"`
+	Patch      string         `json:"patch,omitempty"` // unified diff, empty for binary files
+	Truncated  bool           `json:"truncated"`
+}
+
+type FileDiffResponse struct {
+	OldCommit  string      `json:"oldCommit"`
+	NewCommit  string      `json:"newCommit"`
+	TotalFiles int         `json:"totalFiles"`
+	Offset     int         `json:"offset"`
+	Size       int         `json:"size"`
+	Files      []*FileDiff `json:"files"`
+}
+
+type TreeRequest struct {
+	PipelineMaterialId int    `json:"pipelineMaterialId"`
+	Ref                string `json:"ref"`  // commit hash, branch or tag, branch of material if empty
+	Path               string `json:"path"` // directory relative to root of repository, root if empty
+}
+
+type TreeEntryType string
+
+const (
+	TREE_ENTRY_TYPE_FILE      TreeEntryType = "FILE"
+	TREE_ENTRY_TYPE_DIRECTORY TreeEntryType = "DIRECTORY"
+	TREE_ENTRY_TYPE_SYMLINK   TreeEntryType = "SYMLINK"
+	TREE_ENTRY_TYPE_SUBMODULE TreeEntryType = "SUBMODULE"
+)
+
+type TreeEntry struct {
+	Name string        `json:"name"`
+	Path string        `json:"path"`
+	Type TreeEntryType `json:"type"`
+	Mode string        `json:"mode"`
+	Hash string        `json:"hash"`
+	Size int64         `json:"size"` // 0 for directories and submodules
+}
+
+type TreeResponse struct {
+	Commit  string       `json:"commit"`
+	Path    string       `json:"path"`
+	Entries []*TreeEntry `json:"entries"`
+}
+
+type FileContentRequest struct {
+	PipelineMaterialId int    `json:"pipelineMaterialId"`
+	Ref                string `json:"ref"` // commit hash, branch or tag, branch of material if empty
+	Path               string `json:"path"`
+	MaxBytes           int    `json:"maxBytes"` // content is truncated beyond it
+}
+
+type LfsPointer struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type FileContentResponse struct {
+	Commit      string      `json:"commit"`
+	Path        string      `json:"path"`
+	Hash        string      `json:"hash"`
+	Size        int64       `json:"size"`
+	ContentType string      `json:"contentType"`
+	Binary      bool        `json:"binary"`
+	Symlink     bool        `json:"symlink"`              // content is target of the link
+	LfsPointer  *LfsPointer `json:"lfsPointer,omitempty"` // set if file is a git lfs pointer, content is the pointer
+	Encoding    string      `json:"encoding"`             // utf-8, or base64 for binary content
+	Content     string      `json:"content"`
+	Truncated   bool        `json:"truncated"`
+}
+
+type CompareRefsRequest struct {
+	PipelineMaterialId int    `json:"pipelineMaterialId"`
+	GitMaterialId      int    `json:"gitMaterialId"`
+	BaseRef            string `json:"baseRef"` // commit hash, branch or tag
+	HeadRef            string `json:"headRef"` // commit hash, branch or tag, branch of material if empty
+	// max commits listed for each side, counts are not limited
+	MaxCommits int `json:"maxCommits"`
+}
+
+type CompareStatus string
+
+const (
+	COMPARE_STATUS_IDENTICAL CompareStatus = "IDENTICAL"
+	COMPARE_STATUS_AHEAD     CompareStatus = "AHEAD"
+	COMPARE_STATUS_BEHIND    CompareStatus = "BEHIND"
+	COMPARE_STATUS_DIVERGED  CompareStatus = "DIVERGED"
+)
+
+type CompareRefsResponse struct {
+	BaseCommit string        `json:"baseCommit"`
+	HeadCommit string        `json:"headCommit"`
+	MergeBase  string        `json:"mergeBase"` // empty if refs have no common history
+	Status     CompareStatus `json:"status"`
+	// commits of head not in base
+	AheadBy      int          `json:"aheadBy"`
+	AheadCommits []*GitCommit `json:"aheadCommits"`
+	// commits of base not in head
+	BehindBy      int          `json:"behindBy"`
+	BehindCommits []*GitCommit `json:"behindCommits"`
+	// files changed by head since merge base, without patches
+	Files          []*FileDiff `json:"files"`
+	FilesTruncated bool        `json:"filesTruncated"`
+	TotalFiles     int         `json:"totalFiles"`
+	Additions      int         `json:"additions"`
+	Deletions      int         `json:"deletions"`
+}
+
+type CommitSearchRequest struct {
+	PipelineMaterialId int `json:"pipelineMaterialId"`
+	GitMaterialId      int `json:"gitMaterialId"`
+	// branch to search, defaults to branch of fixed branch pipeline material, else all branches and tags are searched
+	Branch string `json:"branch"`
+	// message contains text
+	Text string `json:"text"`
+	// message matches posix extended regex
+	Regex string `json:"regex"`
+	// name or email contains
+	Author    string `json:"author"`
+	Committer string `json:"committer"`
+	// commit changed file or any file in directory
+	Path string `json:"path"`
+	// bounds of committer date of commits
+	Since         *time.Time `json:"since"`
+	Until         *time.Time `json:"until"`
+	CaseSensitive bool       `json:"caseSensitive"`
+	Offset        int        `json:"offset"`
+	Size          int        `json:"size"`
+}
+
+type CommitSearchResponse struct {
+	Commits       []*GitCommit `json:"commits"`
+	Offset        int          `json:"offset"`
+	Size          int          `json:"size"`
+	HasMore       bool         `json:"hasMore"`
+	LastFetchTime time.Time    `json:"lastFetchTime"`
+}
+
+type BranchSortBy string
+
+const (
+	BRANCH_SORT_BY_NAME   BranchSortBy = "NAME"
+	BRANCH_SORT_BY_RECENT BranchSortBy = "RECENT" // latest head commit first
+)
+
+type BranchListRequest struct {
+	GitMaterialId int          `json:"gitMaterialId"`
+	Prefix        string       `json:"prefix"`
+	Regex         string       `json:"regex"`
+	SortBy        BranchSortBy `json:"sortBy"`
+	Offset        int          `json:"offset"`
+	Size          int          `json:"size"`
+	Refresh       bool         `json:"refresh"` // fetch repository before listing
+}
+
+type Branch struct {
+	Name    string    `json:"name"`
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	// head commit of branch is not fetched by any pipeline, author, date and message are unknown
+	Unresolved bool `json:"unresolved,omitempty"`
+}
+
+type BranchListResponse struct {
+	Branches      []*Branch `json:"branches"`
+	TotalCount    int       `json:"totalCount"`
+	Offset        int       `json:"offset"`
+	Size          int       `json:"size"`
+	LastFetchTime time.Time `json:"lastFetchTime"`
+}
+
+type TagSortBy string
+
+const (
+	TAG_SORT_BY_DATE   TagSortBy = "DATE"   // latest tag first
+	TAG_SORT_BY_SEMVER TagSortBy = "SEMVER" // highest version first, tags which are not semver are last
+)
+
+type TagListRequest struct {
+	GitMaterialId int       `json:"gitMaterialId"`
+	Glob          string    `json:"glob"`
+	Regex         string    `json:"regex"`
+	SortBy        TagSortBy `json:"sortBy"`
+	Offset        int       `json:"offset"`
+	Size          int       `json:"size"`
+	Refresh       bool      `json:"refresh"` // fetch repository before listing
+}
+
+type TagInfo struct {
+	Name      string `json:"name"`
+	Commit    string `json:"commit"`
+	Annotated bool   `json:"annotated"`
+	// tagger of annotated tag, author of commit for lightweight tag
+	Tagger  string    `json:"tagger"`
+	Message string    `json:"message"`
+	Date    time.Time `json:"date"`
+	// tag object or commit of tag is not fetched by any pipeline, tagger, message and date are unknown
+	Unresolved bool `json:"unresolved,omitempty"`
+}
+
+type TagListResponse struct {
+	Tags          []*TagInfo `json:"tags"`
+	TotalCount    int        `json:"totalCount"`
+	Offset        int        `json:"offset"`
+	Size          int        `json:"size"`
+	LastFetchTime time.Time  `json:"lastFetchTime"`
+}
+
 type WebhookDataRequest struct {
 	Id int `json:"id"`
 }
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	DEFAULT_BRANCH_PAGE_SIZE = 50
+	MAX_BRANCH_PAGE_SIZE     = 500
+)
+
+// ListBranches lists branches of remote with their head commit, commits are read only for the page unless branches are
+// sorted by recency
+func (impl RepositoryManagerImpl) ListBranches(ctx context.Context, checkoutPath string, userName string, password string, request *BranchListRequest) (*BranchListResponse, error) {
+	var nameRegex *regexp.Regexp
+	if len(request.Regex) > 0 {
+		var err error
+		nameRegex, err = regexp.Compile(request.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch regex %s: %v", request.Regex, err)
+		}
+	}
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	branchHeads, err := impl.listRemoteBranchHeads(ctx, checkoutPath, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	var branches []*Branch
+	for name, commit := range branchHeads {
+		if !strings.HasPrefix(name, request.Prefix) || (nameRegex != nil && !nameRegex.MatchString(name)) {
+			continue
+		}
+		branches = append(branches, &Branch{Name: name, Commit: commit})
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+	if request.SortBy == BRANCH_SORT_BY_RECENT {
+		for _, branch := range branches {
+			impl.setBranchCommitInfo(repository, branch)
+		}
+		// branches whose head is not fetched have no date, they follow the others by name
+		sort.SliceStable(branches, func(i, j int) bool {
+			if branches[i].Unresolved != branches[j].Unresolved {
+				return !branches[i].Unresolved
+			}
+			return branches[i].Date.After(branches[j].Date)
+		})
+	}
+	offset := request.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	size := request.Size
+	if size <= 0 {
+		size = DEFAULT_BRANCH_PAGE_SIZE
+	} else if size > MAX_BRANCH_PAGE_SIZE {
+		size = MAX_BRANCH_PAGE_SIZE
+	}
+	response := &BranchListResponse{TotalCount: len(branches), Offset: offset, Size: size, Branches: []*Branch{}}
+	if offset >= len(branches) {
+		return response, nil
+	}
+	end := offset + size
+	if end > len(branches) {
+		end = len(branches)
+	}
+	response.Branches = branches[offset:end]
+	if request.SortBy != BRANCH_SORT_BY_RECENT {
+		for _, branch := range response.Branches {
+			impl.setBranchCommitInfo(repository, branch)
+		}
+	}
+	return response, nil
+}
+
+func (impl RepositoryManagerImpl) setBranchCommitInfo(repository *git.Repository, branch *Branch) {
+	commit, err := repository.CommitObject(plumbing.NewHash(branch.Commit))
+	if err == plumbing.ErrObjectNotFound {
+		// head of branch is not fetched, refs of branches without pipelines are not fetched
+		branch.Unresolved = true
+		return
+	} else if err != nil {
+		// branch is still listed, without commit info
+		impl.logger.Errorw("error in getting head commit of branch", "branch", branch.Name, "commit", branch.Commit, "err", err)
+		branch.Unresolved = true
+		return
+	}
+	branch.Author = commit.Author.String()
+	branch.Date = commit.Author.When
+	branch.Message = strings.TrimSpace(strings.SplitN(commit.Message, "\n", 2)[0])
+}
+
+// listRemoteBranchHeads lists branches with git ls-remote, as repository has only branches of pipelines fetched when
+// fetch ref specs are narrowed
+func (impl RepositoryManagerImpl) listRemoteBranchHeads(ctx context.Context, checkoutPath string, userName string, password string) (map[string]string, error) {
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.LsRemote(ctx, checkoutPath, userName, password, &out, "--heads")
+	if err != nil {
+		impl.logger.Errorw("error in listing branches of remote", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in listing branches of remote: %s", strings.TrimSpace(errMsg))
+	}
+	branchHeads := make(map[string]string)
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/heads/") {
+			continue
+		}
+		branchHeads[strings.TrimPrefix(fields[1], "refs/heads/")] = fields[0]
+	}
+	return branchHeads, nil
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	// COMMIT_HISTORY_CACHE_SIZE is number of latest commits of a branch cached in commit history of material
+	COMMIT_HISTORY_CACHE_SIZE = 15
+	MAX_COMMIT_PAGE_SIZE      = 100
+	STORED_HISTORY_CURSOR     = "stored:"
+)
+
+// CommitHistoryCursor is position in history walked from a start commit, start commit is pinned by first page so that
+// commits pushed later do not shift the pages
+type CommitHistoryCursor struct {
+	StartCommit string
+	Skip        int
+}
+
+func (c *CommitHistoryCursor) String() string {
+	return fmt.Sprintf("%s:%d", c.StartCommit, c.Skip)
+}
+
+func ParseCommitHistoryCursor(cursor string) (*CommitHistoryCursor, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 2 || len(parts[0]) != 40 {
+		return nil, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	skip, err := strconv.Atoi(parts[1])
+	if err != nil || skip < 0 {
+		return nil, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	return &CommitHistoryCursor{StartCommit: parts[0], Skip: skip}, nil
+}
+
+// StoredHistoryCursor is position in stored history of branch, commits ordered below BeforeOrder make the next page.
+// Commits stored later are either above the head or below the oldest commit, so they do not shift the pages
+type StoredHistoryCursor struct {
+	BeforeOrder int
+}
+
+func (c *StoredHistoryCursor) String() string {
+	return fmt.Sprintf("%s%d", STORED_HISTORY_CURSOR, c.BeforeOrder)
+}
+
+// ParseStoredHistoryCursor returns nil for blank cursor i.e. first page
+func ParseStoredHistoryCursor(cursor string) (*StoredHistoryCursor, error) {
+	if len(cursor) == 0 {
+		return nil, nil
+	}
+	order, err := strconv.Atoi(strings.TrimPrefix(cursor, STORED_HISTORY_CURSOR))
+	if err != nil || !strings.HasPrefix(cursor, STORED_HISTORY_CURSOR) {
+		return nil, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	return &StoredHistoryCursor{BeforeOrder: order}, nil
+}
+
+func GetCommitPageSize(count int) int {
+	if count <= 0 {
+		return COMMIT_HISTORY_CACHE_SIZE
+	} else if count > MAX_COMMIT_PAGE_SIZE {
+		return MAX_COMMIT_PAGE_SIZE
+	}
+	return count
+}
+
+// IsStoredHistoryRequest tells if request asks for a page of history of branch from its head without bounds, which is
+// served from stored history of branch
+func IsStoredHistoryRequest(request *FetchScmChangesRequest) bool {
+	return len(request.From) == 0 && len(request.To) == 0 && request.Since == nil && request.Until == nil &&
+		(len(request.Cursor) == 0 || strings.HasPrefix(request.Cursor, STORED_HISTORY_CURSOR))
+}
+
+// GetUnstoredCommits walks history of branch from its head in log order and returns first count commits not in stored
+// history, isStored is asked for a batch of commits at a time. Stats are not waited for
+func (impl RepositoryManagerImpl) GetUnstoredCommits(checkoutPath string, branch string, isStored func(commitHashes []string) (map[string]bool, error), count int) ([]*GitCommit, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := impl.getBranchReference(repository, branch)
+	if err != nil {
+		return nil, err
+	}
+	itr, err := repository.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		impl.logger.Errorw("error in getting iterator", "path", checkoutPath, "branch", branch, "err", err)
+		return nil, err
+	}
+	defer itr.Close()
+	var gitCommits []*GitCommit
+	done := false
+	for !done && len(gitCommits) < count {
+		var batch []*object.Commit
+		var hashes []string
+		for len(batch) < MAX_COMMIT_PAGE_SIZE {
+			commit, err := itr.Next()
+			if err == io.EOF {
+				done = true
+				break
+			} else if err != nil {
+				impl.logger.Errorw("error in iterating history", "path", checkoutPath, "branch", branch, "err", err)
+				return nil, err
+			}
+			batch = append(batch, commit)
+			hashes = append(hashes, commit.Hash.String())
+		}
+		if len(batch) == 0 {
+			break
+		}
+		stored, err := isStored(hashes)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range batch {
+			if stored[commit.Hash.String()] || len(gitCommits) == count {
+				continue
+			}
+			gitCommit, err := impl.toPendingGitCommit(commit)
+			if err != nil {
+				impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "commit", commit.Hash.String(), "err", err)
+				return nil, err
+			}
+			gitCommits = append(gitCommits, gitCommit)
+		}
+	}
+	impl.commitStatsService.FillStats(checkoutPath, gitCommits)
+	return gitCommits, nil
+}
+
+// GetCommitHistory returns a page of history of branch, walked from head of branch or To commit of request until From
+// commit. History bounded by date is walked in committer time order, so that the walk stops at first commit before
+// Since, same bounds must be passed with cursor of next page
+func (impl RepositoryManagerImpl) GetCommitHistory(checkoutPath string, branch string, request *FetchScmChangesRequest) ([]*GitCommit, string, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, "", err
+	}
+	cursor := &CommitHistoryCursor{}
+	if len(request.Cursor) > 0 {
+		cursor, err = ParseCommitHistoryCursor(request.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+	} else if len(request.To) > 0 {
+		cursor.StartCommit = request.To
+	} else {
+		branch = strings.TrimPrefix(branch, "refs/heads/")
+		ref, err := repository.Reference(plumbing.ReferenceName(REMOTE_BRANCH_REF_PREFIX+branch), true)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, "", &BranchNotFoundError{Branch: branch}
+		} else if err != nil {
+			impl.logger.Errorw("error in getting reference", "branch", branch, "err", err)
+			return nil, "", err
+		}
+		cursor.StartCommit = ref.Hash().String()
+	}
+	startCommit, err := repository.CommitObject(plumbing.NewHash(cursor.StartCommit))
+	if err != nil {
+		impl.logger.Errorw("error in getting start commit of history", "path", checkoutPath, "commit", cursor.StartCommit, "err", err)
+		return nil, "", fmt.Errorf("commit %s not found in the repository", cursor.StartCommit)
+	}
+	logOptions := &git.LogOptions{From: startCommit.Hash}
+	if request.Since != nil || request.Until != nil {
+		logOptions.Order = git.LogOrderCommitterTime
+	}
+	itr, err := repository.Log(logOptions)
+	if err != nil {
+		impl.logger.Errorw("error in getting iterator", "path", checkoutPath, "commit", cursor.StartCommit, "err", err)
+		return nil, "", err
+	}
+	defer itr.Close()
+
+	size := GetCommitPageSize(request.Count)
+	gitCommits := make([]*GitCommit, 0, size)
+	matched := 0
+	hasMore := false
+	for {
+		commit, err := itr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			impl.logger.Errorw("error in iterating history", "path", checkoutPath, "commit", cursor.StartCommit, "err", err)
+			return nil, "", err
+		}
+		if len(request.From) > 0 && commit.Hash.String() == request.From {
+			break
+		}
+		if request.Since != nil && commit.Committer.When.Before(*request.Since) {
+			break
+		}
+		if request.Until != nil && commit.Committer.When.After(*request.Until) {
+			continue
+		}
+		matched++
+		if matched <= cursor.Skip {
+			continue
+		}
+		if len(gitCommits) == size {
+			hasMore = true
+			break
+		}
+		gitCommit, err := impl.toPendingGitCommit(commit)
+		if err != nil {
+			impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "commit", commit.Hash.String(), "err", err)
+			return nil, "", err
+		}
+		gitCommits = append(gitCommits, gitCommit)
+	}
+	impl.commitStatsService.FillStats(checkoutPath, gitCommits)
+	nextCursor := ""
+	if hasMore {
+		nextCursor = (&CommitHistoryCursor{StartCommit: cursor.StartCommit, Skip: cursor.Skip + len(gitCommits)}).String()
+	}
+	return gitCommits, nextCursor, nil
+}
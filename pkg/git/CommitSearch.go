@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// SearchCommits finds commits of branch of request, or of all fetched branches and tags, matching every filter of
+// request, newest commit first
+func (impl RepositoryManagerImpl) SearchCommits(ctx context.Context, checkoutPath string, userName, password string, request *CommitSearchRequest) (*CommitSearchResponse, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	offset := request.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	size := GetCommitPageSize(request.Size)
+	args, err := commitSearchArgs(repository, request, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.Log(ctx, checkoutPath, userName, password, &out, args...)
+	if err != nil {
+		impl.logger.Errorw("error in searching commits", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in searching commits: %s", strings.TrimSpace(errMsg))
+	}
+	hashes := strings.Fields(out.String())
+	response := &CommitSearchResponse{Offset: offset, Size: size, Commits: []*GitCommit{}}
+	if len(hashes) > size {
+		response.HasMore = true
+		hashes = hashes[:size]
+	}
+	for _, hash := range hashes {
+		commit, err := repository.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			impl.logger.Errorw("error in getting commit", "path", checkoutPath, "commit", hash, "err", err)
+			return nil, err
+		}
+		gitCommit, err := impl.toPendingGitCommit(commit)
+		if err != nil {
+			impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "commit", hash, "err", err)
+			return nil, err
+		}
+		response.Commits = append(response.Commits, gitCommit)
+	}
+	impl.commitStatsService.FillStats(checkoutPath, response.Commits)
+	return response, nil
+}
+
+// commitSearchArgs builds git log args, text, author and committer are quoted as patterns are extended regex
+func commitSearchArgs(repository *git.Repository, request *CommitSearchRequest, offset int, size int) ([]string, error) {
+	args := []string{"--format=%H", "--extended-regexp", fmt.Sprintf("--skip=%d", offset), fmt.Sprintf("--max-count=%d", size+1)}
+	if !request.CaseSensitive {
+		args = append(args, "--regexp-ignore-case")
+	}
+	if len(request.Text) > 0 {
+		args = append(args, "--grep="+regexp.QuoteMeta(request.Text))
+	}
+	if len(request.Regex) > 0 {
+		args = append(args, "--grep="+request.Regex)
+	}
+	if len(request.Text) > 0 && len(request.Regex) > 0 {
+		args = append(args, "--all-match")
+	}
+	if len(request.Author) > 0 {
+		args = append(args, "--author="+regexp.QuoteMeta(request.Author))
+	}
+	if len(request.Committer) > 0 {
+		args = append(args, "--committer="+regexp.QuoteMeta(request.Committer))
+	}
+	if request.Since != nil {
+		args = append(args, fmt.Sprintf("--since=@%d", request.Since.Unix()))
+	}
+	if request.Until != nil {
+		args = append(args, fmt.Sprintf("--until=@%d", request.Until.Unix()))
+	}
+	if len(request.Branch) > 0 {
+		branch := strings.TrimPrefix(request.Branch, "refs/heads/")
+		ref, err := repository.Reference(plumbing.ReferenceName(REMOTE_BRANCH_REF_PREFIX+branch), true)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, &BranchNotFoundError{Branch: branch}
+		} else if err != nil {
+			return nil, err
+		}
+		args = append(args, ref.Hash().String())
+	} else {
+		args = append(args, "--remotes=origin", "--tags")
+	}
+	args = append(args, "--")
+	if filePath := strings.TrimPrefix(path.Clean("/"+request.Path), "/"); filePath != "" {
+		args = append(args, filePath)
+	}
+	return args, nil
+}
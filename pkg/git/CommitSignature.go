@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	pgpErrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"io/ioutil"
+	"strings"
+)
+
+type SignatureStatus string
+
+const (
+	SIGNATURE_STATUS_UNSIGNED  SignatureStatus = "UNSIGNED"
+	SIGNATURE_STATUS_VALID     SignatureStatus = "VALID"
+	SIGNATURE_STATUS_INVALID   SignatureStatus = "INVALID"
+	SIGNATURE_STATUS_UNTRUSTED SignatureStatus = "UNTRUSTED" // signature can't be checked against trusted keys of git provider
+)
+
+const (
+	SIGNATURE_TYPE_PGP = "PGP"
+	SIGNATURE_TYPE_SSH = "SSH"
+
+	SSH_SIGNATURE_ARMOR_START = "-----BEGIN SSH SIGNATURE-----"
+	SSH_SIGNATURE_ARMOR_END   = "-----END SSH SIGNATURE-----"
+	SSH_SIGNATURE_MAGIC       = "SSHSIG"
+	SSH_SIGNATURE_NAMESPACE   = "git"
+)
+
+type CommitSignature struct {
+	Status  SignatureStatus
+	Type    string `json:",omitempty"`
+	KeyId   string `json:",omitempty"` // PGP issuer key id or SHA256 fingerprint of SSH key
+	Signer  string `json:",omitempty"` // identity of trusted key which verified the signature
+	Message string `json:",omitempty"`
+}
+
+// TrustedKeys are PGP and SSH public keys of a git provider against which commit signatures are verified
+type TrustedKeys struct {
+	pgpKeyRing openpgp.EntityList
+	sshKeys    map[string]string // SHA256 fingerprint to comment of authorized key
+}
+
+func NewTrustedKeys(gitProvider *sql.GitProvider) (*TrustedKeys, error) {
+	trustedKeys := &TrustedKeys{sshKeys: make(map[string]string)}
+	if gitProvider == nil {
+		return trustedKeys, nil
+	}
+	if len(strings.TrimSpace(gitProvider.TrustedGpgKeys)) > 0 {
+		keyRing, err := openpgp.ReadArmoredKeyRing(strings.NewReader(gitProvider.TrustedGpgKeys))
+		if err != nil {
+			return trustedKeys, fmt.Errorf("invalid trusted gpg keys: %s", err.Error())
+		}
+		trustedKeys.pgpKeyRing = keyRing
+	}
+	rest := []byte(gitProvider.TrustedSshKeys)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		publicKey, comment, _, remaining, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return trustedKeys, fmt.Errorf("invalid trusted ssh keys: %s", err.Error())
+		}
+		trustedKeys.sshKeys[ssh.FingerprintSHA256(publicKey)] = comment
+		rest = remaining
+	}
+	return trustedKeys, nil
+}
+
+// VerifyCommitSignature verifies PGP or SSH signature of commit against trusted keys
+func VerifyCommitSignature(commit *object.Commit, trustedKeys *TrustedKeys) *CommitSignature {
+	if len(commit.PGPSignature) == 0 {
+		return &CommitSignature{Status: SIGNATURE_STATUS_UNSIGNED}
+	}
+	encoded := &plumbing.MemoryObject{}
+	err := commit.EncodeWithoutSignature(encoded)
+	if err != nil {
+		return &CommitSignature{Status: SIGNATURE_STATUS_INVALID, Message: err.Error()}
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return &CommitSignature{Status: SIGNATURE_STATUS_INVALID, Message: err.Error()}
+	}
+	signedData, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return &CommitSignature{Status: SIGNATURE_STATUS_INVALID, Message: err.Error()}
+	}
+	if strings.HasPrefix(strings.TrimSpace(commit.PGPSignature), SSH_SIGNATURE_ARMOR_START) {
+		return verifySshSignature(commit.PGPSignature, signedData, trustedKeys)
+	}
+	return verifyPgpSignature(commit.PGPSignature, signedData, trustedKeys)
+}
+
+func verifyPgpSignature(armoredSignature string, signedData []byte, trustedKeys *TrustedKeys) *CommitSignature {
+	signature := &CommitSignature{Type: SIGNATURE_TYPE_PGP, KeyId: getPgpIssuerKeyId(armoredSignature)}
+	if trustedKeys == nil || len(trustedKeys.pgpKeyRing) == 0 {
+		signature.Status = SIGNATURE_STATUS_UNTRUSTED
+		signature.Message = "no trusted gpg keys configured"
+		return signature
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(trustedKeys.pgpKeyRing, bytes.NewReader(signedData), strings.NewReader(armoredSignature))
+	if err == pgpErrors.ErrUnknownIssuer {
+		signature.Status = SIGNATURE_STATUS_UNTRUSTED
+		signature.Message = "signing key is not trusted"
+		return signature
+	} else if _, ok := err.(pgpErrors.UnsupportedError); ok {
+		// e.g. ed25519 keys, signature can't be checked which is not same as invalid signature
+		signature.Status = SIGNATURE_STATUS_UNTRUSTED
+		signature.Message = err.Error()
+		return signature
+	} else if err != nil {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = err.Error()
+		return signature
+	}
+	signature.Status = SIGNATURE_STATUS_VALID
+	for name := range signer.Identities {
+		signature.Signer = name
+		break
+	}
+	return signature
+}
+
+func getPgpIssuerKeyId(armoredSignature string) string {
+	block, err := armor.Decode(strings.NewReader(armoredSignature))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	switch sig := p.(type) {
+	case *packet.Signature:
+		if sig.IssuerKeyId != nil {
+			return fmt.Sprintf("%016X", *sig.IssuerKeyId)
+		}
+	case *packet.SignatureV3:
+		return fmt.Sprintf("%016X", sig.IssuerKeyId)
+	}
+	return ""
+}
+
+// verifySshSignature verifies signature created by ssh-keygen -Y sign in git namespace, see PROTOCOL.sshsig of openssh
+func verifySshSignature(armoredSignature string, signedData []byte, trustedKeys *TrustedKeys) *CommitSignature {
+	signature := &CommitSignature{Type: SIGNATURE_TYPE_SSH}
+	sshSignature, err := parseSshSignature(armoredSignature)
+	if err != nil {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = err.Error()
+		return signature
+	}
+	publicKey, err := ssh.ParsePublicKey(sshSignature.PublicKey)
+	if err != nil {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = err.Error()
+		return signature
+	}
+	signature.KeyId = ssh.FingerprintSHA256(publicKey)
+	if sshSignature.Namespace != SSH_SIGNATURE_NAMESPACE {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = fmt.Sprintf("unexpected signature namespace %s", sshSignature.Namespace)
+		return signature
+	}
+	var digest []byte
+	switch sshSignature.HashAlgorithm {
+	case "sha256":
+		sum := sha256.Sum256(signedData)
+		digest = sum[:]
+	case "sha512":
+		sum := sha512.Sum512(signedData)
+		digest = sum[:]
+	default:
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = fmt.Sprintf("unsupported hash algorithm %s", sshSignature.HashAlgorithm)
+		return signature
+	}
+	message := []byte(SSH_SIGNATURE_MAGIC)
+	message = append(message, ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSignature.Namespace, sshSignature.Reserved, sshSignature.HashAlgorithm, digest})...)
+	wireSignature := struct {
+		Format string
+		Blob   []byte
+		Rest   []byte `ssh:"rest"`
+	}{}
+	err = ssh.Unmarshal(sshSignature.Signature, &wireSignature)
+	if err != nil {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = err.Error()
+		return signature
+	}
+	err = publicKey.Verify(message, &ssh.Signature{Format: wireSignature.Format, Blob: wireSignature.Blob, Rest: wireSignature.Rest})
+	if err != nil {
+		signature.Status = SIGNATURE_STATUS_INVALID
+		signature.Message = err.Error()
+		return signature
+	}
+	signer, ok := "", false
+	if trustedKeys != nil {
+		signer, ok = trustedKeys.sshKeys[signature.KeyId]
+	}
+	if !ok {
+		signature.Status = SIGNATURE_STATUS_UNTRUSTED
+		signature.Message = "signing key is not trusted"
+		return signature
+	}
+	signature.Status = SIGNATURE_STATUS_VALID
+	signature.Signer = signer
+	return signature
+}
+
+type sshSignatureBlob struct {
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+func parseSshSignature(armoredSignature string) (*sshSignatureBlob, error) {
+	armored := strings.TrimSpace(armoredSignature)
+	armored = strings.TrimPrefix(armored, SSH_SIGNATURE_ARMOR_START)
+	armored = strings.TrimSuffix(armored, SSH_SIGNATURE_ARMOR_END)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(armored), ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) < len(SSH_SIGNATURE_MAGIC)+4 || string(blob[:len(SSH_SIGNATURE_MAGIC)]) != SSH_SIGNATURE_MAGIC {
+		return nil, fmt.Errorf("invalid ssh signature")
+	}
+	blob = blob[len(SSH_SIGNATURE_MAGIC):]
+	if version := binary.BigEndian.Uint32(blob[:4]); version != 1 {
+		return nil, fmt.Errorf("unsupported ssh signature version %d", version)
+	}
+	sshSignature := &sshSignatureBlob{}
+	err = ssh.Unmarshal(blob[4:], sshSignature)
+	if err != nil {
+		return nil, err
+	}
+	return sshSignature, nil
+}
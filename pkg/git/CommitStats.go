@@ -0,0 +1,335 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/env"
+	"github.com/devtron-labs/git-sensor/internal"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"github.com/gammazero/workerpool"
+	"go.uber.org/zap"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type CommitStatsConfig struct {
+	CommitStatsWorker int `env:"COMMIT_STATS_WORKER" envDefault:"2"`
+}
+
+type CommitStatsStatus string
+
+const (
+	COMMIT_STATS_STATUS_COMPLETE CommitStatsStatus = "COMPLETE"
+	COMMIT_STATS_STATUS_PENDING  CommitStatsStatus = "PENDING" // queued for computation in background
+	// only names of changed files are known, blobs are not present in a blobless clone to count lines
+	COMMIT_STATS_STATUS_PARTIAL CommitStatsStatus = "PARTIAL"
+	COMMIT_STATS_STATUS_FAILED  CommitStatsStatus = "FAILED"
+	// trees of commit or its parent are not present in repository i.e. treeless clone or shallow boundary commit,
+	// stats are not saved so that they are computed once objects are fetched
+	COMMIT_STATS_STATUS_UNAVAILABLE CommitStatsStatus = "UNAVAILABLE"
+)
+
+var errCommitStatsUnavailable = errors.New("objects needed for stats of commit are not present in repository")
+
+// CommitStatsService keeps file stats of commits computed once per commit, so that diffing a commit is not repeated
+// on every read. Stats are computed only in background under lock of location, reads get saved stats or a status
+// telling why they are not there yet
+type CommitStatsService interface {
+	FillStats(location string, commits []*GitCommit)
+}
+
+type CommitStatsServiceImpl struct {
+	logger                *zap.SugaredLogger
+	commitStatsRepository sql.CommitStatsRepository
+	locker                *internal.RepositoryLocker
+	workerPool            *workerpool.WorkerPool
+	lock                  *sync.Mutex
+	queued                map[string]bool
+	unresolved            map[string]CommitStatsStatus // outcome of last computation which saved nothing
+}
+
+func NewCommitStatsServiceImpl(logger *zap.SugaredLogger, commitStatsRepository sql.CommitStatsRepository,
+	locker *internal.RepositoryLocker) (*CommitStatsServiceImpl, error) {
+	cfg := &CommitStatsConfig{}
+	err := env.Parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &CommitStatsServiceImpl{
+		logger:                logger,
+		commitStatsRepository: commitStatsRepository,
+		locker:                locker,
+		workerPool:            workerpool.New(cfg.CommitStatsWorker),
+		lock:                  &sync.Mutex{},
+		queued:                make(map[string]bool),
+		unresolved:            make(map[string]CommitStatsStatus),
+	}, nil
+}
+
+type savedCommitStats struct {
+	fileStats object.FileStats
+	partial   bool
+}
+
+// FillStats sets saved stats on commits whose stats are not complete, and queues computation of the ones not saved.
+// Partial stats saved from a blobless clone are queued again only when location has all blobs to complete them.
+// Commits without status are not tracked for stats and are left as is
+func (impl *CommitStatsServiceImpl) FillStats(location string, commits []*GitCommit) {
+	var hashes []string
+	for _, commit := range commits {
+		if commit != nil && commit.StatsStatus != "" && commit.StatsStatus != COMMIT_STATS_STATUS_COMPLETE {
+			hashes = append(hashes, commit.Commit)
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	savedStats, err := impl.findSavedStats(hashes)
+	if err != nil {
+		impl.logger.Errorw("error in getting saved commit stats", "location", location, "err", err)
+		return
+	}
+	completable := -1
+	for _, commit := range commits {
+		if commit == nil || commit.StatsStatus == "" || commit.StatsStatus == COMMIT_STATS_STATUS_COMPLETE {
+			continue
+		}
+		if stats, ok := savedStats[commit.Commit]; ok && !stats.partial {
+			commit.Changes = fileStatNames(stats.fileStats)
+			commit.StatsStatus = COMMIT_STATS_STATUS_COMPLETE
+			continue
+		} else if ok {
+			commit.Changes = fileStatNames(stats.fileStats)
+			commit.StatsStatus = COMMIT_STATS_STATUS_PARTIAL
+			if completable == -1 {
+				completable = 0
+				if !isPartialClone(location) {
+					completable = 1
+				}
+			}
+			if completable == 1 {
+				impl.queue(location, commit.Commit)
+			}
+			continue
+		}
+		commit.StatsStatus = impl.queue(location, commit.Commit)
+	}
+}
+
+// queue submits computation of stats of commit unless one is already queued for it, queued computations are run
+// by workers of the pool so that background computation does not starve the host. Status to report meanwhile is
+// returned, i.e. outcome of last computation which did not save stats, or pending
+func (impl *CommitStatsServiceImpl) queue(location string, hash string) CommitStatsStatus {
+	key := location + ":" + hash
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	status, ok := impl.unresolved[key]
+	if !ok {
+		status = COMMIT_STATS_STATUS_PENDING
+	}
+	if impl.queued[key] {
+		return status
+	}
+	impl.queued[key] = true
+	impl.workerPool.Submit(func() {
+		unresolvedStatus := impl.computeInBackground(location, hash)
+		impl.lock.Lock()
+		delete(impl.queued, key)
+		if len(unresolvedStatus) > 0 {
+			impl.unresolved[key] = unresolvedStatus
+		} else {
+			delete(impl.unresolved, key)
+		}
+		impl.lock.Unlock()
+	})
+	return status
+}
+
+// computeInBackground computes and saves stats of commit holding lock of location, as maintenance or eviction of
+// the repository would otherwise remove objects while they are read. Status of commit is returned when nothing
+// is saved for it
+func (impl *CommitStatsServiceImpl) computeInBackground(location string, hash string) CommitStatsStatus {
+	repoLock := impl.locker.LeaseLocker(location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(location)
+	}()
+	savedStats, err := impl.findSavedStats([]string{hash})
+	if err != nil {
+		impl.logger.Errorw("error in getting saved commit stats", "location", location, "commit", hash, "err", err)
+		return COMMIT_STATS_STATUS_FAILED
+	}
+	stats, saved := savedStats[hash]
+	if saved && !stats.partial {
+		// saved by another computation while this one was queued
+		return ""
+	}
+	repository, err := git.PlainOpen(location)
+	if err != nil {
+		impl.logger.Errorw("error in opening repository for commit stats", "location", location, "err", err)
+		return COMMIT_STATS_STATUS_FAILED
+	}
+	commit, err := repository.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		impl.logger.Errorw("error in getting commit for stats", "location", location, "commit", hash, "err", err)
+		return COMMIT_STATS_STATUS_FAILED
+	}
+	result := impl.getUntimedFileStats(commit)
+	if result.Error == errCommitStatsUnavailable {
+		impl.logger.Debugw("stats not available for commit", "location", location, "commit", hash)
+		return COMMIT_STATS_STATUS_UNAVAILABLE
+	} else if result.Error != nil {
+		impl.logger.Errorw("error in computing commit stats", "location", location, "commit", hash, "err", result.Error)
+		return COMMIT_STATS_STATUS_FAILED
+	}
+	if saved && result.Partial {
+		return ""
+	}
+	impl.saveStats(hash, result.FileStats, result.Partial)
+	return ""
+}
+
+func (impl *CommitStatsServiceImpl) findSavedStats(hashes []string) (map[string]savedCommitStats, error) {
+	commitStats, err := impl.commitStatsRepository.FindByCommitHashes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	savedStats := make(map[string]savedCommitStats, len(commitStats))
+	for _, stats := range commitStats {
+		var fileStats object.FileStats
+		if len(stats.FileStats) > 0 {
+			err = json.Unmarshal([]byte(stats.FileStats), &fileStats)
+			if err != nil {
+				impl.logger.Errorw("error in parsing saved commit stats", "commit", stats.CommitHash, "err", err)
+				continue
+			}
+		}
+		savedStats[stats.CommitHash] = savedCommitStats{fileStats: fileStats, partial: stats.Partial}
+	}
+	return savedStats, nil
+}
+
+func (impl *CommitStatsServiceImpl) saveStats(hash string, fileStats object.FileStats, partial bool) {
+	commitStats := &sql.CommitStats{
+		CommitHash: hash,
+		Partial:    partial,
+		CreatedOn:  time.Now(),
+	}
+	if len(fileStats) > 0 {
+		b, err := json.Marshal(fileStats)
+		if err != nil {
+			impl.logger.Errorw("error in serializing commit stats", "commit", hash, "err", err)
+			return
+		}
+		commitStats.FileStats = string(b)
+	}
+	err := impl.commitStatsRepository.Save(commitStats)
+	if err != nil {
+		impl.logger.Errorw("error in saving commit stats", "commit", hash, "err", err)
+	}
+}
+
+// isPartialClone tells if repository at location is a blobless or treeless clone, git marks the remote it lazily
+// fetches missing objects from as promisor
+func isPartialClone(location string) bool {
+	repository, err := git.PlainOpen(location)
+	if err != nil {
+		// stats are not completed from a repository which can not be read
+		return true
+	}
+	cfg, err := repository.Config()
+	if err != nil {
+		return true
+	}
+	return cfg.Raw.Section("remote").Subsection("origin").Option("promisor") == "true"
+}
+
+// this function gives file stats in untimed manner. There is no timeout for this, it is called only by background
+// computation
+func (impl *CommitStatsServiceImpl) getUntimedFileStats(commit *object.Commit) (result FileStatsResult) {
+	defer func() {
+		if err := recover(); err != nil {
+			// sometimes the Patch generation will fail due to a known bug in
+			// sergi's go-diff: https://github.com/sergi/go-diff/issues/89.
+			impl.logger.Errorw("panic error in commit getStats", "commitHash", commit.Hash.String(), "err", err)
+			result = FileStatsResult{Error: fmt.Errorf("panic in computing stats of commit %s: %v", commit.Hash.String(), err)}
+		}
+	}()
+
+	fs, err := commit.Stats()
+	if err == plumbing.ErrObjectNotFound {
+		// blobs or parent are not present in partial or shallow clone, changed paths are listed without line stats
+		fs, err = impl.getChangedFileNames(commit)
+		return FileStatsResult{
+			FileStats: fs,
+			Partial:   true,
+			Error:     err,
+		}
+	}
+	return FileStatsResult{
+		FileStats: fs,
+		Error:     err,
+	}
+}
+
+// getChangedFileNames lists paths changed by commit from trees only, errCommitStatsUnavailable is returned
+// when trees are not present either i.e. treeless clone or shallow boundary commit
+func (impl *CommitStatsServiceImpl) getChangedFileNames(commit *object.Commit) (object.FileStats, error) {
+	tree, err := commit.Tree()
+	if err == plumbing.ErrObjectNotFound {
+		impl.logger.Debugw("tree not available for commit", "commitHash", commit.Hash.String())
+		return nil, errCommitStatsUnavailable
+	} else if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err == nil {
+			parentTree, err = parent.Tree()
+		}
+		if err == plumbing.ErrObjectNotFound {
+			impl.logger.Debugw("parent not available for commit", "commitHash", commit.Hash.String())
+			return nil, errCommitStatsUnavailable
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, errCommitStatsUnavailable
+	} else if err != nil {
+		return nil, err
+	}
+	return fileNamesToStats(changes), nil
+}
+
+func fileStatNames(fileStats object.FileStats) []string {
+	var names []string
+	for _, f := range fileStats {
+		names = append(names, f.Name)
+	}
+	return names
+}
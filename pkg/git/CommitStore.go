@@ -0,0 +1,323 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"github.com/go-pg/pg"
+	"go.uber.org/zap"
+)
+
+// CommitStoreService keeps commits seen in repository of git material, and history of branches of pipeline materials
+// as ordered references to them. History of branch is returned newest first. Stats are not stored with commits, commits
+// read with location get them from CommitStatsService
+type CommitStoreService interface {
+	SaveCommits(gitMaterialId int, commits []*GitCommit) error
+	SaveBranchCommits(gitMaterialId int, ciPipelineMaterialId int, branch string, commits []*GitCommit, reset bool) error
+	SaveOlderBranchCommits(gitMaterialId int, ciPipelineMaterialId int, branch string, commits []*GitCommit) error
+	GetBranchCommits(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, limit int) ([]*GitCommit, error)
+	GetBranchHistoryPage(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, cursor *StoredHistoryCursor, size int) ([]*GitCommit, *StoredHistoryCursor, error)
+	GetStoredBranchCommits(ciPipelineMaterialId int, branch string, commitHashes []string) (map[string]bool, error)
+	GetBranchCommit(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, commitHash string) (*GitCommit, error)
+	GetCommit(gitMaterialId int, location string, commitHash string) (*GitCommit, error)
+	GetCommits(gitMaterialId int, commitHashes []string) (map[string]*GitCommit, error)
+}
+
+type CommitStoreServiceImpl struct {
+	logger              *zap.SugaredLogger
+	gitCommitRepository sql.GitCommitRepository
+	commitStatsService  CommitStatsService
+}
+
+func NewCommitStoreServiceImpl(logger *zap.SugaredLogger, gitCommitRepository sql.GitCommitRepository,
+	commitStatsService CommitStatsService) *CommitStoreServiceImpl {
+	return &CommitStoreServiceImpl{
+		logger:              logger,
+		gitCommitRepository: gitCommitRepository,
+		commitStatsService:  commitStatsService,
+	}
+}
+
+func (impl CommitStoreServiceImpl) SaveCommits(gitMaterialId int, commits []*GitCommit) error {
+	var models []*sql.GitCommit
+	saved := make(map[string]bool)
+	for _, commit := range commits {
+		// a row can be upserted only once in a statement
+		if commit == nil || saved[commit.Commit] {
+			continue
+		}
+		saved[commit.Commit] = true
+		models = append(models, toGitCommitModel(gitMaterialId, commit))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	err := impl.gitCommitRepository.Save(models)
+	if err != nil {
+		impl.logger.Errorw("error in saving commits", "gitMaterialId", gitMaterialId, "err", err)
+	}
+	return err
+}
+
+// SaveBranchCommits saves commits, newest first, as the latest commits of branch. Commits already in history of branch
+// are moved up, reset drops the previous history e.g. when it was rewritten
+func (impl CommitStoreServiceImpl) SaveBranchCommits(gitMaterialId int, ciPipelineMaterialId int, branch string, commits []*GitCommit, reset bool) error {
+	err := impl.SaveCommits(gitMaterialId, commits)
+	if err != nil {
+		return err
+	}
+	lastOrder := 0
+	if !reset {
+		latest, err := impl.gitCommitRepository.FindBranchCommits(ciPipelineMaterialId, branch, 1)
+		if err != nil {
+			impl.logger.Errorw("error in getting history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+			return err
+		}
+		if len(latest) > 0 {
+			lastOrder = latest[0].CommitOrder
+		}
+	}
+	var branchCommits []*sql.GitBranchCommit
+	saved := make(map[string]bool)
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		if commit == nil || saved[commit.Commit] {
+			continue
+		}
+		saved[commit.Commit] = true
+		lastOrder++
+		branchCommits = append(branchCommits, &sql.GitBranchCommit{
+			CiPipelineMaterialId: ciPipelineMaterialId,
+			BranchName:           branch,
+			CommitHash:           commit.Commit,
+			CommitOrder:          lastOrder,
+			SkipReason:           commit.SkipReason,
+			Warning:              commit.Warning,
+			CreatedOn:            time.Now(),
+		})
+	}
+	if reset {
+		err = impl.gitCommitRepository.ReplaceBranchCommits(ciPipelineMaterialId, branch, branchCommits)
+	} else if len(branchCommits) > 0 {
+		err = impl.gitCommitRepository.SaveBranchCommits(branchCommits)
+	}
+	if err != nil {
+		impl.logger.Errorw("error in saving history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+	}
+	return err
+}
+
+// SaveOlderBranchCommits saves commits, newest first, below the oldest commit of stored history of branch. Commits
+// already in history of branch keep their position
+func (impl CommitStoreServiceImpl) SaveOlderBranchCommits(gitMaterialId int, ciPipelineMaterialId int, branch string, commits []*GitCommit) error {
+	err := impl.SaveCommits(gitMaterialId, commits)
+	if err != nil {
+		return err
+	}
+	nextOrder := 0
+	oldest, err := impl.gitCommitRepository.FindOldestBranchCommit(ciPipelineMaterialId, branch)
+	if err == nil {
+		nextOrder = oldest.CommitOrder - 1
+	} else if err != pg.ErrNoRows {
+		impl.logger.Errorw("error in getting history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+		return err
+	}
+	var branchCommits []*sql.GitBranchCommit
+	saved := make(map[string]bool)
+	for _, commit := range commits {
+		if commit == nil || saved[commit.Commit] {
+			continue
+		}
+		saved[commit.Commit] = true
+		branchCommits = append(branchCommits, &sql.GitBranchCommit{
+			CiPipelineMaterialId: ciPipelineMaterialId,
+			BranchName:           branch,
+			CommitHash:           commit.Commit,
+			CommitOrder:          nextOrder,
+			SkipReason:           commit.SkipReason,
+			Warning:              commit.Warning,
+			CreatedOn:            time.Now(),
+		})
+		nextOrder--
+	}
+	if len(branchCommits) == 0 {
+		return nil
+	}
+	err = impl.gitCommitRepository.InsertBranchCommits(branchCommits)
+	if err != nil {
+		impl.logger.Errorw("error in saving older history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+	}
+	return err
+}
+
+func (impl CommitStoreServiceImpl) GetBranchCommits(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, limit int) ([]*GitCommit, error) {
+	branchCommits, err := impl.gitCommitRepository.FindBranchCommits(ciPipelineMaterialId, branch, limit)
+	if err != nil {
+		impl.logger.Errorw("error in getting history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+		return nil, err
+	}
+	return impl.toBranchHistory(gitMaterialId, location, branchCommits)
+}
+
+// GetBranchHistoryPage returns a page of stored history of branch from cursor, nil cursor starting at head. Cursor of
+// next page is nil when no older commit is stored
+func (impl CommitStoreServiceImpl) GetBranchHistoryPage(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, cursor *StoredHistoryCursor, size int) ([]*GitCommit, *StoredHistoryCursor, error) {
+	var branchCommits []*sql.GitBranchCommit
+	var err error
+	// one more commit than page is read to know if there is a next page
+	if cursor == nil {
+		branchCommits, err = impl.gitCommitRepository.FindBranchCommits(ciPipelineMaterialId, branch, size+1)
+	} else {
+		branchCommits, err = impl.gitCommitRepository.FindBranchCommitsBefore(ciPipelineMaterialId, branch, cursor.BeforeOrder, size+1)
+	}
+	if err != nil {
+		impl.logger.Errorw("error in getting history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+		return nil, nil, err
+	}
+	var nextCursor *StoredHistoryCursor
+	if len(branchCommits) > size {
+		branchCommits = branchCommits[:size]
+		nextCursor = &StoredHistoryCursor{BeforeOrder: branchCommits[size-1].CommitOrder}
+	}
+	commits, err := impl.toBranchHistory(gitMaterialId, location, branchCommits)
+	return commits, nextCursor, err
+}
+
+// GetStoredBranchCommits tells which of the commits are in stored history of branch
+func (impl CommitStoreServiceImpl) GetStoredBranchCommits(ciPipelineMaterialId int, branch string, commitHashes []string) (map[string]bool, error) {
+	branchCommits, err := impl.gitCommitRepository.FindBranchCommitsByHashes(ciPipelineMaterialId, branch, commitHashes)
+	if err != nil {
+		impl.logger.Errorw("error in getting commits of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+		return nil, err
+	}
+	stored := make(map[string]bool, len(branchCommits))
+	for _, branchCommit := range branchCommits {
+		stored[branchCommit.CommitHash] = true
+	}
+	return stored, nil
+}
+
+// GetBranchCommit returns commit if it is in history of branch, nil otherwise
+func (impl CommitStoreServiceImpl) GetBranchCommit(gitMaterialId int, location string, ciPipelineMaterialId int, branch string, commitHash string) (*GitCommit, error) {
+	branchCommit, err := impl.gitCommitRepository.FindBranchCommit(ciPipelineMaterialId, branch, commitHash)
+	if err == pg.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		impl.logger.Errorw("error in getting commit of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "commit", commitHash, "err", err)
+		return nil, err
+	}
+	commits, err := impl.toBranchHistory(gitMaterialId, location, []*sql.GitBranchCommit{branchCommit})
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+	return commits[0], nil
+}
+
+// GetCommit returns commit with its stats if it is stored, nil otherwise
+func (impl CommitStoreServiceImpl) GetCommit(gitMaterialId int, location string, commitHash string) (*GitCommit, error) {
+	commits, err := impl.GetCommits(gitMaterialId, []string{commitHash})
+	if err != nil {
+		return nil, err
+	}
+	commit, ok := commits[commitHash]
+	if !ok {
+		return nil, nil
+	}
+	impl.fillStats(location, []*GitCommit{commit})
+	return commit, nil
+}
+
+// GetCommits returns stored commits by hash, without stats
+func (impl CommitStoreServiceImpl) GetCommits(gitMaterialId int, commitHashes []string) (map[string]*GitCommit, error) {
+	models, err := impl.gitCommitRepository.FindByGitMaterialIdAndCommitHashes(gitMaterialId, commitHashes)
+	if err != nil {
+		impl.logger.Errorw("error in getting commits", "gitMaterialId", gitMaterialId, "err", err)
+		return nil, err
+	}
+	commitMap := make(map[string]*GitCommit, len(models))
+	for _, model := range models {
+		commitMap[model.CommitHash] = impl.fromGitCommitModel(model)
+	}
+	return commitMap, nil
+}
+
+func (impl CommitStoreServiceImpl) toBranchHistory(gitMaterialId int, location string, branchCommits []*sql.GitBranchCommit) ([]*GitCommit, error) {
+	hashes := make([]string, 0, len(branchCommits))
+	for _, branchCommit := range branchCommits {
+		hashes = append(hashes, branchCommit.CommitHash)
+	}
+	commitMap, err := impl.GetCommits(gitMaterialId, hashes)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]*GitCommit, 0, len(branchCommits))
+	for _, branchCommit := range branchCommits {
+		commit, ok := commitMap[branchCommit.CommitHash]
+		if !ok {
+			impl.logger.Warnw("commit of branch not found", "gitMaterialId", gitMaterialId, "commit", branchCommit.CommitHash)
+			continue
+		}
+		commit.SkipReason = branchCommit.SkipReason
+		commit.Warning = branchCommit.Warning
+		commits = append(commits, commit)
+	}
+	impl.fillStats(location, commits)
+	return commits, nil
+}
+
+// fillStats sets saved stats of commits, stats not computed yet are queued and commits are marked pending
+func (impl CommitStoreServiceImpl) fillStats(location string, commits []*GitCommit) {
+	for _, commit := range commits {
+		commit.StatsStatus = COMMIT_STATS_STATUS_PENDING
+	}
+	impl.commitStatsService.FillStats(location, commits)
+}
+
+func toGitCommitModel(gitMaterialId int, commit *GitCommit) *sql.GitCommit {
+	model := &sql.GitCommit{
+		GitMaterialId: gitMaterialId,
+		CommitHash:    commit.Commit,
+		Author:        commit.Author,
+		CommitDate:    commit.Date,
+		Message:       commit.Message,
+		CreatedOn:     time.Now(),
+	}
+	if len(commit.SubmoduleChanges) > 0 {
+		submoduleChanges, _ := json.Marshal(commit.SubmoduleChanges)
+		model.SubmoduleChanges = string(submoduleChanges)
+	}
+	return model
+}
+
+func (impl CommitStoreServiceImpl) fromGitCommitModel(model *sql.GitCommit) *GitCommit {
+	commit := &GitCommit{
+		Commit:  model.CommitHash,
+		Author:  model.Author,
+		Date:    model.CommitDate,
+		Message: model.Message,
+	}
+	if len(model.SubmoduleChanges) > 0 {
+		err := json.Unmarshal([]byte(model.SubmoduleChanges), &commit.SubmoduleChanges)
+		if err != nil {
+			impl.logger.Errorw("error in parsing submodule changes of commit", "commit", model.CommitHash, "err", err)
+		}
+	}
+	return commit
+}
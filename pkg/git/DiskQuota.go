@@ -0,0 +1,413 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/caarlos0/env"
+	"github.com/devtron-labs/git-sensor/internal"
+	"github.com/devtron-labs/git-sensor/internal/middleware"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"github.com/devtron-labs/git-sensor/util"
+	"github.com/nats-io/nats.go"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+type DiskQuotaConfig struct {
+	GitBaseQuotaInMb         int64 `env:"GIT_BASE_QUOTA_IN_MB" envDefault:"0"` // 0 uses size of the volume of GIT_BASE_DIR
+	DiskHighWatermarkPercent int   `env:"DISK_HIGH_WATERMARK_PERCENT" envDefault:"85"`
+	DiskLowWatermarkPercent  int   `env:"DISK_LOW_WATERMARK_PERCENT" envDefault:"70"`
+	DiskCriticalPercent      int   `env:"DISK_CRITICAL_PERCENT" envDefault:"95"`
+	DiskQuotaCheckInMin      int   `env:"DISK_QUOTA_CHECK_IN_MIN" envDefault:"5"`
+	DiskEvictionEnabled      bool  `env:"DISK_EVICTION_ENABLED" envDefault:"true"`
+}
+
+type DiskQuotaLevel string
+
+const (
+	DISK_QUOTA_LEVEL_NORMAL   DiskQuotaLevel = "NORMAL"
+	DISK_QUOTA_LEVEL_HIGH     DiskQuotaLevel = "HIGH"     // above high watermark, repositories are evicted
+	DISK_QUOTA_LEVEL_CRITICAL DiskQuotaLevel = "CRITICAL" // no headroom, new repositories are refused
+)
+
+const (
+	EVICTION_REASON_DELETED  = "deleted"
+	EVICTION_REASON_INACTIVE = "inactive"
+	EVICTION_REASON_ACTIVE   = "active"
+)
+
+type DiskUsage struct {
+	UsedBytes     int64          `json:"usedBytes"`
+	CapacityBytes int64          `json:"capacityBytes"`
+	UsedPercent   float64        `json:"usedPercent"`
+	Level         DiskQuotaLevel `json:"level"`
+	MeasuredOn    time.Time      `json:"measuredOn"`
+}
+
+type DiskQuotaStateChange struct {
+	Level              DiskQuotaLevel `json:"level"`
+	PreviousLevel      DiskQuotaLevel `json:"previousLevel"`
+	UsedBytes          int64          `json:"usedBytes"`
+	CapacityBytes      int64          `json:"capacityBytes"`
+	UsedPercent        float64        `json:"usedPercent"`
+	EvictedMaterialIds []int          `json:"evictedMaterialIds"`
+}
+
+type DiskQuotaService interface {
+	CheckHeadroom() error
+	GetDiskUsage() *DiskUsage
+}
+
+type diskQuotaState struct {
+	lock  sync.RWMutex
+	usage *DiskUsage
+}
+
+type evictionCandidate struct {
+	location     string
+	reason       string
+	lastUsedTime time.Time
+	materialIds  []int
+}
+
+type DiskQuotaServiceImpl struct {
+	logger            *zap.SugaredLogger
+	materialRepo      sql.MaterialRepository
+	repositoryManager RepositoryManager
+	locker            *internal.RepositoryLocker
+	pubSubClient      *internal.PubSubClient
+	cron              *cron.Cron
+	config            *DiskQuotaConfig
+	state             *diskQuotaState
+}
+
+func NewDiskQuotaServiceImpl(logger *zap.SugaredLogger, materialRepo sql.MaterialRepository,
+	repositoryManager RepositoryManager, locker *internal.RepositoryLocker,
+	pubSubClient *internal.PubSubClient) (*DiskQuotaServiceImpl, error) {
+	cfg := &DiskQuotaConfig{}
+	err := env.Parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cronLogger := &CronLoggerImpl{logger: logger}
+	cron := cron.New(
+		cron.WithChain(
+			cron.SkipIfStillRunning(cronLogger),
+			cron.Recover(cronLogger)))
+	cron.Start()
+	impl := &DiskQuotaServiceImpl{
+		logger:            logger,
+		materialRepo:      materialRepo,
+		repositoryManager: repositoryManager,
+		locker:            locker,
+		pubSubClient:      pubSubClient,
+		cron:              cron,
+		config:            cfg,
+		state:             &diskQuotaState{},
+	}
+	_, err = cron.AddFunc(fmt.Sprintf("@every %dm", cfg.DiskQuotaCheckInMin), impl.checkDiskQuota)
+	if err != nil {
+		logger.Errorw("error in starting disk quota cron", "err", err)
+		return nil, err
+	}
+	return impl, nil
+}
+
+func (impl DiskQuotaServiceImpl) StopCron() {
+	impl.cron.Stop()
+}
+
+// GetDiskUsage returns usage measured in last check, nil if not measured yet
+func (impl DiskQuotaServiceImpl) GetDiskUsage() *DiskUsage {
+	impl.state.lock.RLock()
+	defer impl.state.lock.RUnlock()
+	return impl.state.usage
+}
+
+// CheckHeadroom fails if usage measured in last check has crossed the critical threshold
+func (impl DiskQuotaServiceImpl) CheckHeadroom() error {
+	usage := impl.GetDiskUsage()
+	if usage == nil || usage.Level != DISK_QUOTA_LEVEL_CRITICAL {
+		return nil
+	}
+	return fmt.Errorf("disk quota exceeded: %d of %d bytes (%.1f%%) used at %s, no headroom left to add repository",
+		usage.UsedBytes, usage.CapacityBytes, usage.UsedPercent, GIT_BASE_DIR)
+}
+
+// checkDiskQuota records disk usage of every repository, and evicts repositories when usage is above high watermark
+// until it is below low watermark
+func (impl DiskQuotaServiceImpl) checkDiskQuota() {
+	materials, err := impl.materialRepo.FindActive()
+	if err != nil {
+		impl.logger.Errorw("error in fetching materials for disk quota", "err", err)
+		return
+	}
+	deletedMaterials, err := impl.materialRepo.FindDeletedWithCheckout()
+	if err != nil {
+		impl.logger.Errorw("error in fetching deleted materials for disk quota", "err", err)
+		return
+	}
+	candidates := impl.getEvictionCandidates(materials, deletedMaterials)
+	sizes := make(map[string]int64)
+	for _, candidate := range candidates {
+		size, err := getDirSize(candidate.location)
+		if err != nil {
+			impl.logger.Errorw("error in measuring disk usage of repository", "location", candidate.location, "err", err)
+			continue
+		}
+		sizes[candidate.location] = size
+		err = impl.materialRepo.UpdateDiskUsageByLocation(candidate.location, size)
+		if err != nil {
+			impl.logger.Errorw("error in updating disk usage", "location", candidate.location, "err", err)
+		}
+	}
+	usage, err := impl.measureUsage()
+	if err != nil {
+		impl.logger.Errorw("error in measuring disk usage", "dir", GIT_BASE_DIR, "err", err)
+		return
+	}
+	var evictedMaterialIds []int
+	if usage.Level != DISK_QUOTA_LEVEL_NORMAL && impl.config.DiskEvictionEnabled {
+		bytesToFree := usage.UsedBytes - usage.CapacityBytes*int64(impl.config.DiskLowWatermarkPercent)/100
+		evictedMaterialIds = impl.evict(candidates, sizes, bytesToFree)
+		if len(evictedMaterialIds) > 0 {
+			usage, err = impl.measureUsage()
+			if err != nil {
+				impl.logger.Errorw("error in measuring disk usage", "dir", GIT_BASE_DIR, "err", err)
+				return
+			}
+		}
+	}
+	impl.recordUsage(usage, evictedMaterialIds)
+}
+
+// getEvictionCandidates returns repositories on disk in order of eviction, those used only by deleted materials first,
+// then those without active pipelines least recently used first. Repositories with active pipelines come last, also
+// least recently used first, as evicting them makes next poll clone them again
+func (impl DiskQuotaServiceImpl) getEvictionCandidates(materials []*sql.GitMaterial, deletedMaterials []*sql.GitMaterial) []*evictionCandidate {
+	var deleted, inactive, active []*evictionCandidate
+	candidates := make(map[string]*evictionCandidate)
+	hasPipelines := make(map[string]bool)
+	evicted := make(map[string]bool)
+	for _, material := range materials {
+		location := material.CheckoutLocation
+		evicted[location] = evicted[location] || material.Evicted
+		candidate, ok := candidates[location]
+		if !ok {
+			candidate = &evictionCandidate{location: location}
+			candidates[location] = candidate
+		}
+		candidate.materialIds = append(candidate.materialIds, material.Id)
+		if material.LastUsedTime.After(candidate.lastUsedTime) {
+			candidate.lastUsedTime = material.LastUsedTime
+		}
+		hasPipelines[location] = hasPipelines[location] || len(material.CiPipelineMaterials) > 0
+	}
+	for _, material := range deletedMaterials {
+		location := material.CheckoutLocation
+		if location == "" {
+			continue
+		}
+		candidate, ok := candidates[location]
+		if !ok {
+			candidate = &evictionCandidate{location: location, reason: EVICTION_REASON_DELETED}
+			candidates[location] = candidate
+			deleted = append(deleted, candidate)
+		}
+		if candidate.reason == EVICTION_REASON_DELETED {
+			candidate.materialIds = append(candidate.materialIds, material.Id)
+		}
+	}
+	for location, candidate := range candidates {
+		if candidate.reason == EVICTION_REASON_DELETED || evicted[location] {
+			continue
+		}
+		if hasPipelines[location] {
+			candidate.reason = EVICTION_REASON_ACTIVE
+			active = append(active, candidate)
+		} else {
+			candidate.reason = EVICTION_REASON_INACTIVE
+			inactive = append(inactive, candidate)
+		}
+	}
+	byLastUsed := func(candidates []*evictionCandidate) {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].lastUsedTime.Equal(candidates[j].lastUsedTime) {
+				return candidates[i].location < candidates[j].location
+			}
+			return candidates[i].lastUsedTime.Before(candidates[j].lastUsedTime)
+		})
+	}
+	byLastUsed(inactive)
+	byLastUsed(active)
+	result := append(deleted, inactive...)
+	return append(result, active...)
+}
+
+func (impl DiskQuotaServiceImpl) evict(candidates []*evictionCandidate, sizes map[string]int64, bytesToFree int64) []int {
+	var evictedMaterialIds []int
+	var freed int64
+	for _, candidate := range candidates {
+		if freed >= bytesToFree {
+			break
+		}
+		size, ok := sizes[candidate.location]
+		if !ok || size == 0 {
+			continue
+		}
+		err := impl.evictLocation(candidate)
+		if err != nil {
+			impl.logger.Errorw("error in evicting repository", "location", candidate.location, "err", err)
+			continue
+		}
+		freed += size
+		evictedMaterialIds = append(evictedMaterialIds, candidate.materialIds...)
+	}
+	impl.logger.Infow("evicted repositories to free disk", "bytesToFree", bytesToFree, "freed", freed, "materialIds", evictedMaterialIds)
+	return evictedMaterialIds
+}
+
+// evictLocation removes repository from disk under its lock, materials using it clone it again on next use
+func (impl DiskQuotaServiceImpl) evictLocation(candidate *evictionCandidate) error {
+	repoLock := impl.locker.LeaseLocker(candidate.location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(candidate.location)
+	}()
+	impl.logger.Infow("evicting repository", "location", candidate.location, "reason", candidate.reason, "materialIds", candidate.materialIds)
+	err := impl.repositoryManager.Clean(candidate.location)
+	if err != nil {
+		return err
+	}
+	err = impl.materialRepo.UpdateEvictedByLocation(candidate.location, true)
+	if err != nil {
+		return err
+	}
+	middleware.GitStoreEvictionCounter.WithLabelValues(candidate.reason).Inc()
+	return nil
+}
+
+// measureUsage returns usage of GIT_BASE_DIR against quota if configured, else usage of its volume
+func (impl DiskQuotaServiceImpl) measureUsage() (*DiskUsage, error) {
+	usage := &DiskUsage{MeasuredOn: time.Now()}
+	if impl.config.GitBaseQuotaInMb > 0 {
+		used, err := getDirSize(GIT_BASE_DIR)
+		if err != nil {
+			return nil, err
+		}
+		usage.UsedBytes = used
+		usage.CapacityBytes = impl.config.GitBaseQuotaInMb * 1024 * 1024
+	} else {
+		var stat syscall.Statfs_t
+		err := syscall.Statfs(GIT_BASE_DIR, &stat)
+		if err != nil {
+			return nil, err
+		}
+		usage.CapacityBytes = int64(stat.Blocks) * int64(stat.Bsize)
+		usage.UsedBytes = int64(stat.Blocks-stat.Bavail) * int64(stat.Bsize)
+	}
+	if usage.CapacityBytes > 0 {
+		usage.UsedPercent = float64(usage.UsedBytes) * 100 / float64(usage.CapacityBytes)
+	}
+	switch {
+	case usage.UsedPercent >= float64(impl.config.DiskCriticalPercent):
+		usage.Level = DISK_QUOTA_LEVEL_CRITICAL
+	case usage.UsedPercent >= float64(impl.config.DiskHighWatermarkPercent):
+		usage.Level = DISK_QUOTA_LEVEL_HIGH
+	default:
+		usage.Level = DISK_QUOTA_LEVEL_NORMAL
+	}
+	return usage, nil
+}
+
+func (impl DiskQuotaServiceImpl) recordUsage(usage *DiskUsage, evictedMaterialIds []int) {
+	impl.state.lock.Lock()
+	previous := impl.state.usage
+	impl.state.usage = usage
+	impl.state.lock.Unlock()
+
+	middleware.GitBaseDiskUsageBytes.WithLabelValues().Set(float64(usage.UsedBytes))
+	middleware.GitBaseDiskCapacityBytes.WithLabelValues().Set(float64(usage.CapacityBytes))
+	previousLevel := DISK_QUOTA_LEVEL_NORMAL
+	if previous != nil {
+		previousLevel = previous.Level
+	}
+	if usage.Level == previousLevel && len(evictedMaterialIds) == 0 {
+		return
+	}
+	if usage.Level != previousLevel && usage.Level != DISK_QUOTA_LEVEL_NORMAL {
+		middleware.GitBaseDiskQuotaBreachCounter.WithLabelValues(string(usage.Level)).Inc()
+	}
+	impl.logger.Warnw("disk quota state change", "level", usage.Level, "previousLevel", previousLevel,
+		"usedBytes", usage.UsedBytes, "capacityBytes", usage.CapacityBytes, "evictedMaterialIds", evictedMaterialIds)
+	impl.notifyForDiskQuotaStateChange(&DiskQuotaStateChange{
+		Level:              usage.Level,
+		PreviousLevel:      previousLevel,
+		UsedBytes:          usage.UsedBytes,
+		CapacityBytes:      usage.CapacityBytes,
+		UsedPercent:        usage.UsedPercent,
+		EvictedMaterialIds: evictedMaterialIds,
+	})
+}
+
+func (impl DiskQuotaServiceImpl) notifyForDiskQuotaStateChange(stateChange *DiskQuotaStateChange) {
+	mb, err := json.Marshal(stateChange)
+	if err != nil {
+		impl.logger.Errorw("err in json marshaling", "err", err)
+		return
+	}
+	err = internal.AddStream(impl.pubSubClient.JetStrCtxt, internal.GIT_SENSOR_STREAM)
+	if err != nil {
+		impl.logger.Errorw("Error while adding stream", "error", err)
+	}
+	//Generate random string for passing as Header Id in message
+	randString := "MsgHeaderId-" + util.Generate(10)
+	_, err = impl.pubSubClient.JetStrCtxt.Publish(internal.GIT_DISK_QUOTA_STATE_CHANGE_TOPIC, mb, nats.MsgId(randString))
+	if err != nil {
+		impl.logger.Errorw("error in publishing disk quota state change msg", "stateChange", stateChange, "err", err)
+	}
+}
+
+// getDirSize returns space allocated on disk for files under dir, 0 if dir does not exist
+func getDirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			size += stat.Blocks * 512
+		} else {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
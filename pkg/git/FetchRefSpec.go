@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/devtron-labs/git-sensor/internal/sql"
+)
+
+const (
+	ALL_BRANCHES_REF_SPEC = "+refs/heads/*:refs/remotes/origin/*"
+	ALL_TAGS_REF_SPEC     = "+refs/tags/*:refs/tags/*"
+)
+
+var missingRemoteBranchPattern = regexp.MustCompile(`couldn't find remote ref refs/heads/(\S+)`)
+
+// GetFetchRefSpecs derives refspecs to be fetched for a git material from its active pipeline materials.
+// Empty result means no material restricts the fetch and everything (all branches and tags) is fetched.
+func GetFetchRefSpecs(materials []*sql.CiPipelineMaterial) []string {
+	branches := make(map[string]bool)
+	allBranches := false
+	tags := false
+	for _, material := range materials {
+		if !material.Active {
+			continue
+		}
+		switch material.Type {
+		case sql.SOURCE_TYPE_BRANCH_FIXED:
+			branch := strings.TrimPrefix(strings.TrimSpace(material.Value), "refs/heads/")
+			if len(branch) > 0 {
+				branches[branch] = true
+			}
+		case sql.SOURCE_TYPE_BRANCH_REGEX:
+			allBranches = true
+		case sql.SOURCE_TYPE_WEBHOOK:
+			// source and target branches of pull request events can be any branch of repository, and tag creation
+			// events resolve commits from tags
+			allBranches = true
+			tags = true
+		case sql.SOURCE_TYPE_TAG_ANY:
+			tags = true
+		}
+	}
+	var refSpecs []string
+	if allBranches {
+		refSpecs = append(refSpecs, ALL_BRANCHES_REF_SPEC)
+	} else {
+		for branch := range branches {
+			refSpecs = append(refSpecs, branchRefSpec(branch))
+		}
+		sort.Strings(refSpecs)
+	}
+	if tags {
+		refSpecs = append(refSpecs, ALL_TAGS_REF_SPEC)
+	}
+	return refSpecs
+}
+
+// branchRefSpec fetches exactly the branch, fetch fails once branch is deleted from remote and is retried without it
+func branchRefSpec(branch string) string {
+	return "+refs/heads/" + branch + ":" + REMOTE_BRANCH_REF_PREFIX + branch
+}
+
+// missingRemoteBranch returns branch which git fetch could not find on remote, blank if fetch failed for other reason
+func missingRemoteBranch(errMsg string) string {
+	match := missingRemoteBranchPattern.FindStringSubmatch(errMsg)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func removeRefSpec(refSpecs []string, refSpec string) []string {
+	var remaining []string
+	for _, r := range refSpecs {
+		if r != refSpec {
+			remaining = append(remaining, r)
+		}
+	}
+	return remaining
+}
+
+func refSpecsEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RefSpecsChanged tells if refspecs stored on git material differ from the given ones
+func RefSpecsChanged(material *sql.GitMaterial, refSpecs []string) bool {
+	return !refSpecsEqual(material.FetchRefSpecs, refSpecs)
+}
+
+var commitHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// untrackedBranchRefSpecs returns refspecs of refs which may be branches not fetched by refspecs of git material.
+// Commit hashes, tags and revision expressions are left out, refs which are not branches on remote are dropped by
+// fetch itself
+func untrackedBranchRefSpecs(refSpecs []string, refs ...string) []string {
+	if len(refSpecs) == 0 {
+		return nil
+	}
+	for _, refSpec := range refSpecs {
+		if refSpec == ALL_BRANCHES_REF_SPEC {
+			return nil
+		}
+	}
+	var untracked []string
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if strings.HasPrefix(ref, "refs/heads/") {
+			ref = strings.TrimPrefix(ref, "refs/heads/")
+		} else if strings.HasPrefix(ref, "refs/") {
+			continue
+		}
+		if len(ref) == 0 || ref == "HEAD" || commitHashPattern.MatchString(ref) || strings.ContainsAny(ref, "~^:?*[\\ ") || strings.Contains(ref, "..") {
+			continue
+		}
+		refSpec := branchRefSpec(ref)
+		if len(removeRefSpec(refSpecs, refSpec)) < len(refSpecs) || len(removeRefSpec(untracked, refSpec)) < len(untracked) {
+			continue
+		}
+		untracked = append(untracked, refSpec)
+	}
+	return untracked
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+const (
+	DEFAULT_DIFF_PAGE_SIZE      = 20
+	MAX_DIFF_PAGE_SIZE          = 100
+	DEFAULT_DIFF_BYTES_PER_FILE = 64 * 1024
+	MAX_DIFF_BYTES_PER_FILE     = 1024 * 1024
+)
+
+// GetFileDiff returns unified diff of each file changed between old and new ref, for a page of changed files
+func (impl RepositoryManagerImpl) GetFileDiff(ctx context.Context, checkoutPath string, userName, password string, request *FileDiffRequest) (*FileDiffResponse, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	oldHash, err := resolveCommitHash(repository, request.OldRef)
+	if err != nil {
+		impl.logger.Errorw("error in resolving old ref", "path", checkoutPath, "ref", request.OldRef, "err", err)
+		return nil, err
+	}
+	newHash, err := resolveCommitHash(repository, request.NewRef)
+	if err != nil {
+		impl.logger.Errorw("error in resolving new ref", "path", checkoutPath, "ref", request.NewRef, "err", err)
+		return nil, err
+	}
+	offset, size, maxBytes := getFileDiffPage(request)
+	response := &FileDiffResponse{
+		OldCommit: oldHash.String(),
+		NewCommit: newHash.String(),
+		Offset:    offset,
+		Size:      size,
+	}
+
+	files, err := impl.listChangedFiles(ctx, checkoutPath, userName, password, response.OldCommit, response.NewCommit)
+	if err != nil {
+		return nil, err
+	}
+	response.TotalFiles = len(files)
+	if offset >= len(files) {
+		response.Files = []*FileDiff{}
+		return response, nil
+	}
+	end := offset + size
+	if end > len(files) {
+		end = len(files)
+	}
+	response.Files = files[offset:end]
+	for _, file := range response.Files {
+		if file.Binary {
+			continue
+		}
+		patch := &cappedBuffer{limit: maxBytes}
+		errMsg, err := impl.gitUtil.Diff(ctx, checkoutPath, userName, password, patch, fileDiffArgs(file, response.OldCommit, response.NewCommit)...)
+		if err != nil {
+			impl.logger.Errorw("error in computing diff of file", "path", checkoutPath, "file", file.NewPath, "errorMsg", errMsg, "err", err)
+			return nil, fmt.Errorf("error in computing diff of %s: %s", file.NewPath, strings.TrimSpace(errMsg))
+		}
+		file.Patch, file.Truncated = patch.Content()
+	}
+	return response, nil
+}
+
+// listChangedFiles lists files changed between old and new commit with line stats, without patches
+func (impl RepositoryManagerImpl) listChangedFiles(ctx context.Context, checkoutPath string, userName, password string, oldCommit string, newCommit string) ([]*FileDiff, error) {
+	var summary bytes.Buffer
+	errMsg, err := impl.gitUtil.Diff(ctx, checkoutPath, userName, password, &summary,
+		"-z", "--raw", "--numstat", "--no-abbrev", "--find-renames", "--find-copies", oldCommit, newCommit)
+	if err != nil {
+		impl.logger.Errorw("error in listing changed files", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in listing changed files: %s", strings.TrimSpace(errMsg))
+	}
+	files, err := parseDiffSummary(summary.Bytes())
+	if err != nil {
+		impl.logger.Errorw("error in parsing changed files", "path", checkoutPath, "err", err)
+		return nil, err
+	}
+	return files, nil
+}
+
+func getFileDiffPage(request *FileDiffRequest) (offset int, size int, maxBytes int) {
+	offset = request.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	size = request.Size
+	if size <= 0 {
+		size = DEFAULT_DIFF_PAGE_SIZE
+	} else if size > MAX_DIFF_PAGE_SIZE {
+		size = MAX_DIFF_PAGE_SIZE
+	}
+	maxBytes = request.MaxBytesPerFile
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_DIFF_BYTES_PER_FILE
+	} else if maxBytes > MAX_DIFF_BYTES_PER_FILE {
+		maxBytes = MAX_DIFF_BYTES_PER_FILE
+	}
+	return offset, size, maxBytes
+}
+
+// fileDiffArgs limits diff to paths of file, source of a copy is modified in the diff too so copied file is diffed
+// against its source directly
+func fileDiffArgs(file *FileDiff, oldCommit string, newCommit string) []string {
+	if file.ChangeType == FILE_CHANGE_TYPE_COPIED {
+		return []string{oldCommit + ":" + file.OldPath, newCommit + ":" + file.NewPath}
+	}
+	args := []string{"--find-renames", oldCommit, newCommit, "--"}
+	if file.OldPath != "" {
+		args = append(args, file.OldPath)
+	}
+	if file.NewPath != "" && file.NewPath != file.OldPath {
+		args = append(args, file.NewPath)
+	}
+	return args
+}
+
+// parseDiffSummary parses output of git diff -z --raw --numstat, raw entries of all files come first and numstat
+// entries follow in the same order
+func parseDiffSummary(output []byte) ([]*FileDiff, error) {
+	tokens := strings.Split(string(output), "\x00")
+	var files []*FileDiff
+	statIndex := 0
+	for i := 0; i < len(tokens); i++ {
+		token := strings.TrimLeft(tokens[i], "\n")
+		if token == "" {
+			continue
+		}
+		if strings.HasPrefix(token, ":") {
+			// :<old mode> <new mode> <old hash> <new hash> <status>
+			fields := strings.Fields(token)
+			if len(fields) != 5 || len(fields[4]) == 0 || i+1 >= len(tokens) {
+				return nil, fmt.Errorf("unexpected raw diff entry %q", token)
+			}
+			file := &FileDiff{}
+			status := fields[4]
+			switch status[0] {
+			case 'A':
+				file.ChangeType = FILE_CHANGE_TYPE_ADDED
+			case 'D':
+				file.ChangeType = FILE_CHANGE_TYPE_DELETED
+			case 'R':
+				file.ChangeType = FILE_CHANGE_TYPE_RENAMED
+			case 'C':
+				file.ChangeType = FILE_CHANGE_TYPE_COPIED
+			case 'T':
+				file.ChangeType = FILE_CHANGE_TYPE_TYPE_CHANGED
+			default:
+				file.ChangeType = FILE_CHANGE_TYPE_MODIFIED
+			}
+			i++
+			switch file.ChangeType {
+			case FILE_CHANGE_TYPE_RENAMED, FILE_CHANGE_TYPE_COPIED:
+				if i+1 >= len(tokens) {
+					return nil, fmt.Errorf("unexpected raw diff entry %q", token)
+				}
+				file.Similarity, _ = strconv.Atoi(status[1:])
+				file.OldPath = tokens[i]
+				i++
+				file.NewPath = tokens[i]
+			case FILE_CHANGE_TYPE_ADDED:
+				file.NewPath = tokens[i]
+			case FILE_CHANGE_TYPE_DELETED:
+				file.OldPath = tokens[i]
+			default:
+				file.OldPath = tokens[i]
+				file.NewPath = tokens[i]
+			}
+			files = append(files, file)
+			continue
+		}
+		// <additions>\t<deletions>\t<path>, path is empty and followed by old and new path for renames and copies
+		fields := strings.SplitN(token, "\t", 3)
+		if len(fields) != 3 || statIndex >= len(files) {
+			return nil, fmt.Errorf("unexpected numstat diff entry %q", token)
+		}
+		if fields[2] == "" {
+			i += 2
+		}
+		file := files[statIndex]
+		statIndex++
+		if fields[0] == "-" && fields[1] == "-" {
+			file.Binary = true
+			continue
+		}
+		file.Additions, _ = strconv.Atoi(fields[0])
+		file.Deletions, _ = strconv.Atoi(fields[1])
+	}
+	return files, nil
+}
+
+// cappedBuffer keeps first limit bytes written to it and discards the rest, without failing the writer
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Content returns content cut at last complete line if it was truncated
+func (b *cappedBuffer) Content() (string, bool) {
+	content := b.buf.String()
+	if b.truncated {
+		if i := strings.LastIndex(content, "\n"); i >= 0 {
+			content = content[:i+1]
+		}
+	}
+	return content, b.truncated
+}
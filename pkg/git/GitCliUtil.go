@@ -1,67 +1,221 @@
 package git
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/devtron-labs/git-sensor/internal"
 	"go.uber.org/zap"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type GitUtil struct {
-	logger *zap.SugaredLogger
+	logger        *zap.SugaredLogger
+	configuration *internal.Configuration
+	versionLock   *sync.Mutex
+	version       []int
 }
 
-func NewGitUtil(logger *zap.SugaredLogger) *GitUtil {
+func NewGitUtil(logger *zap.SugaredLogger, configuration *internal.Configuration) *GitUtil {
 	return &GitUtil{
-		logger: logger,
+		logger:        logger,
+		configuration: configuration,
+		versionLock:   &sync.Mutex{},
 	}
 }
 
 const GIT_ASK_PASS = "/git-ask-pass.sh"
 
-func (impl *GitUtil) Fetch(rootDir string, username string, password string) (response, errMsg string, err error) {
-	impl.logger.Debugw("git fetch ", "location", rootDir)
-	cmd := exec.Command("git", "-C", rootDir, "fetch", "origin", "--tags", "--force")
-	output, errMsg, err := impl.runCommandWithCred(cmd, username, password)
+// objects unreachable for less than this are kept by prune, same as default expiry of git gc
+const PRUNE_EXPIRE = "2.weeks.ago"
+
+var gitVersionPattern = regexp.MustCompile(`git version (\d+)\.(\d+)`)
+
+// GitTimeoutError is returned when git cli operation does not complete within its configured timeout
+type GitTimeoutError struct {
+	Operation string
+	Timeout   time.Duration
+}
+
+func (e *GitTimeoutError) Error() string {
+	return fmt.Sprintf("timeout: git %s did not complete in %s", e.Operation, e.Timeout)
+}
+
+func IsGitTimeoutError(err error) bool {
+	_, ok := err.(*GitTimeoutError)
+	return ok
+}
+
+// Fetch updates already cloned repository, bounded by fetch timeout
+func (impl *GitUtil) Fetch(ctx context.Context, rootDir string, username string, password string, fetchArgs ...string) (response, errMsg string, err error) {
+	return impl.fetch(ctx, impl.timeout(impl.configuration.GitFetchTimeoutInSec, FETCH_TIMEOUT_SEC), rootDir, username, password, fetchArgs...)
+}
+
+// Clone does the first fetch of a freshly initialised repository, bounded by clone timeout as it downloads whole history
+func (impl *GitUtil) Clone(ctx context.Context, rootDir string, username string, password string, fetchArgs ...string) (response, errMsg string, err error) {
+	return impl.fetch(ctx, impl.timeout(impl.configuration.GitCloneTimeoutInSec, CLONE_TIMEOUT_SEC), rootDir, username, password, fetchArgs...)
+}
+
+func (impl *GitUtil) fetch(ctx context.Context, timeout time.Duration, rootDir string, username string, password string, fetchArgs ...string) (response, errMsg string, err error) {
+	impl.logger.Debugw("git fetch ", "location", rootDir, "args", fetchArgs)
+	args := append([]string{"-C", rootDir, "fetch", "origin", "--force", "--prune"}, fetchArgs...)
+	cmd := exec.Command("git", args...)
+	output, errMsg, err := impl.runCommandWithCred(ctx, "fetch", timeout, cmd, username, password)
 	impl.logger.Debugw("fetch output", "root", rootDir, "opt", output, "errMsg", errMsg, "error", err)
 	return output, errMsg, err
 }
 
-func (impl *GitUtil) Checkout(rootDir string, branch string) (response, errMsg string, err error) {
+func (impl *GitUtil) Checkout(ctx context.Context, rootDir string, branch string) (response, errMsg string, err error) {
 	impl.logger.Debugw("git checkout ", "location", rootDir)
 	cmd := exec.Command("git", "-C", rootDir, "checkout", branch, "--force")
-	output, errMsg, err := impl.runCommand(cmd)
+	output, errMsg, err := impl.runCommand(ctx, "checkout", impl.commandTimeout(), cmd)
 	impl.logger.Debugw("checkout output", "root", rootDir, "opt", output, "errMsg", errMsg, "error", err)
 	return output, errMsg, err
 }
 
-func (impl *GitUtil) runCommandWithCred(cmd *exec.Cmd, userName, password string) (response, errMsg string, err error) {
-	cmd.Env = append(os.Environ(),
+// Gc packs loose objects and refs once git finds enough of them, and drops unreachable objects past their expiry
+func (impl *GitUtil) Gc(ctx context.Context, rootDir string) (response, errMsg string, err error) {
+	// gc would otherwise detach and keep running after lock of repository is released
+	return impl.runMaintenanceCommand(ctx, "gc", rootDir, "-c", "gc.autoDetach=false", "gc", "--auto", "--quiet")
+}
+
+func (impl *GitUtil) Prune(ctx context.Context, rootDir string) (response, errMsg string, err error) {
+	return impl.runMaintenanceCommand(ctx, "prune", rootDir, "prune", "--expire="+PRUNE_EXPIRE)
+}
+
+// WriteMultiPackIndex indexes all packs together so that lookups do not scan every pack file, skipped on git older
+// than 2.21 which does not have multi-pack-index
+func (impl *GitUtil) WriteMultiPackIndex(ctx context.Context, rootDir string) (response, errMsg string, err error) {
+	if !impl.isVersionAtLeast(ctx, 2, 21) {
+		impl.logger.Debugw("skipping multi-pack-index, not supported by git", "location", rootDir)
+		return "", "", nil
+	}
+	packs, err := filepath.Glob(filepath.Join(rootDir, "objects", "pack", "*.pack"))
+	if err != nil || len(packs) == 0 {
+		// git refuses to write index of no packs, all objects are still loose
+		return "", "", err
+	}
+	return impl.runMaintenanceCommand(ctx, "multi-pack-index", rootDir, "multi-pack-index", "write")
+}
+
+// WriteCommitGraph writes commit graph of all refs, which speeds up history walks of git cli
+func (impl *GitUtil) WriteCommitGraph(ctx context.Context, rootDir string) (response, errMsg string, err error) {
+	return impl.runMaintenanceCommand(ctx, "commit-graph", rootDir, "commit-graph", "write", "--reachable")
+}
+
+// isVersionAtLeast tells if git cli is of given version or newer, version is read once and unknown version is
+// treated as older
+func (impl *GitUtil) isVersionAtLeast(ctx context.Context, major int, minor int) bool {
+	impl.versionLock.Lock()
+	defer impl.versionLock.Unlock()
+	if impl.version == nil {
+		output, errMsg, err := impl.runCommand(ctx, "version", impl.commandTimeout(), exec.Command("git", "version"))
+		if err != nil {
+			impl.logger.Errorw("error in getting git version", "errorMsg", errMsg, "err", err)
+			return false
+		}
+		match := gitVersionPattern.FindStringSubmatch(output)
+		if match == nil {
+			impl.logger.Errorw("unknown git version", "version", output)
+			return false
+		}
+		versionMajor, _ := strconv.Atoi(match[1])
+		versionMinor, _ := strconv.Atoi(match[2])
+		impl.version = []int{versionMajor, versionMinor}
+	}
+	return impl.version[0] > major || (impl.version[0] == major && impl.version[1] >= minor)
+}
+
+func (impl *GitUtil) runMaintenanceCommand(ctx context.Context, operation string, rootDir string, args ...string) (response, errMsg string, err error) {
+	impl.logger.Debugw("git maintenance ", "location", rootDir, "operation", operation)
+	cmd := exec.Command("git", append([]string{"-C", rootDir}, args...)...)
+	timeout := impl.timeout(impl.configuration.GitMaintenanceTimeoutInSec, MAINTENANCE_TIMEOUT_SEC)
+	output, errMsg, err := impl.runCommand(ctx, operation, timeout, cmd)
+	impl.logger.Debugw("maintenance output", "root", rootDir, "operation", operation, "opt", output, "errMsg", errMsg, "error", err)
+	return output, errMsg, err
+}
+
+func (impl *GitUtil) timeout(configuredSec int, defaultSec int) time.Duration {
+	if configuredSec <= 0 {
+		configuredSec = defaultSec
+	}
+	return time.Duration(configuredSec) * time.Second
+}
+
+func (impl *GitUtil) commandTimeout() time.Duration {
+	return impl.timeout(impl.configuration.GitCommandTimeoutInSec, COMMAND_TIMEOUT_SEC)
+}
+
+func (impl *GitUtil) runCommandWithCred(ctx context.Context, operation string, timeout time.Duration, cmd *exec.Cmd, userName, password string) (response, errMsg string, err error) {
+	cmd.Env = credentialEnv(userName, password)
+	return impl.runCommand(ctx, operation, timeout, cmd)
+}
+
+func credentialEnv(userName, password string) []string {
+	return append(os.Environ(),
 		fmt.Sprintf("GIT_ASKPASS=%s", GIT_ASK_PASS),
 		fmt.Sprintf("GIT_USERNAME=%s", userName),
 		fmt.Sprintf("GIT_PASSWORD=%s", password),
 	)
-	return impl.runCommand(cmd)
 }
 
-func (impl *GitUtil) runCommand(cmd *exec.Cmd) (response, errMsg string, err error) {
+func (impl *GitUtil) runCommand(ctx context.Context, operation string, timeout time.Duration, cmd *exec.Cmd) (response, errMsg string, err error) {
+	var out bytes.Buffer
+	err = impl.run(ctx, operation, timeout, cmd, &out, &out)
+	if err != nil {
+		return "", out.String(), err
+	}
+	output := strings.TrimSpace(out.String())
+	return output, "", nil
+}
+
+// run runs git in its own process group and kills the whole group once timeout passes or ctx is cancelled,
+// so that ssh and credential helpers spawned by git do not outlive it and keep holding the repository lock
+func (impl *GitUtil) run(ctx context.Context, operation string, timeout time.Duration, cmd *exec.Cmd, stdout io.Writer, stderr *bytes.Buffer) error {
+	timeoutContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 	cmd.Env = append(cmd.Env, "HOME=/dev/null")
-	outBytes, err := cmd.CombinedOutput()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Start()
 	if err != nil {
-		impl.logger.Error("error in git cli operation", "msg", string(outBytes), "err", err)
-		exErr, ok := err.(*exec.ExitError)
-		if !ok {
-			return "", string(outBytes), err
+		impl.logger.Errorw("error in starting git cli operation", "operation", operation, "err", err)
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-timeoutContext.Done():
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			err = &GitTimeoutError{Operation: operation, Timeout: timeout}
 		}
-		errOutput := string(exErr.Stderr)
-		return "", errOutput, err
+		impl.logger.Errorw("git cli operation aborted", "operation", operation, "msg", stderr.String(), "err", err)
+		return err
 	}
-	output := string(outBytes)
-	output = strings.TrimSpace(output)
-	return output, "", nil
+	if err != nil {
+		impl.logger.Errorw("error in git cli operation", "operation", operation, "msg", stderr.String(), "err", err)
+		return err
+	}
+	return nil
 }
 
 func (impl *GitUtil) Init(rootDir string, remoteUrl string, isBare bool) error {
@@ -82,11 +236,65 @@ func (impl *GitUtil) Init(rootDir string, remoteUrl string, isBare bool) error {
 	return err
 }
 
-func (impl *GitUtil) ConfigureSshCommand(rootDir string, sshPrivateKeyPath string) (response, errMsg string, err error) {
+func (impl *GitUtil) ConfigureSshCommand(ctx context.Context, rootDir string, sshPrivateKeyPath string) (response, errMsg string, err error) {
 	impl.logger.Debugw("configuring ssh command on ", "location", rootDir)
 	coreSshCommand := fmt.Sprintf("ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no", sshPrivateKeyPath)
 	cmd := exec.Command("git", "-C", rootDir, "config", "core.sshCommand", coreSshCommand)
-	output, errMsg, err := impl.runCommand(cmd)
+	output, errMsg, err := impl.runCommand(ctx, "config", impl.commandTimeout(), cmd)
 	impl.logger.Debugw("configure ssh command output ", "root", rootDir, "opt", output, "errMsg", errMsg, "error", err)
 	return output, errMsg, err
-}
\ No newline at end of file
+}
+func (impl *GitUtil) SetConfig(ctx context.Context, rootDir string, key string, value string) (response, errMsg string, err error) {
+	impl.logger.Debugw("setting git config", "location", rootDir, "key", key)
+	cmd := exec.Command("git", "-C", rootDir, "config", key, value)
+	output, errMsg, err := impl.runCommand(ctx, "config", impl.commandTimeout(), cmd)
+	impl.logger.Debugw("set config output", "root", rootDir, "opt", output, "errMsg", errMsg, "error", err)
+	return output, errMsg, err
+}
+
+// Diff runs git diff, output is written to stdout as is
+func (impl *GitUtil) Diff(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, diffArgs ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "diff", rootDir, username, password, stdout, append([]string{"diff", "--no-color", "--no-ext-diff"}, diffArgs...)...)
+}
+
+// ListTree runs git ls-tree with sizes of blobs and NUL terminated entries
+func (impl *GitUtil) ListTree(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, lsTreeArgs ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "ls-tree", rootDir, username, password, stdout, append([]string{"ls-tree", "-z", "-l"}, lsTreeArgs...)...)
+}
+
+// CatBlob writes content of blob to stdout
+func (impl *GitUtil) CatBlob(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, blobHash string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "cat-file", rootDir, username, password, stdout, "cat-file", "blob", blobHash)
+}
+
+// Log runs git log without colors, output is written to stdout as is
+func (impl *GitUtil) Log(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, logArgs ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "log", rootDir, username, password, stdout, append([]string{"log", "--no-color"}, logArgs...)...)
+}
+
+// RevList runs git rev-list, output is written to stdout as is
+func (impl *GitUtil) RevList(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, revListArgs ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "rev-list", rootDir, username, password, stdout, append([]string{"rev-list"}, revListArgs...)...)
+}
+
+// MergeBase runs git merge-base, which exits with status 1 and no output when commits have no common ancestor
+func (impl *GitUtil) MergeBase(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, commits ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "merge-base", rootDir, username, password, stdout, append([]string{"merge-base"}, commits...)...)
+}
+
+// LsRemote runs git ls-remote against origin of repository, output is written to stdout as is
+func (impl *GitUtil) LsRemote(ctx context.Context, rootDir string, username string, password string, stdout io.Writer, lsRemoteArgs ...string) (errMsg string, err error) {
+	return impl.runReadCommand(ctx, "ls-remote", rootDir, username, password, stdout, append(append([]string{"ls-remote"}, lsRemoteArgs...), "origin")...)
+}
+
+// runReadCommand runs command reading objects of repository with credentials, as partial clones fetch missing objects
+// from remote on demand, bounded by read timeout which is kept apart from fetch timeout of large fetches
+func (impl *GitUtil) runReadCommand(ctx context.Context, operation string, rootDir string, username string, password string, stdout io.Writer, args ...string) (errMsg string, err error) {
+	impl.logger.Debugw("git read ", "location", rootDir, "args", args)
+	// paths are passed as they are, without glob or magic of pathspecs
+	cmd := exec.Command("git", append([]string{"--literal-pathspecs", "-C", rootDir}, args...)...)
+	cmd.Env = credentialEnv(username, password)
+	var stderr bytes.Buffer
+	err = impl.run(ctx, operation, impl.timeout(impl.configuration.GitReadTimeoutInSec, READ_TIMEOUT_SEC), cmd, stdout, &stderr)
+	return stderr.String(), err
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"fmt"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"regexp"
+	"strings"
+)
+
+const SKIP_REASON_PATH_FILTER = "no changed file matches path filter"
+
+type PathMatcher struct {
+	includes []*regexp.Regexp
+	excludes []*regexp.Regexp
+}
+
+// NewPathMatcher compiles globs of path filter, nil matcher is returned when filter has no globs
+func NewPathMatcher(filter *sql.PathFilter) (*PathMatcher, error) {
+	if filter == nil || (len(filter.IncludePaths) == 0 && len(filter.ExcludePaths) == 0) {
+		return nil, nil
+	}
+	matcher := &PathMatcher{}
+	for _, glob := range filter.IncludePaths {
+		regex, err := compileGlob(glob)
+		if err != nil {
+			return nil, err
+		}
+		matcher.includes = append(matcher.includes, regex)
+	}
+	for _, glob := range filter.ExcludePaths {
+		regex, err := compileGlob(glob)
+		if err != nil {
+			return nil, err
+		}
+		matcher.excludes = append(matcher.excludes, regex)
+	}
+	return matcher, nil
+}
+
+// Matches is true when path matches any include glob (or no include glob is given) and no exclude glob
+func (matcher *PathMatcher) Matches(path string) bool {
+	included := len(matcher.includes) == 0
+	for _, include := range matcher.includes {
+		if include.MatchString(path) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, exclude := range matcher.excludes {
+		if exclude.MatchString(path) {
+			return false
+		}
+	}
+	return true
+}
+
+func (matcher *PathMatcher) MatchesAny(paths []string) bool {
+	for _, path := range paths {
+		if matcher.Matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob converts glob to regex, ** matches across directories and trailing / matches everything inside directory
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(strings.TrimSpace(glob), "/")
+	if len(glob) == 0 {
+		return nil, fmt.Errorf("empty path glob")
+	}
+	if strings.HasSuffix(glob, "/") {
+		glob = glob + "**"
+	}
+	var regex strings.Builder
+	regex.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			regex.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			regex.WriteString(".*")
+			i += 1
+		case c == '*':
+			regex.WriteString("[^/]*")
+		case c == '?':
+			regex.WriteString("[^/]")
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	regex.WriteString("$")
+	return regexp.Compile(regex.String())
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// MAX_COMPARE_FILES limits files listed in comparison, totals are computed over all the files
+const MAX_COMPARE_FILES = 300
+
+// CompareRefs compares head ref against base ref with their merge base, commits unique to each side and files changed
+// by head since merge base
+func (impl RepositoryManagerImpl) CompareRefs(ctx context.Context, checkoutPath string, userName, password string, request *CompareRefsRequest) (*CompareRefsResponse, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	baseHash, err := resolveCommitHash(repository, request.BaseRef)
+	if err != nil {
+		impl.logger.Errorw("error in resolving base ref", "path", checkoutPath, "ref", request.BaseRef, "err", err)
+		return nil, err
+	}
+	headHash, err := resolveCommitHash(repository, request.HeadRef)
+	if err != nil {
+		impl.logger.Errorw("error in resolving head ref", "path", checkoutPath, "ref", request.HeadRef, "err", err)
+		return nil, err
+	}
+	response := &CompareRefsResponse{
+		BaseCommit:    baseHash.String(),
+		HeadCommit:    headHash.String(),
+		AheadCommits:  []*GitCommit{},
+		BehindCommits: []*GitCommit{},
+		Files:         []*FileDiff{},
+	}
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.MergeBase(ctx, checkoutPath, userName, password, &out, response.BaseCommit, response.HeadCommit)
+	if err != nil && len(strings.TrimSpace(errMsg)) > 0 {
+		impl.logger.Errorw("error in finding merge base", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in finding merge base: %s", strings.TrimSpace(errMsg))
+	}
+	response.MergeBase = strings.TrimSpace(out.String())
+
+	response.BehindBy, response.AheadBy, err = impl.countLeftRight(ctx, checkoutPath, userName, password, response.BaseCommit, response.HeadCommit)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.AheadBy == 0 && response.BehindBy == 0:
+		response.Status = COMPARE_STATUS_IDENTICAL
+	case response.BehindBy == 0:
+		response.Status = COMPARE_STATUS_AHEAD
+	case response.AheadBy == 0:
+		response.Status = COMPARE_STATUS_BEHIND
+	default:
+		response.Status = COMPARE_STATUS_DIVERGED
+	}
+	maxCommits := GetCommitPageSize(request.MaxCommits)
+	response.AheadCommits, err = impl.getUniqueCommits(ctx, checkoutPath, userName, password, repository, response.HeadCommit, response.BaseCommit, maxCommits)
+	if err != nil {
+		return nil, err
+	}
+	response.BehindCommits, err = impl.getUniqueCommits(ctx, checkoutPath, userName, password, repository, response.BaseCommit, response.HeadCommit, maxCommits)
+	if err != nil {
+		return nil, err
+	}
+
+	// without common history, head is compared against base directly
+	diffBase := response.MergeBase
+	if diffBase == "" {
+		diffBase = response.BaseCommit
+	}
+	files, err := impl.listChangedFiles(ctx, checkoutPath, userName, password, diffBase, response.HeadCommit)
+	if err != nil {
+		return nil, err
+	}
+	response.TotalFiles = len(files)
+	for _, file := range files {
+		response.Additions += file.Additions
+		response.Deletions += file.Deletions
+	}
+	if len(files) > MAX_COMPARE_FILES {
+		files = files[:MAX_COMPARE_FILES]
+		response.FilesTruncated = true
+	}
+	response.Files = append(response.Files, files...)
+	return response, nil
+}
+
+// countLeftRight counts commits reachable only from left and only from right commit
+func (impl RepositoryManagerImpl) countLeftRight(ctx context.Context, checkoutPath string, userName, password string, left string, right string) (int, int, error) {
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.RevList(ctx, checkoutPath, userName, password, &out, "--left-right", "--count", left+"..."+right)
+	if err != nil {
+		impl.logger.Errorw("error in counting commits", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return 0, 0, fmt.Errorf("error in counting commits: %s", strings.TrimSpace(errMsg))
+	}
+	counts := strings.Fields(out.String())
+	if len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected commit count %q", out.String())
+	}
+	leftCount, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	rightCount, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return leftCount, rightCount, nil
+}
+
+// getUniqueCommits returns latest commits reachable from commit but not from exclude commit, newest commit first
+func (impl RepositoryManagerImpl) getUniqueCommits(ctx context.Context, checkoutPath string, userName, password string, repository *git.Repository, commit string, exclude string, maxCommits int) ([]*GitCommit, error) {
+	hashes, err := impl.listUniqueCommitHashes(ctx, checkoutPath, userName, password, commit, exclude, maxCommits)
+	if err != nil {
+		return nil, err
+	}
+	gitCommits := []*GitCommit{}
+	for _, hash := range hashes {
+		commitObject, err := repository.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			impl.logger.Errorw("error in getting commit", "path", checkoutPath, "commit", hash, "err", err)
+			return nil, err
+		}
+		gitCommit, err := impl.toPendingGitCommit(commitObject)
+		if err != nil {
+			impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "commit", hash, "err", err)
+			return nil, err
+		}
+		gitCommits = append(gitCommits, gitCommit)
+	}
+	impl.commitStatsService.FillStats(checkoutPath, gitCommits)
+	return gitCommits, nil
+}
+
+// listUniqueCommitHashes lists commits reachable from commit but not from exclude commit, newest commit first. All of
+// them are listed when maxCommits is 0
+func (impl RepositoryManagerImpl) listUniqueCommitHashes(ctx context.Context, checkoutPath string, userName, password string, commit string, exclude string, maxCommits int) ([]string, error) {
+	args := []string{commit, "^" + exclude}
+	if maxCommits > 0 {
+		args = append([]string{fmt.Sprintf("--max-count=%d", maxCommits)}, args...)
+	}
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.RevList(ctx, checkoutPath, userName, password, &out, args...)
+	if err != nil {
+		impl.logger.Errorw("error in listing commits", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in listing commits: %s", strings.TrimSpace(errMsg))
+	}
+	return strings.Fields(out.String()), nil
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RELEASE_NOTE_TYPE_OTHER  = "other"
+	RELEASE_NOTE_TYPE_REVERT = "revert"
+	// DEFAULT_ISSUE_KEY_PATTERN matches issue keys of jira like trackers
+	DEFAULT_ISSUE_KEY_PATTERN = `\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`
+)
+
+// releaseNoteSections are sections of release notes in order of rendering, keyed by conventional commit type
+var releaseNoteSections = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{RELEASE_NOTE_TYPE_REVERT, "Reverts"},
+	{"refactor", "Code Refactoring"},
+	{"docs", "Documentation"},
+	{"style", "Styles"},
+	{"test", "Tests"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"chore", "Chores"},
+	{RELEASE_NOTE_TYPE_OTHER, "Other Changes"},
+}
+
+var (
+	conventionalHeaderRegex = regexp.MustCompile(`^(\w+)(?:\(([^()\r\n]*)\))?(!)?: (.+)$`)
+	breakingFooterRegex     = regexp.MustCompile(`^BREAKING[ -]CHANGE: ?(.*)$`)
+	footerRegex             = regexp.MustCompile(`^[\w-]+(?:: | #)`)
+	revertHeaderRegex       = regexp.MustCompile(`^Revert "(.+)"$`)
+	plainMergeRegex         = regexp.MustCompile(`^Merge (?:branch|remote-tracking branch|tag|commit) `)
+	// merge commits of pull requests, title of pull request follows in body or after the colon
+	githubMergeRegex = regexp.MustCompile(`^Merge pull request #(\d+) from \S+`)
+	azureMergeRegex  = regexp.MustCompile(`^Merged PR (\d+): (.+)$`)
+	// references of pull requests anywhere in message
+	squashSuffixRegex   = regexp.MustCompile(`\s*\(#(\d+)\)$`)
+	gitlabMergeRegex    = regexp.MustCompile(`See merge request \S*!(\d+)`)
+	bitbucketMergeRegex = regexp.MustCompile(`\(pull request #(\d+)\)`)
+	closingIssueRegex   = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s+#(\d+)\b`)
+)
+
+type ReleaseNoteEntry struct {
+	Commit       string    `json:"commit"`
+	Type         string    `json:"type"`
+	Scope        string    `json:"scope,omitempty"`
+	Description  string    `json:"description"`
+	Breaking     bool      `json:"breaking"`
+	BreakingNote string    `json:"breakingNote,omitempty"`
+	IssueKeys    []string  `json:"issueKeys,omitempty"`
+	PullRequests []int     `json:"pullRequests,omitempty"`
+	Author       string    `json:"author"`
+	Date         time.Time `json:"date"`
+}
+
+type ReleaseNoteSection struct {
+	Type    string              `json:"type"`
+	Title   string              `json:"title"`
+	Entries []*ReleaseNoteEntry `json:"entries"`
+}
+
+type ReleaseNotes struct {
+	Sections        []*ReleaseNoteSection `json:"sections"`
+	BreakingChanges []*ReleaseNoteEntry   `json:"breakingChanges"`
+	IssueKeys       []string              `json:"issueKeys"`
+	PullRequests    []int                 `json:"pullRequests"`
+	Markdown        string                `json:"markdown"`
+}
+
+// BuildReleaseNotes groups commits by their conventional commit type, latest commit first. Merges of branches are
+// left out as their commits are listed already, merges of pull requests are listed with title of pull request
+func BuildReleaseNotes(commits []*Commit, issueKeyPattern string) (*ReleaseNotes, error) {
+	if len(issueKeyPattern) == 0 {
+		issueKeyPattern = DEFAULT_ISSUE_KEY_PATTERN
+	}
+	issueKeyRegex, err := regexp.Compile(issueKeyPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issue key pattern %s: %v", issueKeyPattern, err)
+	}
+	sorted := make([]*Commit, 0, len(commits))
+	for _, commit := range commits {
+		if commit != nil {
+			sorted = append(sorted, commit)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Committer.Date.After(sorted[j].Committer.Date)
+	})
+
+	notes := &ReleaseNotes{Sections: []*ReleaseNoteSection{}, BreakingChanges: []*ReleaseNoteEntry{}, IssueKeys: []string{}, PullRequests: []int{}}
+	entriesByType := make(map[string][]*ReleaseNoteEntry)
+	seenIssueKeys := make(map[string]bool)
+	seenPullRequests := make(map[int]bool)
+	for _, commit := range sorted {
+		entry := parseReleaseNoteEntry(commit, issueKeyRegex)
+		if entry == nil {
+			continue
+		}
+		entriesByType[entry.Type] = append(entriesByType[entry.Type], entry)
+		if entry.Breaking {
+			notes.BreakingChanges = append(notes.BreakingChanges, entry)
+		}
+		for _, key := range entry.IssueKeys {
+			if !seenIssueKeys[key] {
+				seenIssueKeys[key] = true
+				notes.IssueKeys = append(notes.IssueKeys, key)
+			}
+		}
+		for _, number := range entry.PullRequests {
+			if !seenPullRequests[number] {
+				seenPullRequests[number] = true
+				notes.PullRequests = append(notes.PullRequests, number)
+			}
+		}
+	}
+	for _, section := range releaseNoteSections {
+		if entries, ok := entriesByType[section.Type]; ok {
+			notes.Sections = append(notes.Sections, &ReleaseNoteSection{Type: section.Type, Title: section.Title, Entries: entries})
+		}
+	}
+	notes.Markdown = renderReleaseNotes(notes)
+	return notes, nil
+}
+
+// parseReleaseNoteEntry parses header and footers of commit message, nil is returned for merges of branches
+func parseReleaseNoteEntry(commit *Commit, issueKeyRegex *regexp.Regexp) *ReleaseNoteEntry {
+	message := strings.TrimSpace(strings.ReplaceAll(commit.Subject, "\r\n", "\n"))
+	lines := strings.Split(message, "\n")
+	header := strings.TrimSpace(lines[0])
+	body := strings.Join(lines[1:], "\n")
+	entry := &ReleaseNoteEntry{
+		Commit: commit.Hash.Long,
+		Author: commit.Author.Name,
+		Date:   commit.Author.Date,
+	}
+	var pullRequests []string
+	if match := githubMergeRegex.FindStringSubmatch(header); match != nil {
+		pullRequests = append(pullRequests, match[1])
+		// title of pull request is first line of body, branch is kept if there is none
+		if title := strings.TrimSpace(body); len(title) > 0 {
+			bodyLines := strings.SplitN(title, "\n", 2)
+			header = strings.TrimSpace(bodyLines[0])
+			body = ""
+			if len(bodyLines) > 1 {
+				body = bodyLines[1]
+			}
+		}
+	} else if match := azureMergeRegex.FindStringSubmatch(header); match != nil {
+		pullRequests = append(pullRequests, match[1])
+		header = strings.TrimSpace(match[2])
+	} else if plainMergeRegex.MatchString(header) {
+		return nil
+	}
+	if match := squashSuffixRegex.FindStringSubmatch(header); match != nil {
+		pullRequests = append(pullRequests, match[1])
+		header = strings.TrimSpace(header[:len(header)-len(match[0])])
+	}
+	for _, regex := range []*regexp.Regexp{gitlabMergeRegex, bitbucketMergeRegex} {
+		for _, match := range regex.FindAllStringSubmatch(message, -1) {
+			pullRequests = append(pullRequests, match[1])
+		}
+	}
+
+	if match := revertHeaderRegex.FindStringSubmatch(header); match != nil {
+		entry.Type = RELEASE_NOTE_TYPE_REVERT
+		entry.Description = match[1]
+	} else if match := conventionalHeaderRegex.FindStringSubmatch(header); match != nil && isReleaseNoteType(strings.ToLower(match[1])) {
+		entry.Type = strings.ToLower(match[1])
+		entry.Scope = strings.TrimSpace(match[2])
+		entry.Breaking = match[3] == "!"
+		entry.Description = strings.TrimSpace(match[4])
+	} else {
+		entry.Type = RELEASE_NOTE_TYPE_OTHER
+		entry.Description = header
+	}
+	if note := parseBreakingNote(body); len(note) > 0 {
+		entry.Breaking = true
+		entry.BreakingNote = note
+	} else if entry.Breaking {
+		entry.BreakingNote = entry.Description
+	}
+
+	seenPullRequests := make(map[int]bool)
+	for _, pullRequest := range pullRequests {
+		number, err := strconv.Atoi(pullRequest)
+		if err == nil && !seenPullRequests[number] {
+			seenPullRequests[number] = true
+			entry.PullRequests = append(entry.PullRequests, number)
+		}
+	}
+	seenIssueKeys := make(map[string]bool)
+	var issueKeys []string
+	for _, key := range issueKeyRegex.FindAllString(message, -1) {
+		issueKeys = append(issueKeys, key)
+	}
+	for _, match := range closingIssueRegex.FindAllStringSubmatch(message, -1) {
+		issueKeys = append(issueKeys, "#"+match[1])
+	}
+	for _, key := range issueKeys {
+		if !seenIssueKeys[key] {
+			seenIssueKeys[key] = true
+			entry.IssueKeys = append(entry.IssueKeys, key)
+		}
+	}
+	return entry
+}
+
+func isReleaseNoteType(commitType string) bool {
+	for _, section := range releaseNoteSections {
+		if section.Type == commitType && commitType != RELEASE_NOTE_TYPE_OTHER {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBreakingNote returns text of BREAKING CHANGE footer, which runs till the next footer or end of its paragraph
+func parseBreakingNote(body string) string {
+	var note []string
+	inNote := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if inNote {
+			if line == "" || footerRegex.MatchString(line) {
+				break
+			}
+			note = append(note, line)
+			continue
+		}
+		if match := breakingFooterRegex.FindStringSubmatch(line); match != nil {
+			inNote = true
+			if text := strings.TrimSpace(match[1]); len(text) > 0 {
+				note = append(note, text)
+			}
+		}
+	}
+	return strings.Join(note, " ")
+}
+
+func renderReleaseNotes(notes *ReleaseNotes) string {
+	var markdown strings.Builder
+	if len(notes.BreakingChanges) > 0 {
+		markdown.WriteString("### BREAKING CHANGES\n\n")
+		for _, entry := range notes.BreakingChanges {
+			writeReleaseNoteLine(&markdown, entry, entry.BreakingNote)
+		}
+		markdown.WriteString("\n")
+	}
+	for _, section := range notes.Sections {
+		markdown.WriteString("### " + section.Title + "\n\n")
+		for _, entry := range section.Entries {
+			writeReleaseNoteLine(&markdown, entry, entry.Description)
+		}
+		markdown.WriteString("\n")
+	}
+	return strings.TrimSuffix(markdown.String(), "\n")
+}
+
+func writeReleaseNoteLine(markdown *strings.Builder, entry *ReleaseNoteEntry, text string) {
+	markdown.WriteString("- ")
+	if len(entry.Scope) > 0 {
+		markdown.WriteString("**" + entry.Scope + ":** ")
+	}
+	markdown.WriteString(text)
+	var references []string
+	for _, number := range entry.PullRequests {
+		references = append(references, "#"+strconv.Itoa(number))
+	}
+	for _, key := range entry.IssueKeys {
+		// closed issue of github may be the pull request itself
+		if !contains(references, key) {
+			references = append(references, key)
+		}
+	}
+	if len(references) > 0 {
+		markdown.WriteString(" (" + strings.Join(references, ", ") + ")")
+	}
+	shortHash := entry.Commit
+	if len(shortHash) > 8 {
+		shortHash = shortHash[:8]
+	}
+	markdown.WriteString(" (" + shortHash + ")\n")
+}
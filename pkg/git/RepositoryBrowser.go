@@ -0,0 +1,243 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+const (
+	DEFAULT_FILE_CONTENT_BYTES = 1024 * 1024
+	MAX_FILE_CONTENT_BYTES     = 10 * 1024 * 1024
+	BINARY_DETECTION_BYTES     = 8000 // same as git, content with NUL in first bytes is binary
+	LFS_POINTER_MAX_BYTES      = 1024
+	SYMLINK_MODE               = "120000"
+)
+
+var lfsPointerRegex = regexp.MustCompile(`\Aversion https://git-lfs\.github\.com/spec/v1\n(?:.*\n)*?oid sha256:([0-9a-f]{64})\nsize (\d+)\n`)
+
+// PathNotFoundError is returned when path is not present in tree of the commit
+type PathNotFoundError struct {
+	Path   string
+	Commit string
+}
+
+func (e *PathNotFoundError) Error() string {
+	return fmt.Sprintf("path %s not found at commit %s", e.Path, e.Commit)
+}
+
+// GetTree lists entries of directory at path in tree of ref
+func (impl RepositoryManagerImpl) GetTree(ctx context.Context, checkoutPath string, userName, password string, request *TreeRequest) (*TreeResponse, error) {
+	commitHash, dirPath, err := impl.resolveRefAndPath(checkoutPath, request.Ref, request.Path)
+	if err != nil {
+		return nil, err
+	}
+	response := &TreeResponse{Commit: commitHash, Path: dirPath, Entries: []*TreeEntry{}}
+	treeHash := commitHash
+	if dirPath != "" {
+		entry, err := impl.getTreeEntry(ctx, checkoutPath, userName, password, commitHash, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type != TREE_ENTRY_TYPE_DIRECTORY {
+			return nil, fmt.Errorf("path %s is not a directory", dirPath)
+		}
+		treeHash = entry.Hash
+	}
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.ListTree(ctx, checkoutPath, userName, password, &out, treeHash)
+	if err != nil {
+		impl.logger.Errorw("error in listing tree", "path", checkoutPath, "dir", dirPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in listing tree: %s", strings.TrimSpace(errMsg))
+	}
+	entries, err := parseTreeEntries(out.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		entry.Path = path.Join(dirPath, entry.Name)
+	}
+	response.Entries = append(response.Entries, entries...)
+	return response, nil
+}
+
+// GetFileContent returns content of file at path in tree of ref, up to max bytes of request
+func (impl RepositoryManagerImpl) GetFileContent(ctx context.Context, checkoutPath string, userName, password string, request *FileContentRequest) (*FileContentResponse, error) {
+	commitHash, filePath, err := impl.resolveRefAndPath(checkoutPath, request.Ref, request.Path)
+	if err != nil {
+		return nil, err
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("path of file is required")
+	}
+	entry, err := impl.getTreeEntry(ctx, checkoutPath, userName, password, commitHash, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != TREE_ENTRY_TYPE_FILE && entry.Type != TREE_ENTRY_TYPE_SYMLINK {
+		return nil, fmt.Errorf("path %s is not a file", filePath)
+	}
+	maxBytes := request.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_FILE_CONTENT_BYTES
+	} else if maxBytes > MAX_FILE_CONTENT_BYTES {
+		maxBytes = MAX_FILE_CONTENT_BYTES
+	}
+	content := &cappedBuffer{limit: maxBytes}
+	errMsg, err := impl.gitUtil.CatBlob(ctx, checkoutPath, userName, password, content, entry.Hash)
+	if err != nil {
+		impl.logger.Errorw("error in reading file", "path", checkoutPath, "file", filePath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in reading file %s: %s", filePath, strings.TrimSpace(errMsg))
+	}
+	data := content.buf.Bytes()
+	response := &FileContentResponse{
+		Commit:    commitHash,
+		Path:      filePath,
+		Hash:      entry.Hash,
+		Size:      entry.Size,
+		Symlink:   entry.Type == TREE_ENTRY_TYPE_SYMLINK,
+		Truncated: content.truncated,
+	}
+	if entry.Size <= LFS_POINTER_MAX_BYTES {
+		response.LfsPointer = parseLfsPointer(data)
+	}
+	sniffLen := len(data)
+	if sniffLen > BINARY_DETECTION_BYTES {
+		sniffLen = BINARY_DETECTION_BYTES
+	}
+	// a truncated utf-8 sequence at the end is not a sign of binary content
+	response.Binary = bytes.IndexByte(data[:sniffLen], 0) >= 0 || (!content.truncated && !utf8.Valid(data))
+	response.ContentType = detectContentType(filePath, data, response.Binary)
+	if response.Binary {
+		response.Encoding = "base64"
+		response.Content = base64.StdEncoding.EncodeToString(data)
+	} else {
+		response.Encoding = "utf-8"
+		response.Content = string(data)
+	}
+	return response, nil
+}
+
+// resolveRefAndPath resolves ref to commit hash and cleans path to be relative to root of repository
+func (impl RepositoryManagerImpl) resolveRefAndPath(checkoutPath string, ref string, treePath string) (string, string, error) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return "", "", err
+	}
+	hash, err := resolveCommitHash(repository, ref)
+	if err != nil {
+		impl.logger.Errorw("error in resolving ref", "path", checkoutPath, "ref", ref, "err", err)
+		return "", "", err
+	}
+	cleanPath := strings.TrimPrefix(path.Clean("/"+treePath), "/")
+	return hash.String(), cleanPath, nil
+}
+
+// getTreeEntry returns entry of path in tree of commit
+func (impl RepositoryManagerImpl) getTreeEntry(ctx context.Context, checkoutPath string, userName, password string, commitHash string, treePath string) (*TreeEntry, error) {
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.ListTree(ctx, checkoutPath, userName, password, &out, commitHash, "--", treePath)
+	if err != nil {
+		impl.logger.Errorw("error in finding path in tree", "path", checkoutPath, "treePath", treePath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in finding path %s: %s", treePath, strings.TrimSpace(errMsg))
+	}
+	entries, err := parseTreeEntries(out.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		// ls-tree lists the path itself, not its content, as path does not end with slash
+		if entry.Name == treePath {
+			entry.Path = treePath
+			entry.Name = path.Base(treePath)
+			return entry, nil
+		}
+	}
+	return nil, &PathNotFoundError{Path: treePath, Commit: commitHash}
+}
+
+// parseTreeEntries parses output of git ls-tree -z -l, entries are <mode> <type> <hash> <size>\t<name>
+func parseTreeEntries(output []byte) ([]*TreeEntry, error) {
+	var entries []*TreeEntry
+	for _, line := range strings.Split(string(output), "\x00") {
+		if line == "" {
+			continue
+		}
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("unexpected tree entry %q", line)
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected tree entry %q", line)
+		}
+		entry := &TreeEntry{
+			Name: line[tab+1:],
+			Mode: fields[0],
+			Hash: fields[2],
+		}
+		switch fields[1] {
+		case "tree":
+			entry.Type = TREE_ENTRY_TYPE_DIRECTORY
+		case "commit":
+			entry.Type = TREE_ENTRY_TYPE_SUBMODULE
+		default:
+			entry.Type = TREE_ENTRY_TYPE_FILE
+			if entry.Mode == SYMLINK_MODE {
+				entry.Type = TREE_ENTRY_TYPE_SYMLINK
+			}
+			entry.Size, _ = strconv.ParseInt(fields[3], 10, 64)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseLfsPointer(content []byte) *LfsPointer {
+	match := lfsPointerRegex.FindSubmatch(content)
+	if match == nil {
+		return nil
+	}
+	size, err := strconv.ParseInt(string(match[2]), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &LfsPointer{Oid: string(match[1]), Size: size}
+}
+
+// detectContentType prefers type known for extension of file, and sniffs content otherwise
+func detectContentType(filePath string, content []byte, binary bool) string {
+	if contentType := mime.TypeByExtension(path.Ext(filePath)); contentType != "" {
+		return contentType
+	}
+	if !binary {
+		return "text/plain; charset=utf-8"
+	}
+	return http.DetectContentType(content)
+}
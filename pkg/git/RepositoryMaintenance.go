@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/caarlos0/env"
+	"github.com/devtron-labs/git-sensor/internal"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+type MaintenanceConfig struct {
+	MaintenanceIntervalInHours int `env:"MAINTENANCE_INTERVAL_IN_HOURS" envDefault:"24"`
+	MaintenanceCheckInMin      int `env:"MAINTENANCE_CHECK_IN_MIN" envDefault:"10"`
+	MaintenanceBatchSize       int `env:"MAINTENANCE_BATCH_SIZE" envDefault:"5"`
+}
+
+type MaintenanceResult struct {
+	GitMaterialId int    `json:"gitMaterialId"`
+	Location      string `json:"location"`
+	DurationMs    int64  `json:"durationMs"`
+	ErrorMsg      string `json:"errorMsg,omitempty"`
+}
+
+type RepositoryMaintenanceService interface {
+	RunMaintenance(ctx context.Context, gitMaterialId int) (*MaintenanceResult, error)
+}
+
+type RepositoryMaintenanceServiceImpl struct {
+	logger       *zap.SugaredLogger
+	materialRepo sql.MaterialRepository
+	gitUtil      *GitUtil
+	locker       *internal.RepositoryLocker
+	cron         *cron.Cron
+	config       *MaintenanceConfig
+}
+
+func NewRepositoryMaintenanceServiceImpl(logger *zap.SugaredLogger, materialRepo sql.MaterialRepository,
+	gitUtil *GitUtil, locker *internal.RepositoryLocker) (*RepositoryMaintenanceServiceImpl, error) {
+	cfg := &MaintenanceConfig{}
+	err := env.Parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cronLogger := &CronLoggerImpl{logger: logger}
+	cron := cron.New(
+		cron.WithChain(
+			cron.SkipIfStillRunning(cronLogger),
+			cron.Recover(cronLogger)))
+	cron.Start()
+	impl := &RepositoryMaintenanceServiceImpl{
+		logger:       logger,
+		materialRepo: materialRepo,
+		gitUtil:      gitUtil,
+		locker:       locker,
+		cron:         cron,
+		config:       cfg,
+	}
+	_, err = cron.AddFunc(fmt.Sprintf("@every %dm", cfg.MaintenanceCheckInMin), impl.runScheduledMaintenance)
+	if err != nil {
+		logger.Errorw("error in starting maintenance cron", "err", err)
+		return nil, err
+	}
+	return impl, nil
+}
+
+func (impl RepositoryMaintenanceServiceImpl) StopCron() {
+	impl.cron.Stop()
+}
+
+// runScheduledMaintenance maintains a small batch of repositories, least recently maintained first, one at a time so
+// that maintenance is spread over the interval and holds lock of a single repository at once
+func (impl RepositoryMaintenanceServiceImpl) runScheduledMaintenance() {
+	materials, err := impl.materialRepo.FindActive()
+	if err != nil {
+		impl.logger.Errorw("error in fetching materials for maintenance", "err", err)
+		return
+	}
+	dueBefore := time.Now().Add(-time.Duration(impl.config.MaintenanceIntervalInHours) * time.Hour)
+	lastMaintenanceTime := make(map[string]time.Time)
+	evicted := make(map[string]bool)
+	var locations []string
+	for _, material := range materials {
+		// evicted repositories are not on disk until they are used again
+		evicted[material.CheckoutLocation] = evicted[material.CheckoutLocation] || material.Evicted
+		maintenanceTime, ok := lastMaintenanceTime[material.CheckoutLocation]
+		if !ok {
+			locations = append(locations, material.CheckoutLocation)
+		}
+		if !ok || material.LastMaintenanceTime.Before(maintenanceTime) {
+			lastMaintenanceTime[material.CheckoutLocation] = material.LastMaintenanceTime
+		}
+	}
+	var dueLocations []string
+	for _, location := range locations {
+		if !evicted[location] && lastMaintenanceTime[location].Before(dueBefore) {
+			dueLocations = append(dueLocations, location)
+		}
+	}
+	sort.SliceStable(dueLocations, func(i, j int) bool {
+		return lastMaintenanceTime[dueLocations[i]].Before(lastMaintenanceTime[dueLocations[j]])
+	})
+	if len(dueLocations) > impl.config.MaintenanceBatchSize {
+		dueLocations = dueLocations[:impl.config.MaintenanceBatchSize]
+	}
+	for _, location := range dueLocations {
+		_, err = impl.maintainLocation(context.Background(), location)
+		if err != nil {
+			impl.logger.Errorw("error in maintenance of repository", "location", location, "err", err)
+		}
+	}
+}
+
+func (impl RepositoryMaintenanceServiceImpl) RunMaintenance(ctx context.Context, gitMaterialId int) (*MaintenanceResult, error) {
+	material, err := impl.materialRepo.FindById(gitMaterialId)
+	if err != nil {
+		impl.logger.Errorw("error in fetching material", "id", gitMaterialId, "err", err)
+		return nil, err
+	}
+	if !material.CheckoutStatus {
+		return nil, fmt.Errorf("checkout not succeed please checkout first %s", material.Url)
+	}
+	if material.Evicted {
+		return nil, fmt.Errorf("repository evicted from disk, it is cloned again on next use %s", material.Url)
+	}
+	result, err := impl.maintainLocation(ctx, material.CheckoutLocation)
+	if result != nil {
+		result.GitMaterialId = gitMaterialId
+	}
+	return result, err
+}
+
+// maintainLocation runs maintenance of repository at location under its lock and records it on all materials using it
+func (impl RepositoryMaintenanceServiceImpl) maintainLocation(ctx context.Context, location string) (*MaintenanceResult, error) {
+	repoLock := impl.locker.LeaseLocker(location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(location)
+	}()
+	impl.logger.Infow("starting maintenance of repository", "location", location)
+	start := time.Now()
+	err := impl.maintain(ctx, location)
+	result := &MaintenanceResult{
+		Location:   location,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.ErrorMsg = err.Error()
+	}
+	impl.logger.Infow("maintenance of repository done", "location", location, "durationMs", result.DurationMs, "err", err)
+	if ctx.Err() != nil {
+		return result, err
+	}
+	materials, dbErr := impl.materialRepo.FindByCheckoutLocation(location)
+	if dbErr != nil {
+		impl.logger.Errorw("error in fetching materials sharing location", "location", location, "err", dbErr)
+		return result, dbErr
+	}
+	for _, material := range materials {
+		material.LastMaintenanceTime = start
+		material.LastMaintenanceDurationMs = result.DurationMs
+		material.MaintenanceErrorMessage = result.ErrorMsg
+		dbErr = impl.materialRepo.UpdateMaintenanceStatus(material)
+		if dbErr != nil {
+			impl.logger.Errorw("error in updating maintenance status", "id", material.Id, "err", dbErr)
+		}
+	}
+	return result, err
+}
+
+// maintain runs every step even if an earlier one fails, as steps are independent of each other
+func (impl RepositoryMaintenanceServiceImpl) maintain(ctx context.Context, location string) error {
+	steps := []func(ctx context.Context, rootDir string) (string, string, error){
+		impl.gitUtil.Gc,
+		impl.gitUtil.Prune,
+		impl.gitUtil.WriteMultiPackIndex,
+		impl.gitUtil.WriteCommitGraph,
+	}
+	var firstErr error
+	for _, step := range steps {
+		_, errorMsg, err := step(ctx, location)
+		if err != nil {
+			impl.logger.Errorw("error in repository maintenance step", "location", location, "errorMsg", errorMsg, "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				return firstErr
+			}
+		}
+	}
+	return firstErr
+}
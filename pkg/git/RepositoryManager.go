@@ -17,12 +17,13 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/devtron-labs/git-sensor/internal"
 	"io"
-	"log"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,34 +31,132 @@ import (
 	"github.com/devtron-labs/git-sensor/internal/sql"
 	"go.uber.org/zap"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
 type RepositoryManager interface {
-	Fetch(userName, password string, url string, location string) (updated bool, repo *git.Repository, err error)
-	Add(gitProviderId int, location, url string, userName, password string, authMode sql.AuthMode, sshPrivateKeyContent string) error
+	Fetch(ctx context.Context, userName, password string, url string, location string, cloneOptions *CloneOptions) (updated bool, repo *git.Repository, err error)
+	FetchBranches(ctx context.Context, userName, password string, location string, cloneOptions *CloneOptions, refs ...string) error
+	Add(ctx context.Context, gitProviderId int, location, url string, userName, password string, authMode sql.AuthMode, sshPrivateKeyContent string, cloneOptions *CloneOptions) error
 	Clean(cloneDir string) error
 	ChangesSince(checkoutPath string, branch string, from string, to string, count int) ([]*GitCommit, error)
-	ChangesSinceByRepository(repository *git.Repository, branch string, from string, to string, count int) ([]*GitCommit, error)
+	ChangesSinceByRepository(checkoutPath string, repository *git.Repository, branch string, from string, to string, count int) ([]*GitCommit, error)
+	NewCommitsSinceByRepository(ctx context.Context, checkoutPath string, repository *git.Repository, branch string, lastSeenHash string, count int) (commits []*GitCommit, truncated bool, err error)
+	GetRemoteBranchHeads(repository *git.Repository) (map[string]string, error)
+	GetTagHeads(repository *git.Repository) (map[string]string, error)
+	ChangedFilesBetween(repository *git.Repository, from string, to string) ([]string, error)
+	VerifyCommitSignatures(checkoutPath string, commits []*GitCommit, gitProvider *sql.GitProvider)
+	FindOrphanedCommits(repository *git.Repository, oldHead string, newHead string) (rewritten bool, orphanedCommits []*GitCommit, err error)
 	GetCommitMetadata(checkoutPath, commitHash string) (*GitCommit, error)
-	ChangesSinceByRepositoryForAnalytics(checkoutPath string, branch string, Old string, New string) (*GitChanges, error)
+	GetCommitHeader(repository *git.Repository, commitHash string) (*GitCommit, error)
+	ChangesSinceByRepositoryForAnalytics(ctx context.Context, checkoutPath string, branch string, Old string, New string) (*GitChanges, error)
 	GetCommitForTag(checkoutPath, tag string) (*GitCommit, error)
-	CreateSshFileIfNotExistsAndConfigureSshCommand(location string, gitProviderId int, sshPrivateKeyContent string) error
+	IsRepository(location string) bool
+	GetFileDiff(ctx context.Context, checkoutPath string, userName, password string, request *FileDiffRequest) (*FileDiffResponse, error)
+	GetTree(ctx context.Context, checkoutPath string, userName, password string, request *TreeRequest) (*TreeResponse, error)
+	GetFileContent(ctx context.Context, checkoutPath string, userName, password string, request *FileContentRequest) (*FileContentResponse, error)
+	ListBranches(ctx context.Context, checkoutPath string, userName string, password string, request *BranchListRequest) (*BranchListResponse, error)
+	ListTags(ctx context.Context, checkoutPath string, userName string, password string, request *TagListRequest) (*TagListResponse, error)
+	GetCommitHistory(checkoutPath string, branch string, request *FetchScmChangesRequest) ([]*GitCommit, string, error)
+	GetUnstoredCommits(checkoutPath string, branch string, isStored func(commitHashes []string) (map[string]bool, error), count int) ([]*GitCommit, error)
+	SearchCommits(ctx context.Context, checkoutPath string, userName, password string, request *CommitSearchRequest) (*CommitSearchResponse, error)
+	CompareRefs(ctx context.Context, checkoutPath string, userName, password string, request *CompareRefsRequest) (*CompareRefsResponse, error)
+	CreateSshFileIfNotExistsAndConfigureSshCommand(ctx context.Context, location string, gitProviderId int, sshPrivateKeyContent string) error
+}
+
+// CloneOptions controls which objects of remote are fetched into bare repository of git material
+type CloneOptions struct {
+	FetchSubmodules bool
+	Strategy        sql.CloneStrategy
+	Depth           int
+	RefSpecs        []string
+}
+
+func GetCloneOptions(material *sql.GitMaterial) *CloneOptions {
+	return &CloneOptions{
+		FetchSubmodules: material.FetchSubmodules,
+		Strategy:        material.CloneStrategy,
+		Depth:           material.CloneDepth,
+		RefSpecs:        material.FetchRefSpecs,
+	}
+}
+
+// MergeCloneOptions returns options to fetch object store shared by materials, refs needed by any of the materials
+// are fetched and empty refspecs of a material means everything is needed
+func MergeCloneOptions(materials []*sql.GitMaterial) *CloneOptions {
+	if len(materials) == 0 {
+		return &CloneOptions{}
+	}
+	cloneOptions := GetCloneOptions(materials[0])
+	var refSpecs []string
+	seen := make(map[string]bool)
+	for _, material := range materials {
+		if len(material.FetchRefSpecs) == 0 {
+			refSpecs = nil
+			break
+		}
+		for _, refSpec := range material.FetchRefSpecs {
+			if !seen[refSpec] {
+				seen[refSpec] = true
+				refSpecs = append(refSpecs, refSpec)
+			}
+		}
+	}
+	sort.Strings(refSpecs)
+	cloneOptions.RefSpecs = refSpecs
+	return cloneOptions
+}
+
+// FetchArgs returns extra arguments of git fetch for clone strategy and refspecs, partial clone filter is remembered
+// by git in remote config after first fetch but passing it again keeps the repository partial on every fetch
+func (cloneOptions *CloneOptions) FetchArgs() []string {
+	var args []string
+	switch cloneOptions.Strategy {
+	case sql.CLONE_STRATEGY_BLOBLESS:
+		args = append(args, "--filter=blob:none")
+	case sql.CLONE_STRATEGY_TREELESS:
+		args = append(args, "--filter=tree:0")
+	case sql.CLONE_STRATEGY_SHALLOW:
+		depth := cloneOptions.Depth
+		if depth <= 0 {
+			depth = DEFAULT_CLONE_DEPTH
+		}
+		args = append(args, fmt.Sprintf("--depth=%d", depth))
+	}
+	if len(cloneOptions.RefSpecs) == 0 {
+		return append(args, "--tags")
+	}
+	// tags are fetched only when asked for in refspecs
+	args = append(args, "--no-tags")
+	return append(args, cloneOptions.RefSpecs...)
+}
+
+// BranchNotFoundError is returned when branch is not present in remote refs, i.e. deleted or never pushed
+type BranchNotFoundError struct {
+	Branch string
+}
+
+func (e *BranchNotFoundError) Error() string {
+	return fmt.Sprintf("branch %s not found in the repository ", e.Branch)
 }
 
 type RepositoryManagerImpl struct {
-	logger        *zap.SugaredLogger
-	gitUtil       *GitUtil
-	configuration *internal.Configuration
+	logger             *zap.SugaredLogger
+	gitUtil            *GitUtil
+	configuration      *internal.Configuration
+	commitStatsService CommitStatsService
 }
 
-func NewRepositoryManagerImpl(logger *zap.SugaredLogger, gitUtil *GitUtil, configuration *internal.Configuration) *RepositoryManagerImpl {
-	return &RepositoryManagerImpl{logger: logger, gitUtil: gitUtil, configuration: configuration}
+func NewRepositoryManagerImpl(logger *zap.SugaredLogger, gitUtil *GitUtil, configuration *internal.Configuration, commitStatsService CommitStatsService) *RepositoryManagerImpl {
+	return &RepositoryManagerImpl{logger: logger, gitUtil: gitUtil, configuration: configuration, commitStatsService: commitStatsService}
 }
 
-func (impl RepositoryManagerImpl) Add(gitProviderId int, location string, url string, userName, password string, authMode sql.AuthMode, sshPrivateKeyContent string) error {
+func (impl RepositoryManagerImpl) Add(ctx context.Context, gitProviderId int, location string, url string, userName, password string, authMode sql.AuthMode, sshPrivateKeyContent string, cloneOptions *CloneOptions) error {
+	middleware.GitCloneCounter.WithLabelValues().Inc()
 	err := os.RemoveAll(location)
 	if err != nil {
 		impl.logger.Errorw("error in cleaning checkout path", "err", err)
@@ -71,28 +170,48 @@ func (impl RepositoryManagerImpl) Add(gitProviderId int, location string, url st
 
 	// check ssh
 	if authMode == sql.AUTH_MODE_SSH {
-		err = impl.CreateSshFileIfNotExistsAndConfigureSshCommand(location, gitProviderId, sshPrivateKeyContent)
+		err = impl.CreateSshFileIfNotExistsAndConfigureSshCommand(ctx, location, gitProviderId, sshPrivateKeyContent)
 		if err != nil {
 			return err
 		}
 	}
 
-	opt, errorMsg, err := impl.gitUtil.Fetch(location, userName, password)
+	opt, errorMsg, err := impl.fetchRefSpecs(location, cloneOptions, func(fetchArgs ...string) (string, string, error) {
+		return impl.gitUtil.Clone(ctx, location, userName, password, fetchArgs...)
+	})
 	if err != nil {
 		impl.logger.Errorw("error in cloning repo", "errorMsg", errorMsg, "err", err)
 		return err
 	}
 	impl.logger.Debugw("opt msg", "opt", opt)
+	if cloneOptions.FetchSubmodules {
+		repository, err := git.PlainOpen(location)
+		if err != nil {
+			return err
+		}
+		// submodules are fetched again on every poll, so failure here does not fail checkout of the repository
+		_, err = impl.fetchSubmodules(ctx, repository, location, url, userName, password, cloneOptions)
+		if err != nil {
+			impl.logger.Errorw("error in fetching submodules", "location", url, "err", err)
+		}
+	}
 	return nil
 }
 
+// IsRepository tells if a repository is already cloned at location
+func (impl RepositoryManagerImpl) IsRepository(location string) bool {
+	_, err := git.PlainOpen(location)
+	return err == nil
+}
+
 func (impl RepositoryManagerImpl) Clean(dir string) error {
 	err := os.RemoveAll(dir)
 	return err
 }
 
 func (impl RepositoryManagerImpl) clone(auth transport.AuthMethod, cloneDir string, url string) (*git.Repository, error) {
-	timeoutContext, _ := context.WithTimeout(context.Background(), CLONE_TIMEOUT_SEC*time.Second)
+	timeoutContext, cancel := context.WithTimeout(context.Background(), CLONE_TIMEOUT_SEC*time.Second)
+	defer cancel()
 	impl.logger.Infow("cloning repository ", "url", url, "cloneDir", cloneDir)
 	repo, err := git.PlainCloneContext(timeoutContext, cloneDir, true, &git.CloneOptions{
 		URL:  url,
@@ -106,14 +225,25 @@ func (impl RepositoryManagerImpl) clone(auth transport.AuthMethod, cloneDir stri
 	return repo, err
 }
 
-func (impl RepositoryManagerImpl) Fetch(userName, password string, url string, location string) (updated bool, repo *git.Repository, err error) {
+func (impl RepositoryManagerImpl) Fetch(ctx context.Context, userName, password string, url string, location string, cloneOptions *CloneOptions) (updated bool, repo *git.Repository, err error) {
 	start := time.Now()
 	middleware.GitMaterialPollCounter.WithLabelValues().Inc()
 	r, err := git.PlainOpen(location)
 	if err != nil {
 		return false, nil, err
 	}
-	res, errorMsg, err := impl.gitUtil.Fetch(location, userName, password)
+	res, errorMsg, err := impl.fetchRefSpecs(location, cloneOptions, func(fetchArgs ...string) (string, string, error) {
+		return impl.gitUtil.Fetch(ctx, location, userName, password, fetchArgs...)
+	})
+	if err == nil && cloneOptions.FetchSubmodules {
+		submodulesUpdated, err := impl.fetchSubmodules(ctx, r, location, url, userName, password, cloneOptions)
+		if err != nil {
+			impl.logger.Errorw("error in fetching submodules", "location", url, "err", err)
+		}
+		if submodulesUpdated && len(res) == 0 {
+			res = "submodules updated"
+		}
+	}
 	if err == nil && len(res) > 0 {
 		impl.logger.Infow("repository updated", "location", url)
 		//updated
@@ -131,6 +261,62 @@ func (impl RepositoryManagerImpl) Fetch(userName, password string, url string, l
 
 }
 
+// FetchBranches fetches refs which are branches not covered by refspecs of clone options, so that branches requested
+// outside of pipeline materials resolve to their latest head instead of failing or resolving to a stale one
+func (impl RepositoryManagerImpl) FetchBranches(ctx context.Context, userName, password string, location string, cloneOptions *CloneOptions, refs ...string) error {
+	refSpecs := untrackedBranchRefSpecs(cloneOptions.RefSpecs, refs...)
+	if len(refSpecs) == 0 {
+		return nil
+	}
+	options := *cloneOptions
+	options.RefSpecs = refSpecs
+	_, errMsg, err := impl.fetchRefSpecs(location, &options, func(fetchArgs ...string) (string, string, error) {
+		return impl.gitUtil.Fetch(ctx, location, userName, password, fetchArgs...)
+	})
+	if err != nil {
+		impl.logger.Errorw("error in fetching branches", "location", location, "refSpecs", refSpecs, "errMsg", errMsg, "err", err)
+	}
+	return err
+}
+
+// fetchRefSpecs runs fetch with refspecs of clone options. When a fetched branch is not on remote anymore git fails
+// the whole fetch, so refspec of that branch is dropped, its remote tracking ref is removed to report it as deleted and
+// fetch is run again. Deleted branches are reported in response so that repository is seen as updated
+func (impl RepositoryManagerImpl) fetchRefSpecs(location string, cloneOptions *CloneOptions, fetch func(fetchArgs ...string) (string, string, error)) (response, errMsg string, err error) {
+	options := *cloneOptions
+	var deletedBranches []string
+	for {
+		response, errMsg, err = fetch(options.FetchArgs()...)
+		if err == nil {
+			if len(deletedBranches) > 0 {
+				response = strings.TrimSpace(response + "\ndeleted branches: " + strings.Join(deletedBranches, ", "))
+			}
+			return response, errMsg, err
+		}
+		branch := missingRemoteBranch(errMsg)
+		refSpecs := removeRefSpec(options.RefSpecs, branchRefSpec(branch))
+		if len(branch) == 0 || len(refSpecs) == len(options.RefSpecs) {
+			return response, errMsg, err
+		}
+		impl.logger.Warnw("branch not found on remote, fetching without it", "location", location, "branch", branch)
+		r, err := git.PlainOpen(location)
+		if err != nil {
+			return "", errMsg, err
+		}
+		err = r.Storer.RemoveReference(plumbing.ReferenceName(REMOTE_BRANCH_REF_PREFIX + branch))
+		if err != nil {
+			impl.logger.Errorw("error in removing ref of deleted branch", "location", location, "branch", branch, "err", err)
+			return "", errMsg, err
+		}
+		deletedBranches = append(deletedBranches, branch)
+		if len(refSpecs) == 0 {
+			// empty refspecs fetch everything, nothing is left to fetch here
+			return "deleted branches: " + strings.Join(deletedBranches, ", "), "", nil
+		}
+		options.RefSpecs = refSpecs
+	}
+}
+
 func (impl RepositoryManagerImpl) GetCommitForTag(checkoutPath, tag string) (*GitCommit, error) {
 	tag = strings.TrimSpace(tag)
 	r, err := git.PlainOpen(checkoutPath)
@@ -142,26 +328,261 @@ func (impl RepositoryManagerImpl) GetCommitForTag(checkoutPath, tag string) (*Gi
 		impl.logger.Errorw("error in fetching tag", "path", checkoutPath, "tag", tag, "err", err)
 		return nil, err
 	}
-	commit, err := r.CommitObject(plumbing.NewHash(tagRef.Hash().String()))
+	commitHash, err := resolveTagCommitHash(r, tagRef)
+	if err != nil {
+		impl.logger.Errorw("error in resolving tag", "path", checkoutPath, "tag", tag, "err", err)
+		return nil, err
+	}
+	commit, err := r.CommitObject(commitHash)
 	if err != nil {
 		impl.logger.Errorw("error in fetching tag", "path", checkoutPath, "hash", tagRef, "err", err)
 		return nil, err
 	}
-	gitCommit := &GitCommit{
-		Author:  commit.Author.String(),
-		Commit:  commit.Hash.String(),
-		Date:    commit.Author.When,
-		Message: commit.Message,
+	gitCommit, err := impl.toGitCommit(checkoutPath, commit)
+	if err != nil {
+		impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "tag", tag, "err", err)
+		return nil, err
 	}
-	fs, err := impl.getStats(commit)
+	gitCommit.Tag = tag
+	return gitCommit, nil
+}
+
+// fetchSubmodules mirrors every submodule declared in .gitmodules of any remote branch head as a bare repository
+// under modules directory of parent repository, using credentials and ssh command of parent repository
+func (impl RepositoryManagerImpl) fetchSubmodules(ctx context.Context, repository *git.Repository, location string, url string, userName, password string, cloneOptions *CloneOptions) (updated bool, err error) {
+	submodules, err := impl.getSubmodules(ctx, repository, location, userName, password)
+	if err != nil {
+		impl.logger.Errorw("error in reading submodules", "location", location, "err", err)
+		return false, err
+	}
+	if len(submodules) == 0 {
+		return false, nil
+	}
+	repoConfig, err := repository.Config()
+	if err != nil {
+		return false, err
+	}
+	sshCommand := repoConfig.Raw.Section("core").Option("sshCommand")
+	for name, submoduleUrl := range submodules {
+		submoduleUrl = resolveSubmoduleUrl(url, submoduleUrl)
+		submoduleLocation := GetLocationForSubmodule(location, name)
+		submoduleRepo, err := git.PlainOpen(submoduleLocation)
+		if err == nil {
+			remote, err := submoduleRepo.Remote(git.DefaultRemoteName)
+			if err != nil || len(remote.Config().URLs) == 0 || remote.Config().URLs[0] != submoduleUrl {
+				// submodule url changed, mirror is created again
+				submoduleRepo = nil
+			}
+		}
+		if submoduleRepo == nil {
+			err = os.RemoveAll(submoduleLocation)
+			if err != nil {
+				return updated, err
+			}
+			err = impl.gitUtil.Init(submoduleLocation, submoduleUrl, true)
+			if err != nil {
+				impl.logger.Errorw("error in init of submodule", "submodule", name, "url", submoduleUrl, "err", err)
+				return updated, err
+			}
+			if len(sshCommand) > 0 {
+				_, errorMsg, err := impl.gitUtil.SetConfig(ctx, submoduleLocation, "core.sshCommand", sshCommand)
+				if err != nil {
+					impl.logger.Errorw("error in configuring ssh command of submodule", "submodule", name, "errorMsg", errorMsg, "err", err)
+					return updated, err
+				}
+			}
+		}
+		// refspecs of parent do not apply to submodule, whole submodule is mirrored
+		submoduleCloneOptions := &CloneOptions{Strategy: cloneOptions.Strategy, Depth: cloneOptions.Depth}
+		fetch := impl.gitUtil.Fetch
+		if submoduleRepo == nil {
+			fetch = impl.gitUtil.Clone
+		}
+		res, errorMsg, err := fetch(ctx, submoduleLocation, userName, password, submoduleCloneOptions.FetchArgs()...)
+		if err != nil {
+			impl.logger.Errorw("error in fetching submodule", "submodule", name, "url", submoduleUrl, "errorMsg", errorMsg, "err", err)
+			return updated, err
+		}
+		updated = updated || len(res) > 0
+	}
+	return updated, nil
+}
+
+// getSubmodules returns url of submodules keyed by submodule name, read from .gitmodules of all remote branch heads.
+// .gitmodules is read with git cli, which fetches trees and blobs missing in partial clone from remote
+func (impl RepositoryManagerImpl) getSubmodules(ctx context.Context, repository *git.Repository, location string, userName, password string) (map[string]string, error) {
+	branchHeads, err := impl.GetRemoteBranchHeads(repository)
 	if err != nil {
-		impl.logger.Errorw("error in getting fs", "path", checkoutPath, "err", err)
 		return nil, err
 	}
-	for _, f := range fs {
-		gitCommit.Changes = append(gitCommit.Changes, f.Name)
+	submodules := make(map[string]string)
+	for _, head := range branchHeads {
+		entry, err := impl.getTreeEntry(ctx, location, userName, password, head, GIT_MODULES_FILE)
+		if _, ok := err.(*PathNotFoundError); ok {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var content bytes.Buffer
+		errMsg, err := impl.gitUtil.CatBlob(ctx, location, userName, password, &content, entry.Hash)
+		if err != nil {
+			impl.logger.Errorw("error in reading .gitmodules", "location", location, "commit", head, "errorMsg", errMsg, "err", err)
+			return nil, err
+		}
+		modules := config.NewModules()
+		err = modules.Unmarshal(content.Bytes())
+		if err != nil {
+			impl.logger.Warnw("skipping invalid .gitmodules", "commit", head, "err", err)
+			continue
+		}
+		for name, submodule := range modules.Submodules {
+			submodules[name] = submodule.URL
+		}
 	}
-	return gitCommit, nil
+	return submodules, nil
+}
+
+// getSubmoduleChanges returns submodule pointers moved by commit compared to its first parent, none are returned when
+// trees of commit or its parent are not present i.e. treeless clone or shallow boundary commit
+func (impl RepositoryManagerImpl) getSubmoduleChanges(commit *object.Commit) ([]*SubmoduleChange, error) {
+	tree, err := commit.Tree()
+	if err == plumbing.ErrObjectNotFound {
+		impl.logger.Debugw("tree not available for submodule changes", "commitHash", commit.Hash.String())
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err == nil {
+			parentTree, err = parent.Tree()
+		}
+		if err == plumbing.ErrObjectNotFound {
+			impl.logger.Debugw("parent not available for submodule changes", "commitHash", commit.Hash.String())
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	// commit removing the last submodule removes .gitmodules as well
+	if !hasGitModules(tree) && !hasGitModules(parentTree) {
+		return nil, nil
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var submoduleChanges []*SubmoduleChange
+	for _, change := range changes {
+		if change.From.TreeEntry.Mode != filemode.Submodule && change.To.TreeEntry.Mode != filemode.Submodule {
+			continue
+		}
+		submoduleChange := &SubmoduleChange{}
+		if change.From.TreeEntry.Mode == filemode.Submodule {
+			submoduleChange.Path = change.From.Name
+			submoduleChange.OldSha = change.From.TreeEntry.Hash.String()
+		}
+		if change.To.TreeEntry.Mode == filemode.Submodule {
+			submoduleChange.Path = change.To.Name
+			submoduleChange.NewSha = change.To.TreeEntry.Hash.String()
+		}
+		submoduleChanges = append(submoduleChanges, submoduleChange)
+	}
+	return submoduleChanges, nil
+}
+
+func hasGitModules(tree *object.Tree) bool {
+	if tree == nil {
+		return false
+	}
+	_, err := tree.FindEntry(GIT_MODULES_FILE)
+	return err == nil
+}
+
+// resolveSubmoduleUrl resolves ./ and ../ prefixed submodule url relative to url of parent repository
+func resolveSubmoduleUrl(parentUrl string, submoduleUrl string) string {
+	if !strings.HasPrefix(submoduleUrl, "./") && !strings.HasPrefix(submoduleUrl, "../") {
+		return submoduleUrl
+	}
+	base := strings.TrimSuffix(parentUrl, "/")
+	for {
+		if strings.HasPrefix(submoduleUrl, "./") {
+			submoduleUrl = strings.TrimPrefix(submoduleUrl, "./")
+		} else if strings.HasPrefix(submoduleUrl, "../") {
+			submoduleUrl = strings.TrimPrefix(submoduleUrl, "../")
+			if i := strings.LastIndexAny(base, "/:"); i >= 0 {
+				base = base[:i]
+			}
+		} else {
+			break
+		}
+	}
+	return base + "/" + submoduleUrl
+}
+
+// GetTagHeads returns commit hash pointed by every tag keyed by tag name, annotated tags are peeled to their commit
+func (impl RepositoryManagerImpl) GetTagHeads(repository *git.Repository) (map[string]string, error) {
+	tagRefs, err := repository.Tags()
+	if err != nil {
+		impl.logger.Errorw("error in getting tags", "err", err)
+		return nil, err
+	}
+	tagHeads := make(map[string]string)
+	err = tagRefs.ForEach(func(ref *plumbing.Reference) error {
+		commitHash, err := resolveTagCommitHash(repository, ref)
+		if err != nil {
+			impl.logger.Warnw("skipping tag not pointing to a commit", "tag", ref.Name().Short(), "err", err)
+			return nil
+		}
+		tagHeads[ref.Name().Short()] = commitHash.String()
+		return nil
+	})
+	return tagHeads, err
+}
+
+func resolveTagCommitHash(repository *git.Repository, tagRef *plumbing.Reference) (plumbing.Hash, error) {
+	tagObject, err := repository.TagObject(tagRef.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		// lightweight tag
+		return tagRef.Hash(), nil
+	} else if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit, err := tagObject.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return commit.Hash, nil
+}
+
+// RefNotFoundError is returned when ref is neither a commit, a fetched branch nor a tag of the repository
+type RefNotFoundError struct {
+	Ref string
+}
+
+func (e *RefNotFoundError) Error() string {
+	return fmt.Sprintf("ref %s not found in the repository", e.Ref)
+}
+
+// resolveCommitHash resolves branch of material, tag or commit hash to commit hash, branches are looked up first as
+// they are only fetched under remote refs
+func resolveCommitHash(repository *git.Repository, ref string) (plumbing.Hash, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return plumbing.ZeroHash, &RefNotFoundError{Ref: ref}
+	}
+	branchRef, err := repository.Reference(plumbing.ReferenceName(REMOTE_BRANCH_REF_PREFIX+strings.TrimPrefix(ref, "refs/heads/")), true)
+	if err == nil {
+		return branchRef.Hash(), nil
+	}
+	hash, err := repository.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return plumbing.ZeroHash, &RefNotFoundError{Ref: ref}
+	}
+	return *hash, nil
 }
 
 func (impl RepositoryManagerImpl) GetCommitMetadata(checkoutPath, commitHash string) (*GitCommit, error) {
@@ -174,27 +595,104 @@ func (impl RepositoryManagerImpl) GetCommitMetadata(checkoutPath, commitHash str
 		impl.logger.Errorw("error in fetching commit", "path", checkoutPath, "hash", commitHash, "err", err)
 		return nil, err
 	}
-	gitCommit := &GitCommit{
-		Author:  commit.Author.String(),
-		Commit:  commit.Hash.String(),
-		Date:    commit.Author.When,
-		Message: commit.Message,
+	gitCommit, err := impl.toGitCommit(checkoutPath, commit)
+	if err != nil {
+		impl.logger.Errorw("error in getting changes of commit", "path", checkoutPath, "hash", commitHash, "err", err)
+		return nil, err
 	}
-	fs, err := impl.getStats(commit)
+	return gitCommit, nil
+}
+
+// GetCommitHeader reads only hash, author, date and message of commit, its stats are left pending
+func (impl RepositoryManagerImpl) GetCommitHeader(repository *git.Repository, commitHash string) (*GitCommit, error) {
+	commit, err := repository.CommitObject(plumbing.NewHash(commitHash))
 	if err != nil {
-		impl.logger.Errorw("error in getting fs", "path", checkoutPath, "err", err)
+		impl.logger.Errorw("error in fetching commit", "hash", commitHash, "err", err)
 		return nil, err
 	}
-	for _, f := range fs {
-		gitCommit.Changes = append(gitCommit.Changes, f.Name)
+	return &GitCommit{
+		Author:      commit.Author.String(),
+		Commit:      commit.Hash.String(),
+		Date:        commit.Author.When,
+		Message:     commit.Message,
+		StatsStatus: COMMIT_STATS_STATUS_PENDING,
+	}, nil
+}
+
+// toGitCommit converts commit with names of changed files and changes of submodules, stats not computed yet are queued
+// and commit is marked pending
+func (impl RepositoryManagerImpl) toGitCommit(checkoutPath string, commit *object.Commit) (*GitCommit, error) {
+	gitCommit, err := impl.toPendingGitCommit(commit)
+	if err != nil {
+		return nil, err
 	}
+	impl.commitStatsService.FillStats(checkoutPath, []*GitCommit{gitCommit})
 	return gitCommit, nil
 }
 
+// ChangesSinceByRepository does not wait for stats of commits, stats not computed yet are queued and commits are marked
+// pending
 //from -> old commit
 //to -> new commit
 //
-func (impl RepositoryManagerImpl) ChangesSinceByRepository(repository *git.Repository, branch string, from string, to string, count int) ([]*GitCommit, error) {
+func (impl RepositoryManagerImpl) ChangesSinceByRepository(checkoutPath string, repository *git.Repository, branch string, from string, to string, count int) ([]*GitCommit, error) {
+	gitCommits, err := impl.changesSince(repository, branch, from, to, count)
+	impl.commitStatsService.FillStats(checkoutPath, gitCommits)
+	return gitCommits, err
+}
+
+// NewCommitsSinceByRepository returns commits of branch which are not reachable from lastSeenHash, newest first, as
+// listed by git rev-list lastSeenHash..branch in topological order, so commits brought in by merges are included. At
+// most count commits are returned, truncated tells that commits do not reach back to lastSeenHash i.e. there were more
+// new commits, or lastSeenHash is blank or not present in repository and latest count commits of branch are returned.
+// Stats are not waited for, like in ChangesSinceByRepository
+func (impl RepositoryManagerImpl) NewCommitsSinceByRepository(ctx context.Context, checkoutPath string, repository *git.Repository, branch string, lastSeenHash string, count int) (commits []*GitCommit, truncated bool, err error) {
+	if len(lastSeenHash) > 0 {
+		_, err = repository.CommitObject(plumbing.NewHash(lastSeenHash))
+	}
+	if len(lastSeenHash) == 0 || err == plumbing.ErrObjectNotFound {
+		// last seen commit is gone after force push and gc
+		commits, err = impl.ChangesSinceByRepository(checkoutPath, repository, branch, "", "", count)
+		return commits, true, err
+	} else if err != nil {
+		impl.logger.Errorw("error in getting last seen commit", "branch", branch, "commit", lastSeenHash, "err", err)
+		return nil, false, err
+	}
+	ref, err := impl.getBranchReference(repository, branch)
+	if err != nil {
+		return nil, false, err
+	}
+	var out bytes.Buffer
+	// one more commit than count is listed to know if there are more
+	errMsg, err := impl.gitUtil.RevList(ctx, checkoutPath, "", "", &out, "--topo-order",
+		fmt.Sprintf("--max-count=%d", count+1), lastSeenHash+".."+ref.Hash().String())
+	if err != nil {
+		impl.logger.Errorw("error in listing new commits", "branch", branch, "from", lastSeenHash, "to", ref.Hash().String(), "errMsg", errMsg, "err", err)
+		return nil, false, err
+	}
+	hashes := strings.Fields(out.String())
+	if len(hashes) > count {
+		hashes = hashes[:count]
+		truncated = true
+	}
+	for _, hash := range hashes {
+		commit, err := repository.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			impl.logger.Errorw("error in getting commit", "branch", branch, "commit", hash, "err", err)
+			return nil, false, err
+		}
+		gitCommit, err := impl.toPendingGitCommit(commit)
+		if err != nil {
+			impl.logger.Errorw("error in getting changes of commit", "branch", branch, "commit", hash, "err", err)
+			return nil, false, err
+		}
+		commits = append(commits, gitCommit)
+	}
+	impl.commitStatsService.FillStats(checkoutPath, commits)
+	return commits, truncated, nil
+}
+
+func (impl RepositoryManagerImpl) getBranchReference(repository *git.Repository, branch string) (*plumbing.Reference, error) {
 	// fix for azure devops (manual trigger webhook bases pipeline) :
 	// branch name comes as 'refs/heads/master', we need to extract actual branch name out of it.
 	// https://stackoverflow.com/questions/59956206/how-to-get-a-branch-name-with-a-slash-in-azure-devops
@@ -202,15 +700,37 @@ func (impl RepositoryManagerImpl) ChangesSinceByRepository(repository *git.Repos
 		branch = strings.ReplaceAll(branch, "refs/heads/", "")
 	}
 
-	branchRef := fmt.Sprintf("refs/remotes/origin/%s", branch)
+	branchRef := REMOTE_BRANCH_REF_PREFIX + branch
 	ref, err := repository.Reference(plumbing.ReferenceName(branchRef), true)
 	if err != nil && err == plumbing.ErrReferenceNotFound {
 		impl.logger.Errorw("ref not found", "branch", branch, "err", err)
-		return nil, fmt.Errorf("branch %s not found in the repository ", branch)
+		return nil, &BranchNotFoundError{Branch: branch}
 	} else if err != nil {
 		impl.logger.Errorw("error in getting reference", "branch", branch, "err", err)
 		return nil, err
 	}
+	return ref, nil
+}
+
+// toPendingGitCommit converts commit without waiting for its stats, stats are filled by CommitStatsService
+func (impl RepositoryManagerImpl) toPendingGitCommit(commit *object.Commit) (*GitCommit, error) {
+	gitCommit := &GitCommit{
+		Author:      commit.Author.String(),
+		Commit:      commit.Hash.String(),
+		Date:        commit.Author.When,
+		Message:     commit.Message,
+		StatsStatus: COMMIT_STATS_STATUS_PENDING,
+	}
+	var err error
+	gitCommit.SubmoduleChanges, err = impl.getSubmoduleChanges(commit)
+	return gitCommit, err
+}
+
+func (impl RepositoryManagerImpl) changesSince(repository *git.Repository, branch string, from string, to string, count int) ([]*GitCommit, error) {
+	ref, err := impl.getBranchReference(repository, branch)
+	if err != nil {
+		return nil, err
+	}
 	itr, err := repository.Log(&git.LogOptions{From: ref.Hash()})
 	if err != nil {
 		impl.logger.Errorw("error in getting iterator", "branch", branch, "err", err)
@@ -241,60 +761,154 @@ func (impl RepositoryManagerImpl) ChangesSinceByRepository(repository *git.Repos
 			//found end
 			break
 		}
-		gitCommit := &GitCommit{
-			Author:  commit.Author.String(),
-			Commit:  commit.Hash.String(),
-			Date:    commit.Author.When,
-			Message: commit.Message,
-		}
-		fs, err := impl.getStats(commit)
+		gitCommit, err := impl.toPendingGitCommit(commit)
 		if err != nil {
-			impl.logger.Errorw("error in getting fs", "branch", branch, "err", err)
+			impl.logger.Errorw("error in getting changes of commit", "branch", branch, "commit", commit.Hash.String(), "err", err)
 			break
 		}
-		for _, f := range fs {
-			gitCommit.Changes = append(gitCommit.Changes, f.Name)
-		}
 		gitCommits = append(gitCommits, gitCommit)
 		itrCounter = itrCounter + 1
 	}
 	return gitCommits, err
 }
 
-// this function gives file stats in timed manner.
-// if timed-out, return empty result without error
-func (impl RepositoryManagerImpl) getStats(commit *object.Commit) (object.FileStats, error) {
-	result := make(chan FileStatsResult, 1)
-	go func() {
-		result <- impl.getUntimedFileStats(commit)
-	}()
+// GetRemoteBranchHeads returns head commit hash of every branch fetched under refs/remotes/origin, keyed by branch name
+func (impl RepositoryManagerImpl) GetRemoteBranchHeads(repository *git.Repository) (map[string]string, error) {
+	refs, err := repository.References()
+	if err != nil {
+		impl.logger.Errorw("error in getting references", "err", err)
+		return nil, err
+	}
+	branchHeads := make(map[string]string)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if !strings.HasPrefix(name, REMOTE_BRANCH_REF_PREFIX) || ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		branchHeads[strings.TrimPrefix(name, REMOTE_BRANCH_REF_PREFIX)] = ref.Hash().String()
+		return nil
+	})
+	return branchHeads, err
+}
 
-	select {
-	case <-time.After(time.Duration(impl.configuration.CommitStatsTimeoutInSec) * time.Second):
-		impl.logger.Errorw("Timeout occurred for getting file stats", "commit", commit.Hash.String())
-		return nil, nil
-	case result := <-result:
-		return result.FileStats, result.Error
+// VerifyCommitSignatures sets signature of commits verified against trusted keys of git provider
+func (impl RepositoryManagerImpl) VerifyCommitSignatures(checkoutPath string, commits []*GitCommit, gitProvider *sql.GitProvider) {
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		impl.logger.Errorw("error in opening repository for signature verification", "path", checkoutPath, "err", err)
+		return
+	}
+	trustedKeys, err := NewTrustedKeys(gitProvider)
+	if err != nil {
+		// commits signed with valid keys are reported as untrusted until keys of provider are fixed
+		impl.logger.Errorw("error in parsing trusted keys of git provider", "gitProviderId", gitProvider.Id, "err", err)
+	}
+	for _, gitCommit := range commits {
+		commit, err := repository.CommitObject(plumbing.NewHash(gitCommit.Commit))
+		if err != nil {
+			impl.logger.Errorw("error in fetching commit for signature verification", "hash", gitCommit.Commit, "err", err)
+			continue
+		}
+		gitCommit.Signature = VerifyCommitSignature(commit, trustedKeys)
 	}
 }
 
-// this function gives file stats in untimed manner. There is no timeout for this
-// avoid calling this method directly until and unless timeout is not needed
-func (impl RepositoryManagerImpl) getUntimedFileStats(commit *object.Commit) FileStatsResult {
-	defer func() {
-		if err := recover(); err != nil {
-			// sometimes the Patch generation will fail due to a known bug in
-			// sergi's go-diff: https://github.com/sergi/go-diff/issues/89.
-			impl.logger.Errorw("panic error in commit getStats", "commitHash", commit.Hash.String(), "err", err)
-			return
+// FindOrphanedCommits checks if old head is still an ancestor of new head, when it is not the history was rewritten
+// and commits reachable only from old head are returned
+func (impl RepositoryManagerImpl) FindOrphanedCommits(repository *git.Repository, oldHead string, newHead string) (rewritten bool, orphanedCommits []*GitCommit, err error) {
+	newCommit, err := repository.CommitObject(plumbing.NewHash(newHead))
+	if err != nil {
+		return false, nil, err
+	}
+	oldCommit, err := repository.CommitObject(plumbing.NewHash(oldHead))
+	if err == plumbing.ErrObjectNotFound {
+		// old head is not known to this repository anymore, it can't be part of new history
+		return true, []*GitCommit{{Commit: oldHead}}, nil
+	} else if err != nil {
+		return false, nil, err
+	}
+	isAncestor, err := oldCommit.IsAncestor(newCommit)
+	if err != nil || isAncestor {
+		return false, nil, err
+	}
+	mergeBases, err := oldCommit.MergeBase(newCommit)
+	if err != nil {
+		return true, nil, err
+	}
+	visited := make(map[plumbing.Hash]bool)
+	for _, mergeBase := range mergeBases {
+		visited[mergeBase.Hash] = true
+	}
+	pending := []*object.Commit{oldCommit}
+	for len(pending) > 0 && len(orphanedCommits) < MAX_ORPHANED_COMMITS {
+		commit := pending[0]
+		pending = pending[1:]
+		if visited[commit.Hash] {
+			continue
 		}
-	}()
+		visited[commit.Hash] = true
+		orphanedCommits = append(orphanedCommits, &GitCommit{
+			Author:  commit.Author.String(),
+			Commit:  commit.Hash.String(),
+			Date:    commit.Author.When,
+			Message: commit.Message,
+		})
+		err = commit.Parents().ForEach(func(parent *object.Commit) error {
+			pending = append(pending, parent)
+			return nil
+		})
+		if err != nil {
+			return true, orphanedCommits, err
+		}
+	}
+	return true, orphanedCommits, nil
+}
+
+// ChangedFilesBetween returns paths changed between trees of from and to commits, submodule pointers are reported as paths
+func (impl RepositoryManagerImpl) ChangedFilesBetween(repository *git.Repository, from string, to string) ([]string, error) {
+	fromCommit, err := repository.CommitObject(plumbing.NewHash(from))
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := repository.CommitObject(plumbing.NewHash(to))
+	if err != nil {
+		return nil, err
+	}
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		impl.logger.Errorw("error in diff of trees", "from", from, "to", to, "err", err)
+		return nil, err
+	}
+	var paths []string
+	for _, change := range changes {
+		if len(change.From.Name) > 0 {
+			paths = append(paths, change.From.Name)
+		}
+		if len(change.To.Name) > 0 && change.To.Name != change.From.Name {
+			paths = append(paths, change.To.Name)
+		}
+	}
+	return paths, nil
+}
 
-	fs, err := commit.Stats()
-	return FileStatsResult{
-		FileStats: fs,
-		Error:     err,
+func fileNamesToStats(changes object.Changes) object.FileStats {
+	var fileStats object.FileStats
+	for _, change := range changes {
+		name := change.To.Name
+		if len(name) == 0 {
+			name = change.From.Name
+		}
+		fileStats = append(fileStats, object.FileStat{Name: name})
 	}
+	return fileStats
 }
 
 func (impl RepositoryManagerImpl) ChangesSince(checkoutPath string, branch string, from string, to string, count int) ([]*GitCommit, error) {
@@ -306,24 +920,28 @@ func (impl RepositoryManagerImpl) ChangesSince(checkoutPath string, branch strin
 		return nil, err
 	}
 	///---------------------
-	return impl.ChangesSinceByRepository(r, branch, from, to, count)
+	gitCommits, err := impl.changesSince(r, branch, from, to, count)
+	impl.commitStatsService.FillStats(checkoutPath, gitCommits)
+	return gitCommits, err
 	///----------------------
 
 }
 
 type GitChanges struct {
-	Commits   []*Commit
-	FileStats object.FileStats
+	Commits      []*Commit
+	FileStats    object.FileStats
+	ReleaseNotes *ReleaseNotes `json:",omitempty"`
 }
 
 type FileStatsResult struct {
 	FileStats object.FileStats
+	Partial   bool // only names of changed files, line counts are zero
 	Error     error
 }
 
 //from -> old commit
 //to -> new commit
-func (impl RepositoryManagerImpl) ChangesSinceByRepositoryForAnalytics(checkoutPath string, branch string, Old string, New string) (*GitChanges, error) {
+func (impl RepositoryManagerImpl) ChangesSinceByRepositoryForAnalytics(ctx context.Context, checkoutPath string, branch string, Old string, New string) (*GitChanges, error) {
 	GitChanges := &GitChanges{}
 	repository, err := git.PlainOpen(checkoutPath)
 	if err != nil {
@@ -347,17 +965,33 @@ func (impl RepositoryManagerImpl) ChangesSinceByRepositoryForAnalytics(checkoutP
 	if err != nil {
 		return nil, err
 	}
+	var fileStats object.FileStats
 	patch, err := oldTree.Patch(newTree)
-	if err != nil {
+	if err == plumbing.ErrObjectNotFound {
+		// blobs are not present in partial clone, stats are computed from trees without line counts
+		changes, err := object.DiffTree(oldTree, newTree)
+		if err != nil {
+			impl.logger.Errorw("can't get diff of trees: ", "err", err)
+			return nil, err
+		}
+		fileStats = fileNamesToStats(changes)
+	} else if err != nil {
 		impl.logger.Errorw("can'tget patch: ", "err", err)
 		return nil, err
+	} else {
+		fileStats = patch.Stats()
 	}
-	commits, err := computeDiff(repository, &newHash, &oldHash)
+	commitHashes, err := impl.listUniqueCommitHashes(ctx, checkoutPath, "", "", New, Old, 0)
 	if err != nil {
 		impl.logger.Errorw("can't get commits: ", "err", err)
 	}
 	var serializableCommits []*Commit
-	for _, c := range commits {
+	for _, commitHash := range commitHashes {
+		c, err := repository.CommitObject(plumbing.NewHash(commitHash))
+		if err != nil {
+			impl.logger.Errorw("can't get commit: ", "commit", commitHash, "err", err)
+			continue
+		}
 		t, err := repository.TagObject(c.Hash)
 		if err != nil && err != plumbing.ErrObjectNotFound {
 			impl.logger.Errorw("can't get tag: ", "err", err)
@@ -365,13 +999,12 @@ func (impl RepositoryManagerImpl) ChangesSinceByRepositoryForAnalytics(checkoutP
 		serializableCommits = append(serializableCommits, transform(c, t))
 	}
 	GitChanges.Commits = serializableCommits
-	fileStats := patch.Stats()
 	impl.logger.Debugw("computed files stats", "filestats", fileStats)
 	GitChanges.FileStats = fileStats
 	return GitChanges, nil
 }
 
-func (impl RepositoryManagerImpl) CreateSshFileIfNotExistsAndConfigureSshCommand(location string, gitProviderId int, sshPrivateKeyContent string) error {
+func (impl RepositoryManagerImpl) CreateSshFileIfNotExistsAndConfigureSshCommand(ctx context.Context, location string, gitProviderId int, sshPrivateKeyContent string) error {
 	// add private key
 	sshPrivateKeyPath, err := GetOrCreateSshPrivateKeyOnDisk(gitProviderId, sshPrivateKeyContent)
 	if err != nil {
@@ -380,7 +1013,7 @@ func (impl RepositoryManagerImpl) CreateSshFileIfNotExistsAndConfigureSshCommand
 	}
 
 	//git config core.sshCommand
-	_, errorMsg, err := impl.gitUtil.ConfigureSshCommand(location, sshPrivateKeyPath)
+	_, errorMsg, err := impl.gitUtil.ConfigureSshCommand(ctx, location, sshPrivateKeyPath)
 	if err != nil {
 		impl.logger.Errorw("error in configuring ssh command while adding repo", "errorMsg", errorMsg, "err", err)
 		return err
@@ -389,118 +1022,6 @@ func (impl RepositoryManagerImpl) CreateSshFileIfNotExistsAndConfigureSshCommand
 	return nil
 }
 
-func computeDiff(r *git.Repository, newHash *plumbing.Hash, oldHash *plumbing.Hash) ([]*object.Commit, error) {
-	processed := make(map[string]*object.Commit, 0)
-	//t := time.Now()
-	h := newHash  //plumbing.NewHash(newHash)
-	h2 := oldHash //plumbing.NewHash(oldHash)
-	c1, err := r.CommitObject(*h)
-	if err != nil {
-		return nil, fmt.Errorf("not found commit %s", h.String())
-	}
-	c2, err := r.CommitObject(*h2)
-	if err != nil {
-		return nil, fmt.Errorf("not found commit %s", h2.String())
-	}
-
-	var parents, ancestorStack []*object.Commit
-	ps := c1.Parents()
-	for {
-		n, err := ps.Next()
-		if err == io.EOF {
-			break
-		}
-		if n.Hash.String() != c2.Hash.String() {
-			parents = append(parents, n)
-		}
-	}
-	ancestorStack = append(ancestorStack, parents...)
-	processed[c1.Hash.String()] = c1
-
-	for len(ancestorStack) > 0 {
-		lastIndex := len(ancestorStack) - 1
-		//dont process already processed in this algorithm path is not important
-		if _, ok := processed[ancestorStack[lastIndex].Hash.String()]; ok {
-			ancestorStack = ancestorStack[:lastIndex]
-			continue
-		}
-		//if this is old commit provided for processing then ignore it
-		if ancestorStack[lastIndex].Hash.String() == c2.Hash.String() {
-			ancestorStack = ancestorStack[:lastIndex]
-			continue
-		}
-		m, err := ancestorStack[lastIndex].MergeBase(c2)
-		//fmt.Printf("mergebase between %s and %s is %s length %d\n", ancestorStack[lastIndex].Hash.String(), c2.Hash.String(), m[0].Hash.String(), len(m))
-		if err != nil {
-			log.Fatal("Error in mergebase " + ancestorStack[lastIndex].Hash.String() + " " + c2.Hash.String())
-		}
-		// if commit being analyzed is itself merge commit then dont process as it is common in both old and new
-		if in(ancestorStack[lastIndex], m) {
-			ancestorStack = ancestorStack[:lastIndex]
-			continue
-		}
-		d, p := getDiffTillBranchingOrDest(ancestorStack[lastIndex], m)
-		//fmt.Printf("length of diff %d\n", len(d))
-		for _, v := range d {
-			processed[v.Hash.String()] = v
-		}
-		curNodes := make(map[string]bool, 0)
-		for _, v := range ancestorStack {
-			curNodes[v.Hash.String()] = true
-		}
-		processed[ancestorStack[lastIndex].Hash.String()] = ancestorStack[lastIndex]
-		ancestorStack = ancestorStack[:lastIndex]
-		for _, v := range p {
-			if ok2, _ := curNodes[v.Hash.String()]; !ok2 {
-				ancestorStack = append(ancestorStack, v)
-			}
-		}
-	}
-	var commits []*object.Commit
-	for _, d := range processed {
-		commits = append(commits, d)
-	}
-	return commits, nil
-}
-
-func getDiffTillBranchingOrDest(src *object.Commit, dst []*object.Commit) (diff, parents []*object.Commit) {
-	if in(src, dst) {
-		return
-	}
-	new := src
-	for {
-		ps := new.Parents()
-		parents = make([]*object.Commit, 0)
-		for {
-			n, err := ps.Next()
-			if err == io.EOF {
-				break
-			}
-			parents = append(parents, n)
-		}
-		if len(parents) > 1 || len(parents) == 0 {
-			return
-		}
-		if in(parents[0], dst) {
-			parents = nil
-			return
-		} else {
-			//fmt.Printf("added %s when child is %s and merge base is %s", parents[0].Hash.String(), src.Hash.String(), dst[0].Hash.String())
-			diff = append(diff, parents[0])
-		}
-		new = parents[0]
-	}
-}
-
-func in(obj *object.Commit, list []*object.Commit) bool {
-	for _, v := range list {
-		if v.Hash.String() == obj.Hash.String() {
-			return true
-		}
-	}
-	return false
-}
-
 func transform(src *object.Commit, tag *object.Tag) (dst *Commit) {
 	if src == nil {
 		return nil
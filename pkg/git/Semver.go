@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Semver is a parsed semantic version, tags like v1.2.3, 1.2 and 1.2.3-rc.1+build are accepted
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	// number of version parts present in source string, used for x-ranges like 1.2
+	parts int
+}
+
+func ParseSemver(version string) (*Semver, error) {
+	v := strings.TrimSpace(version)
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	semver := &Semver{}
+	if i := strings.Index(v, "-"); i >= 0 {
+		semver.PreRelease = v[i+1:]
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(v) == 0 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid semver %s", version)
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid semver %s", version)
+		}
+		numbers[i] = number
+		semver.parts = i + 1
+	}
+	semver.Major, semver.Minor, semver.Patch = numbers[0], numbers[1], numbers[2]
+	return semver, nil
+}
+
+// Compare returns -1, 0 or 1 when v is lower, equal or greater than other
+func (v *Semver) Compare(other *Semver) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+func (v *Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s = s + "-" + v.PreRelease
+	}
+	return s
+}
+
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// version without pre-release has higher precedence, identifiers are compared numerically when both are numbers
+func comparePreRelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+	aIds := strings.Split(a, ".")
+	bIds := strings.Split(b, ".")
+	for i := 0; i < len(aIds) && i < len(bIds); i++ {
+		aNum, aErr := strconv.Atoi(aIds[i])
+		bNum, bErr := strconv.Atoi(bIds[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInt(aNum, bNum)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(aIds[i], bIds[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(aIds), len(bIds))
+}
+
+type semverComparator struct {
+	operator string
+	version  *Semver
+}
+
+func (c *semverComparator) matches(v *Semver) bool {
+	result := v.Compare(c.version)
+	switch c.operator {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	default:
+		return result == 0
+	}
+}
+
+// SemverRange is a set of comparator groups joined by ||, comparators inside a group are space separated
+// and all of them must match. Supported forms are =, >, >=, <, <=, ^, ~ and x-ranges like 1.x or 1.2
+type SemverRange struct {
+	groups [][]*semverComparator
+}
+
+func ParseSemverRange(expression string) (*SemverRange, error) {
+	semverRange := &SemverRange{}
+	for _, group := range strings.Split(expression, "||") {
+		var comparators []*semverComparator
+		for _, term := range strings.Fields(group) {
+			termComparators, err := parseSemverTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semver range %s: %s", expression, err.Error())
+			}
+			comparators = append(comparators, termComparators...)
+		}
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("invalid semver range %s", expression)
+		}
+		semverRange.groups = append(semverRange.groups, comparators)
+	}
+	return semverRange, nil
+}
+
+func (r *SemverRange) Contains(v *Semver) bool {
+	for _, group := range r.groups {
+		matched := true
+		for _, comparator := range group {
+			if !comparator.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSemverTerm(term string) ([]*semverComparator, error) {
+	operator := ""
+	for _, op := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(term, op) {
+			operator = op
+			break
+		}
+	}
+	version, err := ParseSemver(strings.TrimPrefix(term, operator))
+	if err != nil {
+		return nil, err
+	}
+	lower := &Semver{Major: version.Major, Minor: version.Minor, Patch: version.Patch, PreRelease: version.PreRelease}
+	switch operator {
+	case ">", ">=", "<", "<=":
+		return []*semverComparator{{operator: operator, version: lower}}, nil
+	case "^":
+		upper := &Semver{Major: version.Major + 1}
+		if version.Major == 0 && version.parts > 1 {
+			upper = &Semver{Minor: version.Minor + 1}
+			if version.Minor == 0 && version.parts > 2 {
+				upper = &Semver{Patch: version.Patch + 1}
+			}
+		}
+		return []*semverComparator{{operator: ">=", version: lower}, {operator: "<", version: upper}}, nil
+	case "~":
+		upper := &Semver{Major: version.Major, Minor: version.Minor + 1}
+		if version.parts == 1 {
+			upper = &Semver{Major: version.Major + 1}
+		}
+		return []*semverComparator{{operator: ">=", version: lower}, {operator: "<", version: upper}}, nil
+	default:
+		// x-range, missing parts match anything
+		switch version.parts {
+		case 0:
+			return []*semverComparator{{operator: ">=", version: &Semver{}}}, nil
+		case 1:
+			return []*semverComparator{{operator: ">=", version: lower}, {operator: "<", version: &Semver{Major: version.Major + 1}}}, nil
+		case 2:
+			return []*semverComparator{{operator: ">=", version: lower}, {operator: "<", version: &Semver{Major: version.Major, Minor: version.Minor + 1}}}, nil
+		}
+		return []*semverComparator{{operator: "=", version: lower}}, nil
+	}
+}
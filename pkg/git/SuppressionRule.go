@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"fmt"
+	"github.com/devtron-labs/git-sensor/internal/sql"
+	"regexp"
+	"strings"
+)
+
+type SuppressionRuleMatcher struct {
+	skipMessageTokens  []string
+	skipAuthorPatterns []*regexp.Regexp
+	onlyMergeCommits   bool
+}
+
+// NewSuppressionRuleMatcher compiles suppression rules of material, nil matcher is returned when there is no rule
+func NewSuppressionRuleMatcher(rules *sql.SuppressionRules) (*SuppressionRuleMatcher, error) {
+	if rules == nil || (len(rules.SkipMessageTokens) == 0 && len(rules.SkipAuthorPatterns) == 0 && !rules.OnlyMergeCommits) {
+		return nil, nil
+	}
+	matcher := &SuppressionRuleMatcher{onlyMergeCommits: rules.OnlyMergeCommits}
+	for _, token := range rules.SkipMessageTokens {
+		if len(strings.TrimSpace(token)) > 0 {
+			matcher.skipMessageTokens = append(matcher.skipMessageTokens, strings.ToLower(token))
+		}
+	}
+	for _, pattern := range rules.SkipAuthorPatterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid author pattern %s: %s", pattern, err.Error())
+		}
+		matcher.skipAuthorPatterns = append(matcher.skipAuthorPatterns, regex)
+	}
+	return matcher, nil
+}
+
+// MatchedRule returns description of the first rule suppressing the commit, blank if commit is not suppressed
+func (matcher *SuppressionRuleMatcher) MatchedRule(message string, author string, isMerge bool) string {
+	lowerMessage := strings.ToLower(message)
+	for _, token := range matcher.skipMessageTokens {
+		if strings.Contains(lowerMessage, token) {
+			return fmt.Sprintf("commit message contains %s", token)
+		}
+	}
+	for _, pattern := range matcher.skipAuthorPatterns {
+		if pattern.MatchString(author) {
+			return fmt.Sprintf("commit author matches %s", pattern.String())
+		}
+	}
+	if matcher.onlyMergeCommits && !isMerge {
+		return "not a merge commit"
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// TagSourceTypeValue is the value of SOURCE_TYPE_TAG_ANY pipeline material, blank value matches every tag
+type TagSourceTypeValue struct {
+	Regex       string `json:"regex,omitempty"`
+	SemverRange string `json:"semverRange,omitempty"`
+}
+
+type TagFilter struct {
+	regex       *regexp.Regexp
+	semverRange *SemverRange
+}
+
+func NewTagFilter(materialValue string) (*TagFilter, error) {
+	filter := &TagFilter{}
+	if len(strings.TrimSpace(materialValue)) == 0 {
+		return filter, nil
+	}
+	value := &TagSourceTypeValue{}
+	err := json.Unmarshal([]byte(materialValue), value)
+	if err != nil {
+		return nil, err
+	}
+	if len(value.Regex) > 0 {
+		filter.regex, err = regexp.Compile(value.Regex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(value.SemverRange) > 0 {
+		filter.semverRange, err = ParseSemverRange(value.SemverRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// Matches checks tag against regex and semver range, tags which are not valid semver never match a semver range
+func (filter *TagFilter) Matches(tag string) bool {
+	if filter.regex != nil && !filter.regex.MatchString(tag) {
+		return false
+	}
+	if filter.semverRange != nil {
+		version, err := ParseSemver(tag)
+		if err != nil || !filter.semverRange.Contains(version) {
+			return false
+		}
+	}
+	return true
+}
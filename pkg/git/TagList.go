@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2020 Devtron Labs
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	DEFAULT_TAG_PAGE_SIZE = 50
+	MAX_TAG_PAGE_SIZE     = 500
+)
+
+type tagListEntry struct {
+	tag *TagInfo
+	// tag object of annotated tag, commit of lightweight tag
+	hash    plumbing.Hash
+	version *Semver
+	loaded  bool
+}
+
+// ListTags lists tags of remote matching glob and regex of request, tag objects are read only for the page unless
+// tags are sorted by date
+func (impl RepositoryManagerImpl) ListTags(ctx context.Context, checkoutPath string, userName string, password string, request *TagListRequest) (*TagListResponse, error) {
+	if len(request.Glob) > 0 {
+		if _, err := path.Match(request.Glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag glob %s: %v", request.Glob, err)
+		}
+	}
+	var nameRegex *regexp.Regexp
+	if len(request.Regex) > 0 {
+		var err error
+		nameRegex, err = regexp.Compile(request.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex %s: %v", request.Regex, err)
+		}
+	}
+	repository, err := git.PlainOpen(checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	remoteTags, err := impl.listRemoteTags(ctx, checkoutPath, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*tagListEntry
+	for _, entry := range remoteTags {
+		name := entry.tag.Name
+		if len(request.Glob) > 0 {
+			if matched, _ := path.Match(request.Glob, name); !matched {
+				continue
+			}
+		}
+		if nameRegex != nil && !nameRegex.MatchString(name) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if request.SortBy == TAG_SORT_BY_SEMVER {
+		for _, entry := range entries {
+			entry.version, _ = ParseSemver(entry.tag.Name)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i].version, entries[j].version
+			if a != nil && b != nil {
+				if c := a.Compare(b); c != 0 {
+					return c > 0
+				}
+			} else if a != nil || b != nil {
+				return a != nil
+			}
+			return entries[i].tag.Name > entries[j].tag.Name
+		})
+	} else {
+		for _, entry := range entries {
+			impl.setTagInfo(repository, entry)
+		}
+		// tags which are not fetched have no date, they follow the others by name
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].tag.Unresolved != entries[j].tag.Unresolved {
+				return !entries[i].tag.Unresolved
+			}
+			a, b := entries[i].tag.Date, entries[j].tag.Date
+			if !a.Equal(b) {
+				return a.After(b)
+			}
+			return entries[i].tag.Name > entries[j].tag.Name
+		})
+	}
+
+	offset := request.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	size := request.Size
+	if size <= 0 {
+		size = DEFAULT_TAG_PAGE_SIZE
+	} else if size > MAX_TAG_PAGE_SIZE {
+		size = MAX_TAG_PAGE_SIZE
+	}
+	response := &TagListResponse{TotalCount: len(entries), Offset: offset, Size: size, Tags: []*TagInfo{}}
+	if offset >= len(entries) {
+		return response, nil
+	}
+	end := offset + size
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[offset:end] {
+		impl.setTagInfo(repository, entry)
+		response.Tags = append(response.Tags, entry.tag)
+	}
+	return response, nil
+}
+
+// listRemoteTags lists tags with git ls-remote, as repository has tags fetched only for tag and webhook pipelines.
+// commit of annotated tag is known from its peeled entry even if tag object is not fetched
+func (impl RepositoryManagerImpl) listRemoteTags(ctx context.Context, checkoutPath string, userName string, password string) ([]*tagListEntry, error) {
+	var out bytes.Buffer
+	errMsg, err := impl.gitUtil.LsRemote(ctx, checkoutPath, userName, password, &out, "--tags")
+	if err != nil {
+		impl.logger.Errorw("error in listing tags of remote", "path", checkoutPath, "errorMsg", errMsg, "err", err)
+		return nil, fmt.Errorf("error in listing tags of remote: %s", strings.TrimSpace(errMsg))
+	}
+	var entries []*tagListEntry
+	entryByName := make(map[string]*tagListEntry)
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/tags/") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "refs/tags/")
+		peeled := strings.HasSuffix(name, "^{}")
+		name = strings.TrimSuffix(name, "^{}")
+		entry, ok := entryByName[name]
+		if !ok {
+			entry = &tagListEntry{tag: &TagInfo{Name: name}}
+			entryByName[name] = entry
+			entries = append(entries, entry)
+		}
+		if peeled {
+			entry.tag.Annotated = true
+			entry.tag.Commit = fields[0]
+		} else {
+			entry.hash = plumbing.NewHash(fields[0])
+		}
+	}
+	return entries, nil
+}
+
+// setTagInfo reads target commit of tag, and tagger and message from tag object of annotated tag
+func (impl RepositoryManagerImpl) setTagInfo(repository *git.Repository, entry *tagListEntry) {
+	if entry.loaded {
+		return
+	}
+	entry.loaded = true
+	tag := entry.tag
+	tagObject, err := repository.TagObject(entry.hash)
+	if err == nil {
+		tag.Annotated = true
+		tag.Tagger = tagObject.Tagger.String()
+		tag.Message = strings.TrimSpace(tagObject.Message)
+		tag.Date = tagObject.Tagger.When
+		commit, err := tagObject.Commit()
+		if err != nil {
+			// tag is still listed, without commit
+			impl.logger.Warnw("annotated tag not pointing to a commit", "tag", tag.Name, "err", err)
+			return
+		}
+		tag.Commit = commit.Hash.String()
+		return
+	} else if err != plumbing.ErrObjectNotFound {
+		impl.logger.Errorw("error in getting tag object", "tag", tag.Name, "err", err)
+		tag.Unresolved = true
+		return
+	}
+	if tag.Annotated {
+		// tag object is not fetched, tag is listed with its commit only
+		tag.Unresolved = true
+		return
+	}
+	// lightweight tag
+	tag.Commit = entry.hash.String()
+	commit, err := repository.CommitObject(entry.hash)
+	if err == plumbing.ErrObjectNotFound {
+		// commit is not fetched, tag is listed without tagger and date
+		tag.Unresolved = true
+		return
+	} else if err != nil {
+		impl.logger.Errorw("error in getting commit of tag", "tag", tag.Name, "commit", tag.Commit, "err", err)
+		tag.Unresolved = true
+		return
+	}
+	tag.Tagger = commit.Author.String()
+	tag.Date = commit.Author.When
+}
@@ -32,7 +32,19 @@ const (
 	SSH_PRIVATE_KEY_DIR = GIT_BASE_DIR + "ssh-keys/"
 	SSH_PRIVATE_KEY_FILE_NAME = "ssh_pvt_key"
 	CLONE_TIMEOUT_SEC   = 600
-	FETCH_TIMEOUT_SEC   = 30
+	FETCH_TIMEOUT_SEC   = 600
+)
+
+const (
+	COMMAND_TIMEOUT_SEC      = 30
+	READ_TIMEOUT_SEC         = 60
+	MAINTENANCE_TIMEOUT_SEC  = 600
+	REMOTE_BRANCH_REF_PREFIX = "refs/remotes/origin/"
+	GIT_MODULES_FILE         = ".gitmodules"
+	SUBMODULES_DIR           = "modules"
+	SHARED_STORE_DIR         = "shared"
+	MAX_ORPHANED_COMMITS     = 100
+	DEFAULT_CLONE_DEPTH      = 50
 )
 
 //git@gitlab.com:devtron-client-gitops/wms-user-management.git
@@ -41,26 +53,87 @@ const (
 //git@bitbucket.org:DelhiveryTech/kafka-consumer-config.git
 //https://prashant-delhivery@bitbucket.org/DelhiveryTech/kafka-consumer-config.git
 
+// GetLocationForMaterial returns location of object store shared by all materials which point to same remote with
+// same credentials and clone options, so that a repository referenced by many apps is cloned and fetched once
 func GetLocationForMaterial(material *sql.GitMaterial) (location string, err error) {
 	//gitRegex := `/(?:git|ssh|https?|git@[-\w.]+):(\/\/)?(.*?)(\.git)(\/?|\#[-\d\w._]+?)$/`
+	url := NormalizeUrl(material.Url)
+	storeDir := path.Join(GIT_BASE_DIR, SHARED_STORE_DIR, strconv.Itoa(material.GitProviderId))
 	httpsRegex := `^https.*`
-	httpsMatched, err := regexp.MatchString(httpsRegex, material.Url)
+	httpsMatched, err := regexp.MatchString(httpsRegex, url)
 	if httpsMatched {
-		locationWithoutProtocol := strings.ReplaceAll(material.Url, "https://", "")
-		checkoutPath := path.Join(GIT_BASE_DIR, strconv.Itoa(material.Id), locationWithoutProtocol)
+		locationWithoutProtocol := strings.ReplaceAll(url, "https://", "")
+		checkoutPath := path.Join(storeDir, locationWithoutProtocol+getStoreSuffix(material))
 		return checkoutPath, nil
 	}
 
 	sshRegex := `^git@.*`
-	sshMatched, err := regexp.MatchString(sshRegex, material.Url)
+	sshMatched, err := regexp.MatchString(sshRegex, url)
 	if sshMatched {
-		checkoutPath := path.Join(GIT_BASE_DIR, strconv.Itoa(material.Id), material.Url)
+		checkoutPath := path.Join(storeDir, url+getStoreSuffix(material))
 		return checkoutPath, nil
 	}
 	
 	return "", fmt.Errorf("unsupported format url %s", material.Url)
 }
 
+// NormalizeUrl makes urls of the same remote comparable, i.e. ignores case of host, user info of https urls,
+// trailing slash and .git suffix
+func NormalizeUrl(url string) string {
+	url = strings.TrimSpace(url)
+	url = strings.TrimSuffix(url, "/")
+	url = strings.TrimSuffix(url, ".git")
+	if strings.HasPrefix(url, "https://") {
+		hostAndPath := strings.TrimPrefix(url, "https://")
+		host, repoPath := hostAndPath, ""
+		if i := strings.Index(hostAndPath, "/"); i >= 0 {
+			host, repoPath = hostAndPath[:i], hostAndPath[i:]
+		}
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+		return "https://" + strings.ToLower(host) + repoPath
+	}
+	if strings.HasPrefix(url, "git@") {
+		hostAndPath := strings.TrimPrefix(url, "git@")
+		if i := strings.Index(hostAndPath, ":"); i >= 0 {
+			return "git@" + strings.ToLower(hostAndPath[:i]) + hostAndPath[i:]
+		}
+	}
+	return url
+}
+
+// getStoreSuffix keeps materials with different clone options in separate stores, as objects of a partial or shallow
+// store can not serve a material which needs full history and submodules are mirrored inside the store
+func getStoreSuffix(material *sql.GitMaterial) string {
+	suffix := ""
+	switch material.CloneStrategy {
+	case sql.CLONE_STRATEGY_BLOBLESS, sql.CLONE_STRATEGY_TREELESS:
+		suffix = "@" + strings.ToLower(string(material.CloneStrategy))
+	case sql.CLONE_STRATEGY_SHALLOW:
+		depth := material.CloneDepth
+		if depth <= 0 {
+			depth = DEFAULT_CLONE_DEPTH
+		}
+		suffix = fmt.Sprintf("@shallow-%d", depth)
+	}
+	if material.FetchSubmodules {
+		suffix = suffix + "@submodules"
+	}
+	return suffix
+}
+
+// IsSharedLocation tells if location is a shared object store, materials cloned by older versions have their own
+// location under material id
+func IsSharedLocation(location string) bool {
+	return strings.HasPrefix(location, path.Join(GIT_BASE_DIR, SHARED_STORE_DIR)+"/")
+}
+
+// GetLocationForSubmodule returns location of submodule mirror inside bare repository of parent, same as git keeps them
+func GetLocationForSubmodule(parentLocation string, submoduleName string) string {
+	return path.Join(parentLocation, SUBMODULES_DIR, path.Clean("/"+submoduleName))
+}
+
 func GetUserNamePassword(gitProvider *sql.GitProvider) (userName, password string, err error) {
 	switch gitProvider.AuthMode {
 	case sql.AUTH_MODE_USERNAME_PASSWORD:
@@ -25,28 +25,39 @@ import (
 	"github.com/gammazero/workerpool"
 	"github.com/nats-io/nats.go"
 
+	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"time"
 
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
 type GitWatcherImpl struct {
-	repositoryManager            RepositoryManager
-	materialRepo                 sql.MaterialRepository
-	cron                         *cron.Cron
-	logger                       *zap.SugaredLogger
-	ciPipelineMaterialRepository sql.CiPipelineMaterialRepository
-	pubSubClient                 *internal.PubSubClient
-	locker                       *internal.RepositoryLocker
-	pollConfig                   *PollConfig
-	webhookHandler               WebhookHandler
+	repositoryManager                  RepositoryManager
+	materialRepo                       sql.MaterialRepository
+	cron                               *cron.Cron
+	logger                             *zap.SugaredLogger
+	ciPipelineMaterialRepository       sql.CiPipelineMaterialRepository
+	pubSubClient                       *internal.PubSubClient
+	locker                             *internal.RepositoryLocker
+	pollConfig                         *PollConfig
+	webhookHandler                     WebhookHandler
+	ciPipelineMaterialBranchRepository sql.CiPipelineMaterialBranchRepository
+	gitMaterialTagRepository           sql.GitMaterialTagRepository
+	commitStoreService                 CommitStoreService
 }
 
 type GitWatcher interface {
-	PollAndUpdateGitMaterial(material *sql.GitMaterial) (*sql.GitMaterial, error)
+	PollAndUpdateGitMaterial(ctx context.Context, material *sql.GitMaterial) (*sql.GitMaterial, error)
+	SyncBranchRegexMaterial(ctx context.Context, checkoutLocation string, material *sql.CiPipelineMaterial) error
+	SyncTagMaterial(checkoutLocation string, material *sql.CiPipelineMaterial) error
+	RestoreEvictedMaterial(ctx context.Context, material *sql.GitMaterial) error
 }
 
 type PollConfig struct {
@@ -59,7 +70,10 @@ func NewGitWatcherImpl(repositoryManager RepositoryManager,
 	logger *zap.SugaredLogger,
 	ciPipelineMaterialRepository sql.CiPipelineMaterialRepository,
 	locker *internal.RepositoryLocker,
-	pubSubClient *internal.PubSubClient, webhookHandler WebhookHandler) (*GitWatcherImpl, error) {
+	pubSubClient *internal.PubSubClient, webhookHandler WebhookHandler,
+	ciPipelineMaterialBranchRepository sql.CiPipelineMaterialBranchRepository,
+	gitMaterialTagRepository sql.GitMaterialTagRepository,
+	commitStoreService CommitStoreService) (*GitWatcherImpl, error) {
 
 	cfg := &PollConfig{}
 	err := env.Parse(cfg)
@@ -74,15 +88,18 @@ func NewGitWatcherImpl(repositoryManager RepositoryManager,
 			cron.Recover(cronLogger)))
 	cron.Start()
 	watcher := &GitWatcherImpl{
-		repositoryManager:            repositoryManager,
-		cron:                         cron,
-		logger:                       logger,
-		ciPipelineMaterialRepository: ciPipelineMaterialRepository,
-		materialRepo:                 materialRepo,
-		locker:                       locker,
-		pubSubClient:                 pubSubClient,
-		pollConfig:                   cfg,
-		webhookHandler:               webhookHandler,
+		repositoryManager:                  repositoryManager,
+		cron:                               cron,
+		logger:                             logger,
+		ciPipelineMaterialRepository:       ciPipelineMaterialRepository,
+		materialRepo:                       materialRepo,
+		locker:                             locker,
+		pubSubClient:                       pubSubClient,
+		pollConfig:                         cfg,
+		webhookHandler:                     webhookHandler,
+		ciPipelineMaterialBranchRepository: ciPipelineMaterialBranchRepository,
+		gitMaterialTagRepository:           gitMaterialTagRepository,
+		commitStoreService:                 commitStoreService,
 	}
 	logger.Info()
 	_, err = cron.AddFunc(fmt.Sprintf("@every %dm", cfg.PollDuration), watcher.Watch)
@@ -114,19 +131,26 @@ func (impl GitWatcherImpl) Watch() {
 
 func (impl *GitWatcherImpl) RunOnWorker(materials []*sql.GitMaterial) {
 	wp := workerpool.New(impl.pollConfig.PollWorker)
+	// materials sharing an object store are polled together by a single job, which fetches the store once
+	polledLocations := make(map[string]bool)
 	for _, material := range materials {
 		if len(material.CiPipelineMaterials) == 0 {
 			impl.logger.Infow("no ci pipeline, skipping", "id", material.Id, "url", material.Url)
 			continue
 		}
+		if polledLocations[material.CheckoutLocation] {
+			continue
+		}
+		polledLocations[material.CheckoutLocation] = true
 		materialMsg := &sql.GitMaterial{Id: material.Id, Url: material.Url}
 		wp.Submit(func() {
-			_, err := impl.pollAndUpdateGitMaterial(materialMsg)
+			_, err := impl.pollAndUpdateGitMaterial(context.Background(), materialMsg)
 			if err != nil {
 				impl.logger.Errorw("error in polling git material", "material", materialMsg, "err", err)
 			}
 		})
 	}
+	middleware.GitStoreCount.WithLabelValues().Set(float64(len(polledLocations)))
 	wp.StopWait()
 }
 
@@ -175,7 +199,7 @@ func (impl GitWatcherImpl) SubscribePull() error {
 			impl.logger.Debugw("Error while getting metadata of message", "err", metaErr)
 		}
 		impl.logger.Debugw("polling for material", "id", material.Id, "url", material.Url, "msg timestamp", msgMetaData.Timestamp)
-		_, err = impl.pollAndUpdateGitMaterial(material)
+		_, err = impl.pollAndUpdateGitMaterial(context.Background(), material)
 		if err != nil {
 			impl.logger.Errorw("err in poling", "material", material, "err", err)
 		}
@@ -183,72 +207,218 @@ func (impl GitWatcherImpl) SubscribePull() error {
 	return err
 }
 
-func (impl GitWatcherImpl) PollAndUpdateGitMaterial(material *sql.GitMaterial) (*sql.GitMaterial, error) {
+func (impl GitWatcherImpl) PollAndUpdateGitMaterial(ctx context.Context, material *sql.GitMaterial) (*sql.GitMaterial, error) {
 	//tmp expose remove in future
-	return impl.pollAndUpdateGitMaterial(material)
+	return impl.pollAndUpdateGitMaterial(ctx, material)
 }
 
-func (impl GitWatcherImpl) pollAndUpdateGitMaterial(materialReq *sql.GitMaterial) (*sql.GitMaterial, error) {
-	repoLock := impl.locker.LeaseLocker(materialReq.Id)
+// pollAndUpdateGitMaterial fetches object store of material once and processes changes for all materials sharing it
+func (impl GitWatcherImpl) pollAndUpdateGitMaterial(ctx context.Context, materialReq *sql.GitMaterial) (*sql.GitMaterial, error) {
+	material, err := impl.materialRepo.FindById(materialReq.Id)
+	if err != nil {
+		impl.logger.Errorw("error in fetching material ", "material", materialReq, "err", err)
+		return nil, err
+	}
+	if sharedLocation, err := GetLocationForMaterial(material); err == nil && sharedLocation != material.CheckoutLocation {
+		err = impl.moveToSharedStore(ctx, material, sharedLocation)
+		if err != nil {
+			// keep polling at old location, moving is retried in next poll
+			impl.logger.Errorw("error in moving material to shared store", "id", material.Id, "location", sharedLocation, "err", err)
+		}
+	}
+	location := material.CheckoutLocation
+	repoLock := impl.locker.LeaseLocker(location)
 	repoLock.Mutex.Lock()
 	defer func() {
 		repoLock.Mutex.Unlock()
-		impl.locker.ReturnLocker(materialReq.Id)
+		impl.locker.ReturnLocker(location)
 	}()
-	material, err := impl.materialRepo.FindById(materialReq.Id)
+	materials, err := impl.materialRepo.FindByCheckoutLocation(location)
 	if err != nil {
-		impl.logger.Errorw("error in fetching material ", "material", materialReq, "err", err)
-		return nil, err
+		impl.logger.Errorw("error in fetching materials sharing location", "location", location, "err", err)
+		materials = nil
+	}
+	found := false
+	evicted := false
+	for i, sharingMaterial := range materials {
+		evicted = evicted || sharingMaterial.Evicted
+		if sharingMaterial.Id == material.Id {
+			materials[i] = material
+			found = true
+		}
+	}
+	if !found {
+		materials = append(materials, material)
+	}
+	if evicted || material.Evicted {
+		err = impl.restoreLocation(ctx, location, material, materials)
+		if err != nil {
+			impl.logger.Errorw("error in restoring evicted repository", "id", material.Id, "location", location, "err", err)
+			return material, err
+		}
+	}
+	updated, repo, err := impl.fetchGitMaterial(ctx, location, material, materials)
+	if err != nil && ctx.Err() != nil {
+		// poll aborted by caller, not a failure of the repository
+		impl.logger.Infow("polling of material cancelled", "id", material.Id, "err", err)
+		return material, err
 	}
-	err = impl.pollGitMaterialAndNotify(material)
-	material.LastFetchTime = time.Now()
-	material.FetchStatus = err == nil
+	for _, sharingMaterial := range materials {
+		pollErr := err
+		if pollErr == nil && updated {
+			pollErr = impl.notifyForGitMaterialChanges(ctx, repo, location, sharingMaterial)
+		}
+		sharingMaterial.LastFetchTime = time.Now()
+		sharingMaterial.FetchStatus = pollErr == nil
+		if pollErr != nil {
+			sharingMaterial.LastFetchErrorCount = sharingMaterial.LastFetchErrorCount + 1
+			sharingMaterial.FetchErrorMessage = pollErr.Error()
+		} else {
+			sharingMaterial.LastFetchErrorCount = 0
+			sharingMaterial.FetchErrorMessage = ""
+		}
+		updateErr := impl.materialRepo.Update(sharingMaterial)
+		if updateErr != nil {
+			impl.logger.Errorw("error in updating fetch status", "material", sharingMaterial, "err", updateErr)
+			if sharingMaterial.Id == material.Id {
+				return material, updateErr
+			}
+		}
+	}
+	return material, nil
+}
+
+// moveToSharedStore moves material cloned by older version at its own location to the object store shared with other
+// materials of the same remote, the store is cloned if no material has moved to it yet
+func (impl GitWatcherImpl) moveToSharedStore(ctx context.Context, material *sql.GitMaterial, sharedLocation string) error {
+	oldLocation := material.CheckoutLocation
+	if IsSharedLocation(oldLocation) {
+		// url or clone options changed after checkout, store is changed by checkout of updated material
+		return nil
+	}
+	storeLock := impl.locker.LeaseLocker(sharedLocation)
+	storeLock.Mutex.Lock()
+	err := impl.cloneSharedStore(ctx, material, sharedLocation)
+	if err == nil {
+		material.CheckoutLocation = sharedLocation
+		err = impl.materialRepo.Update(material)
+		if err != nil {
+			material.CheckoutLocation = oldLocation
+		}
+	}
+	storeLock.Mutex.Unlock()
+	impl.locker.ReturnLocker(sharedLocation)
 	if err != nil {
-		material.LastFetchErrorCount = material.LastFetchErrorCount + 1
-		material.FetchErrorMessage = err.Error()
-	} else {
-		material.LastFetchErrorCount = 0
-		material.FetchErrorMessage = ""
+		return err
 	}
-	err = impl.materialRepo.Update(material)
+	impl.logger.Infow("moved material to shared store", "id", material.Id, "oldLocation", oldLocation, "location", sharedLocation)
+
+	oldLock := impl.locker.LeaseLocker(oldLocation)
+	oldLock.Mutex.Lock()
+	defer func() {
+		oldLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(oldLocation)
+	}()
+	err = impl.repositoryManager.Clean(oldLocation)
 	if err != nil {
-		impl.logger.Errorw("error in updating fetch status", "material", material, "err", err)
+		impl.logger.Errorw("error in cleaning old location of material", "id", material.Id, "location", oldLocation, "err", err)
 	}
-	return material, err
+	return nil
 }
 
-func (impl GitWatcherImpl) pollGitMaterialAndNotify(material *sql.GitMaterial) error {
+func (impl GitWatcherImpl) cloneSharedStore(ctx context.Context, material *sql.GitMaterial, sharedLocation string) error {
+	if impl.repositoryManager.IsRepository(sharedLocation) {
+		return nil
+	}
 	gitProvider := material.GitProvider
 	userName, password, err := GetUserNamePassword(gitProvider)
-	location, err := GetLocationForMaterial(material)
 	if err != nil {
-		impl.logger.Errorw("error in determining location", "url", material.Url, "err", err)
 		return err
 	}
-	updated, repo, err := impl.repositoryManager.Fetch(userName, password, material.Url, location)
+	return impl.repositoryManager.Add(ctx, material.GitProviderId, sharedLocation, material.Url, userName, password, gitProvider.AuthMode, gitProvider.SshPrivateKey, GetCloneOptions(material))
+}
+
+// RestoreEvictedMaterial clones repository of material again if it was removed from disk to free space
+func (impl GitWatcherImpl) RestoreEvictedMaterial(ctx context.Context, material *sql.GitMaterial) error {
+	if !material.Evicted {
+		return nil
+	}
+	location := material.CheckoutLocation
+	repoLock := impl.locker.LeaseLocker(location)
+	repoLock.Mutex.Lock()
+	defer func() {
+		repoLock.Mutex.Unlock()
+		impl.locker.ReturnLocker(location)
+	}()
+	materials, err := impl.materialRepo.FindByCheckoutLocation(location)
+	if err != nil {
+		impl.logger.Errorw("error in fetching materials sharing location", "location", location, "err", err)
+		return err
+	}
+	return impl.restoreLocation(ctx, location, material, materials)
+}
+
+// restoreLocation clones evicted repository at location for all materials sharing it, caller must hold lock of location
+func (impl GitWatcherImpl) restoreLocation(ctx context.Context, location string, material *sql.GitMaterial, materials []*sql.GitMaterial) error {
+	if !impl.repositoryManager.IsRepository(location) {
+		impl.logger.Infow("restoring evicted repository", "id", material.Id, "location", location)
+		gitProvider := material.GitProvider
+		userName, password, err := GetUserNamePassword(gitProvider)
+		if err != nil {
+			return err
+		}
+		err = impl.repositoryManager.Add(ctx, material.GitProviderId, location, material.Url, userName, password, gitProvider.AuthMode, gitProvider.SshPrivateKey, MergeCloneOptions(materials))
+		if err != nil {
+			return err
+		}
+	}
+	err := impl.materialRepo.UpdateEvictedByLocation(location, false)
+	if err != nil {
+		impl.logger.Errorw("error in updating evicted status", "location", location, "err", err)
+		return err
+	}
+	material.Evicted = false
+	for _, sharingMaterial := range materials {
+		sharingMaterial.Evicted = false
+	}
+	return nil
+}
+
+// fetchGitMaterial fetches repository at location with credentials of material, for refs needed by all materials
+func (impl GitWatcherImpl) fetchGitMaterial(ctx context.Context, location string, material *sql.GitMaterial, materials []*sql.GitMaterial) (bool, *git.Repository, error) {
+	gitProvider := material.GitProvider
+	userName, password, err := GetUserNamePassword(gitProvider)
+	if err != nil {
+		impl.logger.Errorw("error in getting credentials", "url", material.Url, "err", err)
+		return false, nil, err
+	}
+	cloneOptions := MergeCloneOptions(materials)
+	updated, repo, err := impl.repositoryManager.Fetch(ctx, userName, password, material.Url, location, cloneOptions)
 	if err != nil {
 		impl.logger.Errorw("error in fetching material details ", "repo", material.Url, "err", err)
 		// there might be the case if ssh private key gets flush from disk, so creating and single retrying in this case
-		if gitProvider.AuthMode == sql.AUTH_MODE_SSH {
-			err = impl.repositoryManager.CreateSshFileIfNotExistsAndConfigureSshCommand(location, gitProvider.Id, gitProvider.SshPrivateKey)
+		if gitProvider.AuthMode == sql.AUTH_MODE_SSH && !IsGitTimeoutError(err) && ctx.Err() == nil {
+			err = impl.repositoryManager.CreateSshFileIfNotExistsAndConfigureSshCommand(ctx, location, gitProvider.Id, gitProvider.SshPrivateKey)
 			if err != nil {
 				impl.logger.Errorw("error in creating/configuring ssh private key on disk ", "repo", material.Url, "gitProviderId", gitProvider.Id, "err", err)
-				return err
-			}else{
-				impl.logger.Info("Retrying fetching for" , "repo",  material.Url)
-				updated, repo, err = impl.repositoryManager.Fetch(userName, password, material.Url, location)
+				return false, nil, err
+			} else {
+				impl.logger.Info("Retrying fetching for", "repo", material.Url)
+				updated, repo, err = impl.repositoryManager.Fetch(ctx, userName, password, material.Url, location, cloneOptions)
 				if err != nil {
 					impl.logger.Errorw("error in fetching material details in retry", "repo", material.Url, "err", err)
-					return err
+					return false, nil, err
 				}
 			}
-		}else{
-			return err
+		} else {
+			return false, nil, err
 		}
 	}
-	if !updated {
-		return nil
-	}
+	return updated, repo, nil
+}
+
+func (impl GitWatcherImpl) notifyForGitMaterialChanges(ctx context.Context, repo *git.Repository, location string, material *sql.GitMaterial) error {
+	gitProvider := material.GitProvider
 	materials, err := impl.ciPipelineMaterialRepository.FindByGitMaterialId(material.Id)
 	if err != nil {
 		impl.logger.Errorw("error in calculating head", "err", err, "url", material.Url)
@@ -257,41 +427,119 @@ func (impl GitWatcherImpl) pollGitMaterialAndNotify(material *sql.GitMaterial) e
 	var updatedMaterials []*CiPipelineMaterialBean
 	var updatedMaterialsModel []*sql.CiPipelineMaterial
 	var erroredMaterialsModels []*sql.CiPipelineMaterial
+	var tagMaterials []*sql.CiPipelineMaterial
+	var stateChanges []*CiPipelineMaterialStateChange
 	for _, material := range materials {
+		if material.Type == sql.SOURCE_TYPE_TAG_ANY {
+			tagMaterials = append(tagMaterials, material)
+			continue
+		}
+		if material.Type == sql.SOURCE_TYPE_BRANCH_REGEX {
+			branchMaterials, err := impl.pollBranchRegexMaterial(ctx, location, repo, material)
+			if err != nil {
+				material.Errored = true
+				material.ErrorMsg = err.Error()
+				erroredMaterialsModels = append(erroredMaterialsModels, material)
+			} else if len(branchMaterials) > 0 {
+				updatedMaterials = append(updatedMaterials, branchMaterials...)
+				updatedMaterialsModel = append(updatedMaterialsModel, material)
+				middleware.GitMaterialUpdateCounter.WithLabelValues().Inc()
+			}
+			continue
+		}
 		if material.Type != sql.SOURCE_TYPE_BRANCH_FIXED {
 			continue
 		}
-		commits, err := impl.repositoryManager.ChangesSinceByRepository(repo, material.Value, "", "", 15)
-		if err != nil {
+		// only commits since last seen head are walked, their stats are computed and they are added on top of stored history
+		commits, truncated, err := impl.repositoryManager.NewCommitsSinceByRepository(ctx, location, repo, material.Value, material.LastSeenHash, COMMIT_HISTORY_CACHE_SIZE)
+		if _, ok := err.(*BranchNotFoundError); ok {
+			if material.State != sql.MATERIAL_STATE_BRANCH_DELETED {
+				impl.logger.Infow("branch of material deleted", "materialId", material.Id, "branch", material.Value)
+				stateChanges = append(stateChanges, impl.buildStateChange(material, sql.MATERIAL_STATE_BRANCH_DELETED, "", nil))
+				material.State = sql.MATERIAL_STATE_BRANCH_DELETED
+				material.Errored = false
+				material.ErrorMsg = ""
+				updatedMaterialsModel = append(updatedMaterialsModel, material)
+			}
+		} else if err != nil {
 			material.Errored = true
 			material.ErrorMsg = err.Error()
 			erroredMaterialsModels = append(erroredMaterialsModels, material)
-		} else if len(commits) > 0 {
-			latestCommit := commits[0]
-			if latestCommit.Commit != material.LastSeenHash {
+		} else {
+			if len(commits) > 0 {
 				//new commit found
-				mb := &CiPipelineMaterialBean{
-					Id:            material.Id,
-					Value:         material.Value,
-					GitMaterialId: material.GitMaterialId,
-					Type:          material.Type,
-					Active:        material.Active,
-					GitCommit:     latestCommit,
+				latestCommit := commits[0]
+				impl.repositoryManager.VerifyCommitSignatures(location, commits, gitProvider)
+				var rewritten bool
+				var orphanedCommits []*GitCommit
+				if len(material.LastSeenHash) > 0 {
+					rewritten, orphanedCommits, err = impl.repositoryManager.FindOrphanedCommits(repo, material.LastSeenHash, latestCommit.Commit)
+					if err != nil {
+						// history is not saved and last seen hash is not moved so that rewrite is checked again in next poll
+						impl.logger.Errorw("error in checking history rewrite", "materialId", material.Id, "err", err)
+						continue
+					}
+				}
+				latestCommit.SkipReason, latestCommit.Warning = impl.getSkipReason(repo, material, commits, truncated)
+				history := commits
+				if rewritten {
+					history, err = impl.getRewrittenHistory(location, repo, material.GitMaterialId, material.Id, material.Value, commits)
+					if err != nil {
+						impl.logger.Errorw("error in getting rewritten history of material", "materialId", material.Id, "err", err)
+						continue
+					}
+				}
+				// history rewritten by force push does not keep orphaned commits, and truncated new commits are not stacked
+				// on stored history to not leave a gap in it, older commits are stored again once history is paged
+				err = impl.commitStoreService.SaveBranchCommits(material.GitMaterialId, material.Id, material.Value, history, rewritten || truncated)
+				if err != nil {
+					// last seen hash is not moved so that new commits are picked again in next poll
+					impl.logger.Errorw("error in saving history of material", "materialId", material.Id, "err", err)
+					continue
+				}
+				material.State = sql.MATERIAL_STATE_NORMAL
+				if rewritten {
+					impl.logger.Infow("history of material rewritten", "materialId", material.Id, "previousHead", material.LastSeenHash, "newHead", latestCommit.Commit, "orphanedCommits", len(orphanedCommits))
+					stateChanges = append(stateChanges, impl.buildStateChange(material, sql.MATERIAL_STATE_HISTORY_REWRITTEN, latestCommit.Commit, orphanedCommits))
+					material.State = sql.MATERIAL_STATE_HISTORY_REWRITTEN
+				}
+				if len(latestCommit.SkipReason) == 0 {
+					mb := &CiPipelineMaterialBean{
+						Id:            material.Id,
+						Value:         material.Value,
+						GitMaterialId: material.GitMaterialId,
+						Type:          material.Type,
+						Active:        material.Active,
+						GitCommit:     latestCommit,
+						NewCommits:    commits,
+					}
+					updatedMaterials = append(updatedMaterials, mb)
+				} else {
+					impl.logger.Infow("skipping notification for material", "materialId", material.Id, "commit", latestCommit.Commit, "reason", latestCommit.SkipReason)
 				}
-				updatedMaterials = append(updatedMaterials, mb)
 
 				material.LastSeenHash = latestCommit.Commit
-				material.CommitAuthor = latestCommit.Author
-				material.CommitDate = latestCommit.Date
-				commitJson, _ := json.Marshal(commits)
-				material.CommitHistory = string(commitJson)
 				material.Errored = false
 				material.ErrorMsg = ""
 				updatedMaterialsModel = append(updatedMaterialsModel, material)
+			} else if material.State == sql.MATERIAL_STATE_BRANCH_DELETED {
+				// branch pushed again at last seen commit
+				material.State = sql.MATERIAL_STATE_NORMAL
+				updatedMaterialsModel = append(updatedMaterialsModel, material)
 			}
 			middleware.GitMaterialUpdateCounter.WithLabelValues().Inc()
 		}
 	}
+	if len(tagMaterials) > 0 {
+		tagUpdatedMaterials, tagUpdatedMaterialsModel, tagErroredMaterialsModels, err := impl.pollTagMaterials(repo, material.Id, tagMaterials)
+		if err != nil {
+			impl.logger.Errorw("error in polling tags", "url", material.Url, "err", err)
+			return err
+		}
+		updatedMaterials = append(updatedMaterials, tagUpdatedMaterials...)
+		updatedMaterialsModel = append(updatedMaterialsModel, tagUpdatedMaterialsModel...)
+		erroredMaterialsModels = append(erroredMaterialsModels, tagErroredMaterialsModels...)
+	}
 	if len(updatedMaterialsModel) > 0 {
 		err = impl.NotifyForMaterialUpdate(updatedMaterials)
 		if err != nil {
@@ -303,16 +551,441 @@ func (impl GitWatcherImpl) pollGitMaterialAndNotify(material *sql.GitMaterial) e
 			impl.logger.Errorw("error in sending notification for materials", "url", material.Url, "update", updatedMaterialsModel)
 		}
 	}
+	if len(stateChanges) > 0 {
+		impl.NotifyForMaterialStateChange(stateChanges)
+	}
 	if len(erroredMaterialsModels) > 0 {
-		err = impl.ciPipelineMaterialRepository.Update(updatedMaterialsModel)
+		err = impl.ciPipelineMaterialRepository.Update(erroredMaterialsModels)
 		if err != nil {
-			impl.logger.Errorw("error in update db ", "url", material.Url, "update", updatedMaterialsModel)
-			impl.logger.Errorw("error in sending notification for materials", "url", material.Url, "update", updatedMaterialsModel)
+			impl.logger.Errorw("error in update db ", "url", material.Url, "update", erroredMaterialsModels)
 		}
 	}
 	return nil
 }
 
+func (impl GitWatcherImpl) buildStateChange(material *sql.CiPipelineMaterial, state sql.MaterialState, newHead string, orphanedCommits []*GitCommit) *CiPipelineMaterialStateChange {
+	return &CiPipelineMaterialStateChange{
+		Id:              material.Id,
+		GitMaterialId:   material.GitMaterialId,
+		Type:            material.Type,
+		Value:           material.Value,
+		State:           state,
+		PreviousHead:    material.LastSeenHash,
+		NewHead:         newHead,
+		OrphanedCommits: orphanedCommits,
+	}
+}
+
+func (impl GitWatcherImpl) SyncBranchRegexMaterial(ctx context.Context, checkoutLocation string, material *sql.CiPipelineMaterial) error {
+	repo, err := git.PlainOpen(checkoutLocation)
+	if err != nil {
+		impl.logger.Errorw("error in opening repository", "location", checkoutLocation, "err", err)
+		return err
+	}
+	// branches seen while saving the material are only recorded, notification is sent for the moves after that
+	_, err = impl.pollBranchRegexMaterial(ctx, checkoutLocation, repo, material)
+	return err
+}
+
+// pollBranchRegexMaterial compares head of every remote branch matching material regex with the last seen head of
+// that branch and returns one notification per branch which has moved (or newly appeared)
+func (impl GitWatcherImpl) pollBranchRegexMaterial(ctx context.Context, location string, repo *git.Repository, material *sql.CiPipelineMaterial) ([]*CiPipelineMaterialBean, error) {
+	branchRegex, err := regexp.Compile(material.Value)
+	if err != nil {
+		impl.logger.Errorw("invalid branch regex", "materialId", material.Id, "regex", material.Value, "err", err)
+		return nil, err
+	}
+	branchHeads, err := impl.repositoryManager.GetRemoteBranchHeads(repo)
+	if err != nil {
+		impl.logger.Errorw("error in getting branches", "materialId", material.Id, "err", err)
+		return nil, err
+	}
+	knownBranches, err := impl.ciPipelineMaterialBranchRepository.FindActiveByCiPipelineMaterialId(material.Id)
+	if err != nil {
+		impl.logger.Errorw("error in getting branches of material", "materialId", material.Id, "err", err)
+		return nil, err
+	}
+	knownBranchMap := make(map[string]*sql.CiPipelineMaterialBranch)
+	for _, knownBranch := range knownBranches {
+		knownBranchMap[knownBranch.BranchName] = knownBranch
+	}
+
+	var updatedMaterials []*CiPipelineMaterialBean
+	var newBranches, updatedBranches []*sql.CiPipelineMaterialBranch
+	matchedBranches := make(map[string]bool)
+	for branch, head := range branchHeads {
+		if !branchRegex.MatchString(branch) {
+			continue
+		}
+		matchedBranches[branch] = true
+		knownBranch, ok := knownBranchMap[branch]
+		if ok && knownBranch.LastSeenHash == head {
+			continue
+		}
+		var lastSeenHash string
+		if ok {
+			lastSeenHash = knownBranch.LastSeenHash
+		}
+		commits, truncated, err := impl.repositoryManager.NewCommitsSinceByRepository(ctx, location, repo, branch, lastSeenHash, COMMIT_HISTORY_CACHE_SIZE)
+		if err != nil || len(commits) == 0 {
+			impl.logger.Errorw("error in getting commits of branch", "materialId", material.Id, "branch", branch, "err", err)
+			continue
+		}
+		latestCommit := commits[0]
+		// history of branch is replaced when last seen head is not in it anymore e.g. after force push, or when new commits
+		// were truncated
+		var rewritten bool
+		if len(lastSeenHash) > 0 {
+			rewritten, _, err = impl.repositoryManager.FindOrphanedCommits(repo, lastSeenHash, latestCommit.Commit)
+			if err != nil {
+				impl.logger.Errorw("error in checking history rewrite of branch", "materialId", material.Id, "branch", branch, "err", err)
+				continue
+			}
+		}
+		history := commits
+		if rewritten {
+			history, err = impl.getRewrittenHistory(location, repo, material.GitMaterialId, material.Id, branch, commits)
+			if err != nil {
+				impl.logger.Errorw("error in getting rewritten history of branch", "materialId", material.Id, "branch", branch, "err", err)
+				continue
+			}
+		}
+		err = impl.commitStoreService.SaveBranchCommits(material.GitMaterialId, material.Id, branch, history, rewritten || truncated)
+		if err != nil {
+			impl.logger.Errorw("error in saving history of branch", "materialId", material.Id, "branch", branch, "err", err)
+			continue
+		}
+		if !ok {
+			knownBranch = &sql.CiPipelineMaterialBranch{
+				CiPipelineMaterialId: material.Id,
+				BranchName:           branch,
+				Active:               true,
+				CreatedOn:            time.Now(),
+			}
+			newBranches = append(newBranches, knownBranch)
+		} else {
+			updatedBranches = append(updatedBranches, knownBranch)
+		}
+		knownBranch.LastSeenHash = latestCommit.Commit
+		knownBranch.UpdatedOn = time.Now()
+		knownBranchMap[branch] = knownBranch
+
+		updatedMaterials = append(updatedMaterials, &CiPipelineMaterialBean{
+			Id:            material.Id,
+			Value:         material.Value,
+			GitMaterialId: material.GitMaterialId,
+			Type:          material.Type,
+			Active:        material.Active,
+			GitCommit:     latestCommit,
+			NewCommits:    commits,
+			Branch:        branch,
+		})
+	}
+	// branches deleted from remote or not matching regex anymore
+	for branch, knownBranch := range knownBranchMap {
+		if !matchedBranches[branch] {
+			knownBranch.Active = false
+			knownBranch.UpdatedOn = time.Now()
+			updatedBranches = append(updatedBranches, knownBranch)
+			delete(knownBranchMap, branch)
+		}
+	}
+	if len(newBranches) > 0 {
+		err = impl.ciPipelineMaterialBranchRepository.Save(newBranches)
+		if err != nil {
+			impl.logger.Errorw("error in saving branches of material", "materialId", material.Id, "err", err)
+			return nil, err
+		}
+	}
+	if len(updatedBranches) > 0 {
+		err = impl.ciPipelineMaterialBranchRepository.Update(updatedBranches)
+		if err != nil {
+			impl.logger.Errorw("error in updating branches of material", "materialId", material.Id, "err", err)
+			return nil, err
+		}
+	}
+
+	// material head points to the most recent commit among all matched branches
+	heads := []string{material.LastSeenHash}
+	for _, knownBranch := range knownBranchMap {
+		heads = append(heads, knownBranch.LastSeenHash)
+	}
+	headCommits, err := impl.commitStoreService.GetCommits(material.GitMaterialId, heads)
+	if err != nil {
+		impl.logger.Errorw("error in getting heads of branches of material", "materialId", material.Id, "err", err)
+		return nil, err
+	}
+	var headDate time.Time
+	if headCommit, ok := headCommits[material.LastSeenHash]; ok {
+		headDate = headCommit.Date
+	}
+	for _, knownBranch := range knownBranchMap {
+		headCommit, ok := headCommits[knownBranch.LastSeenHash]
+		if !ok {
+			continue
+		}
+		if headCommit.Date.After(headDate) || len(material.LastSeenHash) == 0 {
+			material.LastSeenHash = headCommit.Commit
+			headDate = headCommit.Date
+		}
+	}
+	material.Errored = false
+	material.ErrorMsg = ""
+	return updatedMaterials, nil
+}
+
+// getSkipReason evaluates filters of material against commits new since last seen head, newest first, blank reason
+// means notify. Signed-only policy skips when any new commit is not validly signed, suppression rules skip only when
+// every new commit is suppressed, so that a suppressed head does not hide commits below it. Commits are only evaluated
+// up to cache size, truncated new commits are never suppressed. Warning is set when a filter could not be evaluated
+// and notification went ahead without it
+func (impl GitWatcherImpl) getSkipReason(repo *git.Repository, material *sql.CiPipelineMaterial, commits []*GitCommit, truncated bool) (skipReason string, warning string) {
+	latestCommit := commits[0]
+	newCommits := commits
+	if len(material.LastSeenHash) == 0 {
+		// first head of material, history below it was never notified
+		newCommits = commits[:1]
+	}
+	if material.SuppressionRules != nil && material.SuppressionRules.RequireSignedCommits {
+		for _, commit := range newCommits {
+			if commit.Signature == nil || commit.Signature.Status != SIGNATURE_STATUS_VALID {
+				status := SIGNATURE_STATUS_UNSIGNED
+				if commit.Signature != nil {
+					status = commit.Signature.Status
+				}
+				if commit == latestCommit {
+					return fmt.Sprintf("commit signature is not valid, status %s", status), ""
+				}
+				return fmt.Sprintf("signature of new commit %s is not valid, status %s", commit.Commit, status), ""
+			}
+		}
+	}
+	suppressionRuleMatcher, err := NewSuppressionRuleMatcher(material.SuppressionRules)
+	if err != nil {
+		impl.logger.Errorw("invalid suppression rules, notifying without them", "materialId", material.Id, "err", err)
+	} else if suppressionRuleMatcher != nil && !truncated {
+		var headRule string
+		for _, commit := range newCommits {
+			isMerge := false
+			commitObject, err := repo.CommitObject(plumbing.NewHash(commit.Commit))
+			if err == nil {
+				isMerge = commitObject.NumParents() > 1
+			}
+			rule := suppressionRuleMatcher.MatchedRule(commit.Message, commit.Author, isMerge)
+			if len(rule) == 0 {
+				headRule = ""
+				break
+			}
+			if commit == latestCommit {
+				headRule = rule
+			}
+		}
+		if len(headRule) > 0 {
+			return headRule, ""
+		}
+	}
+	pathMatcher, err := NewPathMatcher(material.PathFilter)
+	if err != nil {
+		impl.logger.Errorw("invalid path filter, notifying without it", "materialId", material.Id, "err", err)
+		return "", fmt.Sprintf("path filter not applied, %s", err.Error())
+	}
+	if pathMatcher == nil || len(material.LastSeenHash) == 0 {
+		return "", ""
+	}
+	changedFiles, err := impl.repositoryManager.ChangedFilesBetween(repo, material.LastSeenHash, latestCommit.Commit)
+	if err != nil {
+		// last seen commit might not exist anymore after force push
+		impl.logger.Warnw("could not get changed files, notifying without path filter", "materialId", material.Id, "err", err)
+		return "", fmt.Sprintf("path filter not applied, changed files since %s could not be found", material.LastSeenHash)
+	}
+	if !pathMatcher.MatchesAny(changedFiles) {
+		return SKIP_REASON_PATH_FILTER, ""
+	}
+	return "", ""
+}
+
+// getRewrittenHistory returns latest commits of branch which replace its stored history after history rewrite, new
+// commits are not on top of stored history then. Already walked new commits are reused and skip reasons of stored
+// commits are kept
+func (impl GitWatcherImpl) getRewrittenHistory(location string, repo *git.Repository, gitMaterialId int, ciPipelineMaterialId int, branch string, commits []*GitCommit) ([]*GitCommit, error) {
+	history, err := impl.repositoryManager.ChangesSinceByRepository(location, repo, branch, "", "", COMMIT_HISTORY_CACHE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	storedCommits, err := impl.commitStoreService.GetBranchCommits(gitMaterialId, location, ciPipelineMaterialId, branch, COMMIT_HISTORY_CACHE_SIZE)
+	if err != nil {
+		impl.logger.Errorw("error in getting stored history of branch", "ciPipelineMaterialId", ciPipelineMaterialId, "branch", branch, "err", err)
+	}
+	impl.copySkipReasons(storedCommits, history)
+	newCommits := make(map[string]*GitCommit)
+	for _, commit := range commits {
+		newCommits[commit.Commit] = commit
+	}
+	for i, commit := range history {
+		if newCommit, ok := newCommits[commit.Commit]; ok {
+			history[i] = newCommit
+		}
+	}
+	return history, nil
+}
+
+// copySkipReasons keeps skip reason and warning of commits already present in previous commit history of material
+func (impl GitWatcherImpl) copySkipReasons(oldCommits []*GitCommit, commits []*GitCommit) {
+	oldCommitMap := make(map[string]*GitCommit)
+	for _, oldCommit := range oldCommits {
+		if len(oldCommit.SkipReason) > 0 || len(oldCommit.Warning) > 0 {
+			oldCommitMap[oldCommit.Commit] = oldCommit
+		}
+	}
+	for _, commit := range commits {
+		if oldCommit, ok := oldCommitMap[commit.Commit]; ok {
+			commit.SkipReason = oldCommit.SkipReason
+			commit.Warning = oldCommit.Warning
+		}
+	}
+}
+
+func (impl GitWatcherImpl) SyncTagMaterial(checkoutLocation string, material *sql.CiPipelineMaterial) error {
+	_, err := NewTagFilter(material.Value)
+	if err != nil {
+		impl.logger.Errorw("invalid tag material value", "materialId", material.Id, "value", material.Value, "err", err)
+		return err
+	}
+	// known tags are shared by all tag materials of git material, they are recorded only once
+	exists, err := impl.gitMaterialTagRepository.Exists(material.GitMaterialId)
+	if err != nil || exists {
+		return err
+	}
+	repo, err := git.PlainOpen(checkoutLocation)
+	if err != nil {
+		impl.logger.Errorw("error in opening repository", "location", checkoutLocation, "err", err)
+		return err
+	}
+	_, err = impl.recordNewTags(repo, material.GitMaterialId)
+	return err
+}
+
+// pollTagMaterials detects tags created since last poll and notifies every SOURCE_TYPE_TAG_ANY material whose
+// filter matches the new tag
+func (impl GitWatcherImpl) pollTagMaterials(repo *git.Repository, gitMaterialId int, materials []*sql.CiPipelineMaterial) (updatedMaterials []*CiPipelineMaterialBean, updatedMaterialsModel []*sql.CiPipelineMaterial, erroredMaterialsModels []*sql.CiPipelineMaterial, err error) {
+	newTagCommits, err := impl.recordNewTags(repo, gitMaterialId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, material := range materials {
+		tagFilter, err := NewTagFilter(material.Value)
+		if err != nil {
+			material.Errored = true
+			material.ErrorMsg = err.Error()
+			erroredMaterialsModels = append(erroredMaterialsModels, material)
+			continue
+		}
+		updated := false
+		var headDate time.Time
+		for _, tagCommit := range newTagCommits {
+			if !tagFilter.Matches(tagCommit.Tag) {
+				continue
+			}
+			updatedMaterials = append(updatedMaterials, &CiPipelineMaterialBean{
+				Id:            material.Id,
+				Value:         material.Value,
+				GitMaterialId: material.GitMaterialId,
+				Type:          material.Type,
+				Active:        material.Active,
+				GitCommit:     tagCommit,
+			})
+			if !updated || tagCommit.Date.After(headDate) {
+				material.LastSeenHash = tagCommit.Commit
+				headDate = tagCommit.Date
+			}
+			updated = true
+		}
+		if updated {
+			material.Errored = false
+			material.ErrorMsg = ""
+			updatedMaterialsModel = append(updatedMaterialsModel, material)
+			middleware.GitMaterialUpdateCounter.WithLabelValues().Inc()
+		}
+	}
+	return updatedMaterials, updatedMaterialsModel, erroredMaterialsModels, nil
+}
+
+// recordNewTags syncs known tags of git material with the repository and returns commits of newly found tags, nothing
+// is returned when no tags were known yet. Only headers of tag commits are read, as the first sync finds every tag of
+// repository, their stats are filled on read
+func (impl GitWatcherImpl) recordNewTags(repo *git.Repository, gitMaterialId int) ([]*GitCommit, error) {
+	tagHeads, err := impl.repositoryManager.GetTagHeads(repo)
+	if err != nil {
+		return nil, err
+	}
+	knownTags, err := impl.gitMaterialTagRepository.FindByGitMaterialId(gitMaterialId)
+	if err != nil {
+		impl.logger.Errorw("error in getting known tags", "gitMaterialId", gitMaterialId, "err", err)
+		return nil, err
+	}
+	knownTagMap := make(map[string]*sql.GitMaterialTag)
+	for _, knownTag := range knownTags {
+		knownTagMap[knownTag.TagName] = knownTag
+	}
+
+	var newTags []*sql.GitMaterialTag
+	var newTagCommits []*GitCommit
+	for tag, commitHash := range tagHeads {
+		knownTag, ok := knownTagMap[tag]
+		delete(knownTagMap, tag)
+		if ok && knownTag.CommitHash == commitHash {
+			continue
+		}
+		commit, err := impl.repositoryManager.GetCommitHeader(repo, commitHash)
+		if err != nil {
+			impl.logger.Errorw("error in getting tag commit", "gitMaterialId", gitMaterialId, "tag", tag, "err", err)
+			continue
+		}
+		commit.Tag = tag
+		newTagCommits = append(newTagCommits, commit)
+		newTags = append(newTags, &sql.GitMaterialTag{
+			GitMaterialId: gitMaterialId,
+			TagName:       tag,
+			CommitHash:    commit.Commit,
+			CommitAuthor:  commit.Author,
+			CommitDate:    commit.Date,
+			CommitMessage: commit.Message,
+			CreatedOn:     time.Now(),
+		})
+	}
+	// tags deleted from remote or force-moved to other commit are replaced
+	var removedTagIds []int
+	for _, knownTag := range knownTags {
+		if _, ok := tagHeads[knownTag.TagName]; !ok || knownTag.CommitHash != tagHeads[knownTag.TagName] {
+			removedTagIds = append(removedTagIds, knownTag.Id)
+		}
+	}
+	if len(removedTagIds) > 0 {
+		err = impl.gitMaterialTagRepository.DeleteByIds(removedTagIds)
+		if err != nil {
+			impl.logger.Errorw("error in deleting removed tags", "gitMaterialId", gitMaterialId, "err", err)
+			return nil, err
+		}
+	}
+	if len(newTags) > 0 {
+		// headers of tag commits are kept with tags, commits are not saved to commit store without their submodule changes
+		err = impl.gitMaterialTagRepository.Save(newTags)
+		if err != nil {
+			impl.logger.Errorw("error in saving new tags", "gitMaterialId", gitMaterialId, "err", err)
+			return nil, err
+		}
+	}
+	if len(knownTags) == 0 {
+		// without stored tags every existing tag of repository is new, they are seeded silently and only tags found
+		// in later polls are notified
+		return nil, nil
+	}
+	sort.Slice(newTagCommits, func(i, j int) bool {
+		return newTagCommits[i].Date.Before(newTagCommits[j].Date)
+	})
+	return newTagCommits, nil
+}
+
 func (impl GitWatcherImpl) NotifyForMaterialUpdate(materials []*CiPipelineMaterialBean) error {
 
 	impl.logger.Warnw("material notification", "materials", materials)
@@ -339,6 +1012,27 @@ func (impl GitWatcherImpl) NotifyForMaterialUpdate(materials []*CiPipelineMateri
 	return nil
 }
 
+func (impl GitWatcherImpl) NotifyForMaterialStateChange(stateChanges []*CiPipelineMaterialStateChange) {
+	impl.logger.Warnw("material state change notification", "stateChanges", stateChanges)
+	for _, stateChange := range stateChanges {
+		mb, err := json.Marshal(stateChange)
+		if err != nil {
+			impl.logger.Error("err in json marshaling", "err", err)
+			continue
+		}
+		err = internal.AddStream(impl.pubSubClient.JetStrCtxt, internal.GIT_SENSOR_STREAM)
+		if err != nil {
+			impl.logger.Errorw("Error while adding stream", "error", err)
+		}
+		//Generate random string for passing as Header Id in message
+		randString := "MsgHeaderId-" + util.Generate(10)
+		_, err = impl.pubSubClient.JetStrCtxt.Publish(internal.CI_MATERIAL_STATE_CHANGE_TOPIC, mb, nats.MsgId(randString))
+		if err != nil {
+			impl.logger.Errorw("error in publishing material state change msg ", "stateChange", stateChange)
+		}
+	}
+}
+
 func (impl GitWatcherImpl) SubscribeWebhookEvent() error {
 	_, err := impl.pubSubClient.JetStrCtxt.QueueSubscribe(internal.WEBHOOK_EVENT_TOPIC, internal.WEBHOOK_EVENT_TOPIC_GRP, func(msg *nats.Msg) {
 		impl.logger.Debugw("received msg", "msg", msg)
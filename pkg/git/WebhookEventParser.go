@@ -49,6 +49,8 @@ const (
 	WEBHOOK_SELECTOR_SOURCE_CHECKOUT_NAME    string = "source checkout"
 	WEBHOOK_SELECTOR_TARGET_BRANCH_NAME_NAME string = "target branch name"
 	WEBHOOK_SELECTOR_SOURCE_BRANCH_NAME_NAME string = "source branch name"
+
+	WEBHOOK_EVENT_MERGED_ACTION_TYPE string = "merged"
 )
 
 func (impl WebhookEventParserImpl) ParseEvent(selectors []*sql.GitHostWebhookEventSelectors, requestPayloadJson string) (*sql.WebhookEventParsedData, map[string]string, error) {
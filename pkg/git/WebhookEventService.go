@@ -178,6 +178,10 @@ func (impl WebhookEventServiceImpl) MatchCiTriggerConditionAndNotify(event *sql.
 				impl.logger.Errorw("error in updating material with last fetch time", "material", material, "err", err)
 			}
 
+			if overallMatch {
+				overallMatch = !impl.isSuppressed(ciPipelineMaterial, webhookEventParsedData, fullDataMap)
+			}
+
 			// if condition is match, then notify for CI
 			if overallMatch {
 				impl.NotifyForAutoCi(impl.BuildNotifyCiObject(ciPipelineMaterial, webhookEventParsedData))
@@ -188,6 +192,25 @@ func (impl WebhookEventServiceImpl) MatchCiTriggerConditionAndNotify(event *sql.
 	return nil
 }
 
+// isSuppressed matches suppression rules of material against title and author of webhook event, merged events are treated as merge commits
+func (impl WebhookEventServiceImpl) isSuppressed(ciPipelineMaterial *sql.CiPipelineMaterial, webhookEventParsedData *sql.WebhookEventParsedData, fullDataMap map[string]string) bool {
+	suppressionRuleMatcher, err := NewSuppressionRuleMatcher(ciPipelineMaterial.SuppressionRules)
+	if err != nil {
+		impl.logger.Errorw("invalid suppression rules, notifying without them", "ciPipelineMaterialId", ciPipelineMaterial.Id, "err", err)
+		return false
+	}
+	if suppressionRuleMatcher == nil {
+		return false
+	}
+	isMerge := webhookEventParsedData.EventActionType == WEBHOOK_EVENT_MERGED_ACTION_TYPE
+	rule := suppressionRuleMatcher.MatchedRule(fullDataMap[WEBHOOK_SELECTOR_TITLE_NAME], fullDataMap[WEBHOOK_SELECTOR_AUTHOR_NAME], isMerge)
+	if len(rule) == 0 {
+		return false
+	}
+	impl.logger.Infow("suppressing notification for webhook event", "ciPipelineMaterialId", ciPipelineMaterial.Id, "webhookParsedDataId", webhookEventParsedData.Id, "rule", rule)
+	return true
+}
+
 func (impl WebhookEventServiceImpl) MatchFilter(event *sql.GitHostWebhookEvent, fullDataMap map[string]string, ciPipelineMaterialJsonValue string) ([]*sql.CiPipelineMaterialWebhookDataMappingFilterResult, bool, error) {
 	webhookSourceTypeValue := WebhookSourceTypeValue{}
 	err := json.Unmarshal([]byte(ciPipelineMaterialJsonValue), &webhookSourceTypeValue)
@@ -0,0 +1,3 @@
+---- ALTER TABLE ci_pipeline_material - drop column
+ALTER TABLE ci_pipeline_material
+DROP COLUMN IF EXISTS suppression_rules;
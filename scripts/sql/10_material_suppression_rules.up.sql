@@ -0,0 +1,3 @@
+---- ALTER TABLE ci_pipeline_material - add column
+ALTER TABLE ci_pipeline_material
+ADD COLUMN suppression_rules jsonb;
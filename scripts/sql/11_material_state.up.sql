@@ -0,0 +1,3 @@
+---- ALTER TABLE ci_pipeline_material - add column
+ALTER TABLE ci_pipeline_material
+ADD COLUMN state varchar(50) NOT NULL DEFAULT 'NORMAL';
@@ -0,0 +1,7 @@
+---- ALTER TABLE git_provider - drop column
+ALTER TABLE git_provider
+DROP COLUMN IF EXISTS trusted_gpg_keys;
+
+---- ALTER TABLE git_provider - drop column
+ALTER TABLE git_provider
+DROP COLUMN IF EXISTS trusted_ssh_keys;
@@ -0,0 +1,7 @@
+---- ALTER TABLE git_provider - add column
+ALTER TABLE git_provider
+ADD COLUMN trusted_gpg_keys text;
+
+---- ALTER TABLE git_provider - add column
+ALTER TABLE git_provider
+ADD COLUMN trusted_ssh_keys text;
@@ -0,0 +1,7 @@
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS clone_strategy;
+
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS clone_depth;
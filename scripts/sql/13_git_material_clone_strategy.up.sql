@@ -0,0 +1,7 @@
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN clone_strategy varchar(50) NOT NULL DEFAULT 'FULL';
+
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN clone_depth integer;
@@ -0,0 +1,3 @@
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS fetch_ref_specs;
@@ -0,0 +1,3 @@
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN fetch_ref_specs text[];
@@ -0,0 +1,11 @@
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS last_maintenance_time;
+
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS last_maintenance_duration_ms;
+
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS maintenance_error_message;
@@ -0,0 +1,11 @@
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN last_maintenance_time timestamptz;
+
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN last_maintenance_duration_ms bigint;
+
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN maintenance_error_message text;
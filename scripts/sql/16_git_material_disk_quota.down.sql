@@ -0,0 +1,11 @@
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS disk_usage_bytes;
+
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS last_used_time;
+
+---- ALTER TABLE git_material - drop column
+ALTER TABLE git_material
+DROP COLUMN IF EXISTS evicted;
@@ -0,0 +1,11 @@
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN disk_usage_bytes bigint;
+
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN last_used_time timestamptz DEFAULT now();
+
+---- ALTER TABLE git_material - add column
+ALTER TABLE git_material
+ADD COLUMN evicted bool NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+---- drop table git_commit_stats
+DROP TABLE IF EXISTS public.git_commit_stats;
+
+---- DROP sequence
+DROP SEQUENCE IF EXISTS public.git_commit_stats_id_seq;
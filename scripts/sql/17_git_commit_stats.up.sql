@@ -0,0 +1,29 @@
+--
+-- Name: git_commit_stats_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE IF NOT EXISTS public.git_commit_stats_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE CACHE 1;
+
+
+--
+-- Name: git_commit_stats; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS public.git_commit_stats
+(
+    id          INTEGER                NOT NULL DEFAULT nextval('git_commit_stats_id_seq'::regclass),
+    commit_hash character varying(40)  NOT NULL,
+    file_stats  text,
+    partial     boolean                NOT NULL DEFAULT false,
+    created_on  timestamptz            NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+
+--- Create unique index on git_commit_stats.commit_hash
+CREATE UNIQUE INDEX IF NOT EXISTS git_commit_stats_UX1 ON public.git_commit_stats (commit_hash);
@@ -0,0 +1,78 @@
+---- ALTER TABLE ci_pipeline_material - add columns
+ALTER TABLE ci_pipeline_material
+ADD COLUMN IF NOT EXISTS commit_author varchar(250);
+
+ALTER TABLE ci_pipeline_material
+ADD COLUMN IF NOT EXISTS commit_date timestamptz;
+
+ALTER TABLE ci_pipeline_material
+ADD COLUMN IF NOT EXISTS commit_history text;
+
+---- ALTER TABLE ci_pipeline_material_branch - add columns
+ALTER TABLE ci_pipeline_material_branch
+ADD COLUMN IF NOT EXISTS commit_author character varying(250);
+
+ALTER TABLE ci_pipeline_material_branch
+ADD COLUMN IF NOT EXISTS commit_date timestamptz;
+
+ALTER TABLE ci_pipeline_material_branch
+ADD COLUMN IF NOT EXISTS commit_history text;
+
+
+---- Restore heads of materials and of their branches
+UPDATE ci_pipeline_material cpm
+SET commit_author = gc.author,
+    commit_date   = gc.commit_date
+FROM git_commit gc
+WHERE gc.git_material_id = cpm.git_material_id
+  AND gc.commit_hash = cpm.last_seen_hash;
+
+UPDATE ci_pipeline_material_branch cpmb
+SET commit_author = gc.author,
+    commit_date   = gc.commit_date
+FROM ci_pipeline_material cpm,
+     git_commit gc
+WHERE cpm.id = cpmb.ci_pipeline_material_id
+  AND gc.git_material_id = cpm.git_material_id
+  AND gc.commit_hash = cpmb.last_seen_hash;
+
+
+---- Restore commit history of materials and of their branches, newest first
+UPDATE ci_pipeline_material cpm
+SET commit_history = history.commits
+FROM (SELECT gbc.ci_pipeline_material_id,
+             json_agg(json_build_object('Commit', gc.commit_hash, 'Author', gc.author, 'Date', gc.commit_date,
+                                        'Message', gc.message, 'SkipReason', gbc.skip_reason)
+                      ORDER BY gbc.commit_order DESC)::text AS commits
+      FROM git_branch_commit gbc
+               INNER JOIN ci_pipeline_material m ON m.id = gbc.ci_pipeline_material_id AND m.value = gbc.branch_name
+               INNER JOIN git_commit gc ON gc.git_material_id = m.git_material_id AND gc.commit_hash = gbc.commit_hash
+      GROUP BY gbc.ci_pipeline_material_id) history
+WHERE history.ci_pipeline_material_id = cpm.id;
+
+UPDATE ci_pipeline_material_branch cpmb
+SET commit_history = history.commits
+FROM (SELECT gbc.ci_pipeline_material_id,
+             gbc.branch_name,
+             json_agg(json_build_object('Commit', gc.commit_hash, 'Author', gc.author, 'Date', gc.commit_date,
+                                        'Message', gc.message, 'SkipReason', gbc.skip_reason)
+                      ORDER BY gbc.commit_order DESC)::text AS commits
+      FROM git_branch_commit gbc
+               INNER JOIN ci_pipeline_material m ON m.id = gbc.ci_pipeline_material_id
+               INNER JOIN git_commit gc ON gc.git_material_id = m.git_material_id AND gc.commit_hash = gbc.commit_hash
+      GROUP BY gbc.ci_pipeline_material_id, gbc.branch_name) history
+WHERE history.ci_pipeline_material_id = cpmb.ci_pipeline_material_id
+  AND history.branch_name = cpmb.branch_name;
+
+
+---- drop table git_branch_commit
+DROP TABLE IF EXISTS public.git_branch_commit;
+
+---- DROP sequence
+DROP SEQUENCE IF EXISTS public.git_branch_commit_id_seq;
+
+---- drop table git_commit
+DROP TABLE IF EXISTS public.git_commit;
+
+---- DROP sequence
+DROP SEQUENCE IF EXISTS public.git_commit_id_seq;
@@ -0,0 +1,158 @@
+--
+-- Name: git_commit_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE IF NOT EXISTS public.git_commit_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE CACHE 1;
+
+
+--
+-- Name: git_commit; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS public.git_commit
+(
+    id                INTEGER               NOT NULL DEFAULT nextval('git_commit_id_seq'::regclass),
+    git_material_id   INTEGER               NOT NULL,
+    commit_hash       character varying(40) NOT NULL,
+    author            text,
+    commit_date       timestamptz,
+    message           text,
+    submodule_changes text,
+    created_on        timestamptz           NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+
+---- Add Foreign key constraint on git_material_id in Table git_commit
+ALTER TABLE git_commit
+    ADD CONSTRAINT git_commit_git_material_id_fkey FOREIGN KEY (git_material_id) REFERENCES public.git_material (id);
+
+
+--- Create unique index on git_commit.git_material_id, commit_hash
+CREATE UNIQUE INDEX IF NOT EXISTS git_commit_UX1 ON public.git_commit (git_material_id, commit_hash);
+
+
+--
+-- Name: git_branch_commit_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE IF NOT EXISTS public.git_branch_commit_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE CACHE 1;
+
+
+--
+-- Name: git_branch_commit; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS public.git_branch_commit
+(
+    id                      INTEGER                NOT NULL DEFAULT nextval('git_branch_commit_id_seq'::regclass),
+    ci_pipeline_material_id INTEGER                NOT NULL,
+    branch_name             character varying(250) NOT NULL,
+    commit_hash             character varying(40)  NOT NULL,
+    commit_order            INTEGER                NOT NULL,
+    skip_reason             text,
+    warning                 text,
+    created_on              timestamptz            NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+
+---- Add Foreign key constraint on ci_pipeline_material_id in Table git_branch_commit
+ALTER TABLE git_branch_commit
+    ADD CONSTRAINT git_branch_commit_ci_pipeline_material_id_fkey FOREIGN KEY (ci_pipeline_material_id) REFERENCES public.ci_pipeline_material (id);
+
+
+--- Create unique index on git_branch_commit.ci_pipeline_material_id, branch_name, commit_hash
+CREATE UNIQUE INDEX IF NOT EXISTS git_branch_commit_UX1 ON public.git_branch_commit (ci_pipeline_material_id, branch_name, commit_hash);
+
+
+--- Create index on git_branch_commit.ci_pipeline_material_id, branch_name, commit_order
+CREATE INDEX IF NOT EXISTS git_branch_commit_IX1 ON public.git_branch_commit (ci_pipeline_material_id, branch_name, commit_order);
+
+
+---- Copy commits of commit history of materials and of their branches
+INSERT INTO public.git_commit (git_material_id, commit_hash, author, commit_date, message, submodule_changes, created_on)
+SELECT DISTINCT ON (history.git_material_id, history.entry ->> 'Commit') history.git_material_id,
+                                                                          history.entry ->> 'Commit',
+                                                                          history.entry ->> 'Author',
+                                                                          (history.entry ->> 'Date')::timestamptz,
+                                                                          history.entry ->> 'Message',
+                                                                          (history.entry -> 'SubmoduleChanges')::text,
+                                                                          now()
+FROM (SELECT cpm.git_material_id, c.entry
+      FROM public.ci_pipeline_material cpm,
+           json_array_elements(CASE WHEN cpm.commit_history LIKE '[%' THEN cpm.commit_history::json ELSE '[]'::json END) AS c(entry)
+      WHERE cpm.git_material_id IS NOT NULL
+      UNION ALL
+      SELECT cpm.git_material_id, c.entry
+      FROM public.ci_pipeline_material_branch cpmb
+               INNER JOIN public.ci_pipeline_material cpm ON cpm.id = cpmb.ci_pipeline_material_id,
+           json_array_elements(CASE WHEN cpmb.commit_history LIKE '[%' THEN cpmb.commit_history::json ELSE '[]'::json END) AS c(entry)
+      WHERE cpm.git_material_id IS NOT NULL) history
+ON CONFLICT DO NOTHING;
+
+
+---- Copy heads of materials not present in commit history i.e. heads of tag materials
+INSERT INTO public.git_commit (git_material_id, commit_hash, author, commit_date, created_on)
+SELECT DISTINCT ON (git_material_id, last_seen_hash) git_material_id, last_seen_hash, commit_author, commit_date, now()
+FROM public.ci_pipeline_material
+WHERE git_material_id IS NOT NULL
+  AND last_seen_hash <> ''
+ON CONFLICT DO NOTHING;
+
+
+---- Copy commit history of branch materials, head has the highest order
+INSERT INTO public.git_branch_commit (ci_pipeline_material_id, branch_name, commit_hash, commit_order, skip_reason, created_on)
+SELECT DISTINCT ON (cpm.id, c.entry ->> 'Commit') cpm.id,
+                                                   cpm.value,
+                                                   c.entry ->> 'Commit',
+                                                   json_array_length(cpm.commit_history::json) - c.idx + 1,
+                                                   c.entry ->> 'SkipReason',
+                                                   now()
+FROM public.ci_pipeline_material cpm,
+     json_array_elements(CASE WHEN cpm.commit_history LIKE '[%' THEN cpm.commit_history::json ELSE '[]'::json END) WITH ORDINALITY AS c(entry, idx)
+WHERE cpm.type = 'SOURCE_TYPE_BRANCH_FIXED'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO public.git_branch_commit (ci_pipeline_material_id, branch_name, commit_hash, commit_order, skip_reason, created_on)
+SELECT DISTINCT ON (cpmb.ci_pipeline_material_id, cpmb.branch_name, c.entry ->> 'Commit') cpmb.ci_pipeline_material_id,
+                                                                                          cpmb.branch_name,
+                                                                                          c.entry ->> 'Commit',
+                                                                                          json_array_length(cpmb.commit_history::json) - c.idx + 1,
+                                                                                          c.entry ->> 'SkipReason',
+                                                                                          now()
+FROM public.ci_pipeline_material_branch cpmb,
+     json_array_elements(CASE WHEN cpmb.commit_history LIKE '[%' THEN cpmb.commit_history::json ELSE '[]'::json END) WITH ORDINALITY AS c(entry, idx)
+WHERE cpmb.active = true
+ON CONFLICT DO NOTHING;
+
+
+---- ALTER TABLE ci_pipeline_material - drop columns duplicated in git_commit
+ALTER TABLE ci_pipeline_material
+DROP COLUMN IF EXISTS commit_history;
+
+ALTER TABLE ci_pipeline_material
+DROP COLUMN IF EXISTS commit_author;
+
+ALTER TABLE ci_pipeline_material
+DROP COLUMN IF EXISTS commit_date;
+
+---- ALTER TABLE ci_pipeline_material_branch - drop columns duplicated in git_commit
+ALTER TABLE ci_pipeline_material_branch
+DROP COLUMN IF EXISTS commit_history;
+
+ALTER TABLE ci_pipeline_material_branch
+DROP COLUMN IF EXISTS commit_author;
+
+ALTER TABLE ci_pipeline_material_branch
+DROP COLUMN IF EXISTS commit_date;
@@ -0,0 +1,5 @@
+---- drop table ci_pipeline_material_branch
+DROP TABLE IF EXISTS public.ci_pipeline_material_branch;
+
+---- DROP sequence
+DROP SEQUENCE IF EXISTS public.ci_pipeline_material_branch_id_seq;
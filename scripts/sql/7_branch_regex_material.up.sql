@@ -0,0 +1,39 @@
+--
+-- Name: ci_pipeline_material_branch_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE IF NOT EXISTS public.ci_pipeline_material_branch_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE CACHE 1;
+
+
+--
+-- Name: ci_pipeline_material_branch; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS public.ci_pipeline_material_branch
+(
+    id                      INTEGER                NOT NULL DEFAULT nextval('ci_pipeline_material_branch_id_seq'::regclass),
+    ci_pipeline_material_id INTEGER                NOT NULL,
+    branch_name             character varying(250) NOT NULL,
+    last_seen_hash          character varying(250) NOT NULL,
+    commit_author           character varying(250),
+    commit_date             timestamptz,
+    commit_history          text,
+    active                  bool                   NOT NULL,
+    created_on              timestamptz            NOT NULL,
+    updated_on              timestamptz,
+    PRIMARY KEY ("id")
+);
+
+
+---- Add Foreign key constraint on ci_pipeline_material_id in Table ci_pipeline_material_branch
+ALTER TABLE ci_pipeline_material_branch
+    ADD CONSTRAINT ci_pipeline_material_branch_ci_pipeline_material_id_fkey FOREIGN KEY (ci_pipeline_material_id) REFERENCES public.ci_pipeline_material (id);
+
+
+--- Create index on ci_pipeline_material_branch.ci_pipeline_material_id
+CREATE INDEX IF NOT EXISTS ci_pipeline_material_branch_IX1 ON public.ci_pipeline_material_branch (ci_pipeline_material_id, active);
@@ -0,0 +1,5 @@
+---- drop table git_material_tag
+DROP TABLE IF EXISTS public.git_material_tag;
+
+---- DROP sequence
+DROP SEQUENCE IF EXISTS public.git_material_tag_id_seq;
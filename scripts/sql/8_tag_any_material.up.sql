@@ -0,0 +1,37 @@
+--
+-- Name: git_material_tag_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE IF NOT EXISTS public.git_material_tag_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE CACHE 1;
+
+
+--
+-- Name: git_material_tag; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE IF NOT EXISTS public.git_material_tag
+(
+    id              INTEGER                NOT NULL DEFAULT nextval('git_material_tag_id_seq'::regclass),
+    git_material_id INTEGER                NOT NULL,
+    tag_name        character varying(250) NOT NULL,
+    commit_hash     character varying(250) NOT NULL,
+    commit_author   character varying(250),
+    commit_date     timestamptz,
+    commit_message  text,
+    created_on      timestamptz            NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+
+---- Add Foreign key constraint on git_material_id in Table git_material_tag
+ALTER TABLE git_material_tag
+    ADD CONSTRAINT git_material_tag_git_material_id_fkey FOREIGN KEY (git_material_id) REFERENCES public.git_material (id);
+
+
+--- Create unique index on git_material_tag.git_material_id, tag_name
+CREATE UNIQUE INDEX IF NOT EXISTS git_material_tag_UX1 ON public.git_material_tag (git_material_id, tag_name);
@@ -71,6 +71,22 @@ func InitializeApp() (*App, error) {
 		wire.Bind(new(git.WebhookEventParser), new(*git.WebhookEventParserImpl)),
 		git.NewWebhookHandlerImpl,
 		wire.Bind(new(git.WebhookHandler), new(*git.WebhookHandlerImpl)),
+		sql.NewCiPipelineMaterialBranchRepositoryImpl,
+		wire.Bind(new(sql.CiPipelineMaterialBranchRepository), new(*sql.CiPipelineMaterialBranchRepositoryImpl)),
+		sql.NewGitMaterialTagRepositoryImpl,
+		wire.Bind(new(sql.GitMaterialTagRepository), new(*sql.GitMaterialTagRepositoryImpl)),
+		git.NewRepositoryMaintenanceServiceImpl,
+		wire.Bind(new(git.RepositoryMaintenanceService), new(*git.RepositoryMaintenanceServiceImpl)),
+		git.NewDiskQuotaServiceImpl,
+		wire.Bind(new(git.DiskQuotaService), new(*git.DiskQuotaServiceImpl)),
+		sql.NewCommitStatsRepositoryImpl,
+		wire.Bind(new(sql.CommitStatsRepository), new(*sql.CommitStatsRepositoryImpl)),
+		git.NewCommitStatsServiceImpl,
+		wire.Bind(new(git.CommitStatsService), new(*git.CommitStatsServiceImpl)),
+		sql.NewGitCommitRepositoryImpl,
+		wire.Bind(new(sql.GitCommitRepository), new(*sql.GitCommitRepositoryImpl)),
+		git.NewCommitStoreServiceImpl,
+		wire.Bind(new(git.CommitStoreService), new(*git.CommitStoreServiceImpl)),
 	)
 	return &App{}, nil
 }
@@ -27,15 +27,20 @@ func InitializeApp() (*App, error) {
 		return nil, err
 	}
 	materialRepositoryImpl := sql.NewMaterialRepositoryImpl(db)
-	gitUtil := git.NewGitUtil(sugaredLogger)
 	configuration, err := internal.ParseConfiguration()
 	if err != nil {
 		return nil, err
 	}
-	repositoryManagerImpl := git.NewRepositoryManagerImpl(sugaredLogger, gitUtil, configuration)
+	gitUtil := git.NewGitUtil(sugaredLogger, configuration)
+	commitStatsRepositoryImpl := sql.NewCommitStatsRepositoryImpl(db)
+	repositoryLocker := internal.NewRepositoryLocker(sugaredLogger)
+	commitStatsServiceImpl, err := git.NewCommitStatsServiceImpl(sugaredLogger, commitStatsRepositoryImpl, repositoryLocker)
+	if err != nil {
+		return nil, err
+	}
+	repositoryManagerImpl := git.NewRepositoryManagerImpl(sugaredLogger, gitUtil, configuration, commitStatsServiceImpl)
 	gitProviderRepositoryImpl := sql.NewGitProviderRepositoryImpl(db)
 	ciPipelineMaterialRepositoryImpl := sql.NewCiPipelineMaterialRepositoryImpl(db, sugaredLogger)
-	repositoryLocker := internal.NewRepositoryLocker(sugaredLogger)
 	pubSubClient, err := internal.NewNatsConnection(sugaredLogger)
 	if err != nil {
 		return nil, err
@@ -48,13 +53,25 @@ func InitializeApp() (*App, error) {
 	webhookEventServiceImpl := git.NewWebhookEventServiceImpl(sugaredLogger, webhookEventRepositoryImpl, webhookEventParsedDataRepositoryImpl, webhookEventDataMappingRepositoryImpl, webhookEventDataMappingFilterResultRepositoryImpl, materialRepositoryImpl, pubSubClient, webhookEventBeanConverterImpl)
 	webhookEventParserImpl := git.NewWebhookEventParserImpl(sugaredLogger)
 	webhookHandlerImpl := git.NewWebhookHandlerImpl(sugaredLogger, webhookEventServiceImpl, webhookEventParserImpl)
-	gitWatcherImpl, err := git.NewGitWatcherImpl(repositoryManagerImpl, materialRepositoryImpl, sugaredLogger, ciPipelineMaterialRepositoryImpl, repositoryLocker, pubSubClient, webhookHandlerImpl)
+	ciPipelineMaterialBranchRepositoryImpl := sql.NewCiPipelineMaterialBranchRepositoryImpl(db)
+	gitMaterialTagRepositoryImpl := sql.NewGitMaterialTagRepositoryImpl(db)
+	gitCommitRepositoryImpl := sql.NewGitCommitRepositoryImpl(db)
+	commitStoreServiceImpl := git.NewCommitStoreServiceImpl(sugaredLogger, gitCommitRepositoryImpl, commitStatsServiceImpl)
+	gitWatcherImpl, err := git.NewGitWatcherImpl(repositoryManagerImpl, materialRepositoryImpl, sugaredLogger, ciPipelineMaterialRepositoryImpl, repositoryLocker, pubSubClient, webhookHandlerImpl, ciPipelineMaterialBranchRepositoryImpl, gitMaterialTagRepositoryImpl, commitStoreServiceImpl)
+	if err != nil {
+		return nil, err
+	}
+	diskQuotaServiceImpl, err := git.NewDiskQuotaServiceImpl(sugaredLogger, materialRepositoryImpl, repositoryManagerImpl, repositoryLocker, pubSubClient)
+	if err != nil {
+		return nil, err
+	}
+	repoManagerImpl := pkg.NewRepoManagerImpl(sugaredLogger, materialRepositoryImpl, repositoryManagerImpl, gitProviderRepositoryImpl, ciPipelineMaterialRepositoryImpl, repositoryLocker, gitWatcherImpl, webhookEventRepositoryImpl, webhookEventParsedDataRepositoryImpl, webhookEventDataMappingRepositoryImpl, webhookEventDataMappingFilterResultRepositoryImpl, webhookEventBeanConverterImpl, ciPipelineMaterialBranchRepositoryImpl, gitMaterialTagRepositoryImpl, diskQuotaServiceImpl, commitStoreServiceImpl)
+	repositoryMaintenanceServiceImpl, err := git.NewRepositoryMaintenanceServiceImpl(sugaredLogger, materialRepositoryImpl, gitUtil, repositoryLocker)
 	if err != nil {
 		return nil, err
 	}
-	repoManagerImpl := pkg.NewRepoManagerImpl(sugaredLogger, materialRepositoryImpl, repositoryManagerImpl, gitProviderRepositoryImpl, ciPipelineMaterialRepositoryImpl, repositoryLocker, gitWatcherImpl, webhookEventRepositoryImpl, webhookEventParsedDataRepositoryImpl, webhookEventDataMappingRepositoryImpl, webhookEventDataMappingFilterResultRepositoryImpl, webhookEventBeanConverterImpl)
-	restHandlerImpl := api.NewRestHandlerImpl(repoManagerImpl, sugaredLogger)
+	restHandlerImpl := api.NewRestHandlerImpl(repoManagerImpl, sugaredLogger, repositoryMaintenanceServiceImpl)
 	muxRouter := api.NewMuxRouter(sugaredLogger, restHandlerImpl)
-	app := NewApp(muxRouter, sugaredLogger, gitWatcherImpl, db, pubSubClient)
+	app := NewApp(muxRouter, sugaredLogger, gitWatcherImpl, db, pubSubClient, repositoryMaintenanceServiceImpl, diskQuotaServiceImpl)
 	return app, nil
 }